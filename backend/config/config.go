@@ -1,8 +1,14 @@
 package config
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"gorm.io/driver/postgres"
@@ -20,12 +26,74 @@ type Config struct {
 	RedisHost   string
 	RedisPort   string
 	JWTSecret   string
+
+	// Minutes an access token stays valid; keep short in production
+	JWTExpiryMinutes int
+	// Expected "iss" claim; tokens from any other issuer are rejected
+	JWTIssuer string
+
+	// Connection pool limits for the database/sql pool behind gorm
+	DBMaxOpenConns       int
+	DBMaxIdleConns       int
+	DBConnMaxLifetimeMin int
+
+	// Prepended to every Redis key so environments can share one Redis
+	RedisKeyPrefix string
+	RedisPassword  string
+	// Redis logical database index, parsed by InitRedis
+	RedisDB string
+	// Connect to Redis over TLS, as managed providers require
+	RedisTLS bool
+
+	// Frontend origin players join from, used to build join links
+	PublicBaseURL string
+
+	// Origins allowed for CORS and WebSocket upgrades; empty allows all
+	AllowedOrigins []string
+
+	// Failed logins allowed per email and IP before a lockout (0 disables)
+	LoginMaxAttempts int
+	// Seconds failures are counted over, and how long a lockout lasts
+	LoginLockoutSeconds int
+
+	// Longest a started game may run in minutes before it is finished automatically (0 disables)
+	GameMaxDurationMinutes int
+	// Minutes game state stays in Redis after its last write
+	GameStateTTLMinutes int
+	// Seconds a finished game's state stays in Redis for late syncs (0 clears it at once)
+	FinishedGameGraceSeconds int
+	// Game PINs are "hex" or "numeric", PinLength (4-10) characters long
+	PinAlphabet string
+	PinLength   int
+
+	// Longest an API request's database queries may run in seconds (0 disables)
+	RequestTimeoutSeconds int
+
+	// Seconds to wait for in-flight requests and WebSocket clients on shutdown
+	ShutdownTimeoutSeconds int
+
+	// Minimum level logged: debug, info, warn or error
+	LogLevel string
+
+	// Serve Prometheus metrics at /metrics
+	MetricsEnabled bool
+
+	// Inbound WebSocket messages allowed per client per second (0 disables)
+	WSMessageRateLimit int
+	// Largest inbound WebSocket message in bytes; larger frames close the connection
+	WSMaxMessageSize int64
+	// Seconds the game creator has to reconnect before their game is ended (0 ends it at once)
+	HostReconnectGraceSeconds int
+
+	// Caps on what each user can store (0 disables)
+	MaxQuizzesPerUser   int
+	MaxQuestionsPerQuiz int
 }
 
 func Load() *Config {
 	return &Config{
 		Port:        getEnv("PORT", "8080"),
-		BindAddress: getEnv("BIND_ADDRESS", "localhost"),
+		BindAddress: getEnvAllowEmpty("BIND_ADDRESS", "localhost"),
 		DBHost:      getEnv("DB_HOST", "localhost"),
 		DBPort:      getEnv("DB_PORT", "5432"),
 		DBUser:      getEnv("DB_USER", "openquiz"),
@@ -34,6 +102,47 @@ func Load() *Config {
 		RedisHost:   getEnv("REDIS_HOST", "localhost"),
 		RedisPort:   getEnv("REDIS_PORT", "6379"),
 		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+
+		JWTExpiryMinutes: getEnvInt("JWT_EXPIRY_MINUTES", 60),
+		JWTIssuer:        getEnv("JWT_ISSUER", "openquiz"),
+
+		DBMaxOpenConns:       getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:       getEnvInt("DB_MAX_IDLE_CONNS", 10),
+		DBConnMaxLifetimeMin: getEnvInt("DB_CONN_MAX_LIFETIME_MINUTES", 30),
+
+		RedisKeyPrefix: getEnv("REDIS_KEY_PREFIX", ""),
+		RedisPassword:  getEnv("REDIS_PASSWORD", ""),
+		RedisDB:        getEnv("REDIS_DB", "0"),
+		RedisTLS:       getEnvBool("REDIS_TLS", false),
+
+		PublicBaseURL: getEnv("PUBLIC_BASE_URL", "http://localhost:3000"),
+
+		AllowedOrigins: getEnvList("ALLOWED_ORIGINS"),
+
+		LoginMaxAttempts:    getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginLockoutSeconds: getEnvInt("LOGIN_LOCKOUT_SECONDS", 900),
+
+		GameMaxDurationMinutes:   getEnvInt("GAME_MAX_DURATION_MINUTES", 120),
+		GameStateTTLMinutes:      getEnvInt("GAME_STATE_TTL_MINUTES", 120),
+		FinishedGameGraceSeconds: getEnvInt("FINISHED_GAME_GRACE_SECONDS", 300),
+		PinAlphabet:              getEnv("PIN_ALPHABET", "hex"),
+		PinLength:                getEnvInt("PIN_LENGTH", 6),
+
+		RequestTimeoutSeconds: getEnvInt("REQUEST_TIMEOUT_SECONDS", 15),
+
+		ShutdownTimeoutSeconds: getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 10),
+
+		LogLevel: getEnv("LOG_LEVEL", "info"),
+
+		MetricsEnabled: getEnvBool("METRICS_ENABLED", true),
+
+		WSMessageRateLimit: getEnvInt("WS_MESSAGE_RATE_LIMIT", 10),
+		WSMaxMessageSize:   int64(getEnvInt("WS_MAX_MESSAGE_SIZE", 4096)),
+
+		HostReconnectGraceSeconds: getEnvInt("HOST_RECONNECT_GRACE_SECONDS", 30),
+
+		MaxQuizzesPerUser:   getEnvInt("MAX_QUIZZES_PER_USER", 500),
+		MaxQuestionsPerQuiz: getEnvInt("MAX_QUESTIONS_PER_QUIZ", 200),
 	}
 }
 
@@ -44,6 +153,54 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvAllowEmpty is like getEnv but keeps an explicitly empty value
+func getEnvAllowEmpty(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList splits a comma-separated environment variable, skipping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// InitLogger creates the JSON logger used across the server at the configured
+// level, falling back to info for an unrecognized level
+func InitLogger(cfg *Config) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+}
+
 func InitDB(cfg *Config) (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
 		cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort)
@@ -53,15 +210,48 @@ func InitDB(cfg *Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to access database pool: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.DBConnMaxLifetimeMin) * time.Minute)
+
 	return db, nil
 }
 
-func InitRedis(cfg *Config) *redis.Client {
-	client := redis.NewClient(&redis.Options{
+// redisPingTimeout bounds the startup connectivity check
+const redisPingTimeout = 5 * time.Second
+
+func InitRedis(cfg *Config) (*redis.Client, error) {
+	redisDB, err := strconv.Atoi(cfg.RedisDB)
+	if err != nil || redisDB < 0 {
+		return nil, fmt.Errorf("invalid REDIS_DB %q: must be a non-negative integer", cfg.RedisDB)
+	}
+
+	opts := &redis.Options{
 		Addr:     fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
-		Password: "", // no password set
-		DB:       0,  // use default DB
-	})
+		Password: cfg.RedisPassword,
+		DB:       redisDB,
+	}
+	if cfg.RedisTLS {
+		opts.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: cfg.RedisHost,
+		}
+	}
+
+	client := redis.NewClient(opts)
+
+	// Game state lives in Redis, so surface a bad address or credentials at boot
+	// rather than as lost state mid-game
+	ctx, cancel := context.WithTimeout(context.Background(), redisPingTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", opts.Addr, err)
+	}
 
-	return client
+	return client, nil
 }
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllowedOriginsFromEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"https://quiz.example.com", []string{"https://quiz.example.com"}},
+		{"https://quiz.example.com, https://admin.example.com,", []string{"https://quiz.example.com", "https://admin.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("ALLOWED_ORIGINS", tt.value)
+		if got := Load().AllowedOrigins; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ALLOWED_ORIGINS=%q gave %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"openquiz/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AdminHandler struct {
+	authService *services.AuthService
+	quizService *services.QuizService
+	hub         *services.Hub
+}
+
+func NewAdminHandler(authService *services.AuthService, quizService *services.QuizService, hub *services.Hub) *AdminHandler {
+	return &AdminHandler{
+		authService: authService,
+		quizService: quizService,
+		hub:         hub,
+	}
+}
+
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	users, err := h.authService.ListUsers(c.Request.Context())
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func (h *AdminHandler) ListQuizzes(c *gin.Context) {
+	quizzes, err := h.quizService.AdminListQuizzes(c.Request.Context())
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, quizzes)
+}
+
+func (h *AdminHandler) DeleteQuiz(c *gin.Context) {
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid quiz ID")
+		return
+	}
+
+	if err := h.quizService.AdminDeleteQuiz(c.Request.Context(), uint(quizID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondError(c, http.StatusNotFound, "Quiz not found")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quiz deleted successfully"})
+}
+
+// HubStats reports the WebSocket connections currently open, per game
+func (h *AdminHandler) HubStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.hub.Stats())
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes returned in the "code" field of every error response
+const (
+	ErrCodeValidation   = "validation_error"
+	ErrCodeUnauthorized = "unauthorized"
+	ErrCodeForbidden    = "forbidden"
+	ErrCodeNotFound     = "not_found"
+	ErrCodeConflict     = "conflict"
+	ErrCodeRateLimited  = "rate_limited"
+	ErrCodeUnavailable  = "unavailable"
+	ErrCodeInternal     = "internal_error"
+)
+
+// ErrorResponse is the JSON envelope for all API errors
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// RespondError writes an error envelope whose code is derived from the HTTP status
+func RespondError(c *gin.Context, status int, message string) {
+	c.JSON(status, ErrorResponse{Code: errorCode(status), Message: message})
+}
+
+// AbortWithError writes an error envelope and stops the handler chain
+func AbortWithError(c *gin.Context, status int, message string) {
+	RespondError(c, status, message)
+	c.Abort()
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrCodeValidation
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusTooManyRequests:
+		return ErrCodeRateLimited
+	case http.StatusServiceUnavailable:
+		return ErrCodeUnavailable
+	default:
+		return ErrCodeInternal
+	}
+}
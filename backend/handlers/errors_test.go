@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondErrorCodes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		status   int
+		wantCode string
+	}{
+		{http.StatusBadRequest, ErrCodeValidation},
+		{http.StatusUnauthorized, ErrCodeUnauthorized},
+		{http.StatusForbidden, ErrCodeForbidden},
+		{http.StatusNotFound, ErrCodeNotFound},
+		{http.StatusConflict, ErrCodeConflict},
+		{http.StatusTooManyRequests, ErrCodeRateLimited},
+		{http.StatusServiceUnavailable, ErrCodeUnavailable},
+		{http.StatusInternalServerError, ErrCodeInternal},
+		{http.StatusTeapot, ErrCodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			RespondError(c, tt.status, "something went wrong")
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if body := decodeError(t, w); body.Code != tt.wantCode || body.Message != "something went wrong" {
+				t.Errorf("body = %+v, want code %q", body, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAbortWithErrorStopsTheChain(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	reached := false
+	router.GET("/", func(c *gin.Context) {
+		AbortWithError(c, http.StatusForbidden, "Admin access required")
+	}, func(c *gin.Context) {
+		reached = true
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if reached {
+		t.Error("handler after AbortWithError ran")
+	}
+	if body := decodeError(t, w); w.Code != http.StatusForbidden || body.Code != ErrCodeForbidden {
+		t.Errorf("got %d %+v, want 403 forbidden", w.Code, body)
+	}
+}
+
+func TestHandlerFailuresUseTheErrorEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	gameHandler, _ := newTestGameHandler()
+	router := gin.New()
+	router.POST("/api/auth/login", NewAuthHandler(nil).Login)
+	router.POST("/api/quizzes", NewQuizHandler(nil).CreateQuiz)
+	router.GET("/api/quizzes/:id", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+	}, NewQuizHandler(nil).GetQuizByID)
+	router.POST("/api/games/:pin/answer", gameHandler.SubmitAnswer)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"malformed login", http.MethodPost, "/api/auth/login", `{"email": `, http.StatusBadRequest, ErrCodeValidation},
+		{"login missing fields", http.MethodPost, "/api/auth/login", `{}`, http.StatusBadRequest, ErrCodeValidation},
+		{"quiz without a user", http.MethodPost, "/api/quizzes", `{}`, http.StatusUnauthorized, ErrCodeUnauthorized},
+		{"invalid quiz ID", http.MethodGet, "/api/quizzes/abc", "", http.StatusBadRequest, ErrCodeValidation},
+		{"answer without a socket token", http.MethodPost, "/api/games/abc123/answer", `{}`, http.StatusUnauthorized, ErrCodeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			// The envelope has exactly a code and a non-empty message
+			var fields map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+				t.Fatalf("decode body %q: %v", w.Body.String(), err)
+			}
+			if len(fields) != 2 || fields["code"] != tt.wantCode {
+				t.Errorf("body = %s, want only code %q and message", w.Body.String(), tt.wantCode)
+			}
+			if message, _ := fields["message"].(string); message == "" {
+				t.Errorf("body = %s, want a message", w.Body.String())
+			}
+		})
+	}
+}
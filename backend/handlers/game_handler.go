@@ -1,9 +1,10 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
-	"strings"
+	"strconv"
 
 	"openquiz/services"
 
@@ -12,103 +13,441 @@ import (
 
 type GameHandler struct {
 	gameService *services.GameService
+	authService *services.AuthService
 	hub         *services.Hub
+	// Frontend origin join links are built from
+	publicBaseURL string
 }
 
-func NewGameHandler(gameService *services.GameService, hub *services.Hub) *GameHandler {
+func NewGameHandler(gameService *services.GameService, authService *services.AuthService, hub *services.Hub, publicBaseURL string) *GameHandler {
 	return &GameHandler{
-		gameService: gameService,
-		hub:         hub,
+		gameService:   gameService,
+		authService:   authService,
+		hub:           hub,
+		publicBaseURL: publicBaseURL,
 	}
 }
 
 func (h *GameHandler) StartGame(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	var req services.StartGameRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	game, err := h.gameService.StartGame(userID.(uint), &req)
+	game, err := h.gameService.StartGame(c.Request.Context(), userID.(uint), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	joinURL := services.JoinURL(h.publicBaseURL, game.Pin)
+	game.Pin = services.DisplayPin(game.Pin)
+	c.JSON(http.StatusCreated, services.StartGameResponse{
+		Game:     *game,
+		JoinURL:  joinURL,
+		JoinCode: game.Pin,
+	})
+}
+
+func (h *GameHandler) PrepareGame(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req services.PrepareGameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	game, err := h.gameService.PrepareGame(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	game.Pin = services.DisplayPin(game.Pin)
 	c.JSON(http.StatusCreated, game)
 }
 
+func (h *GameHandler) GetUserGames(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var query services.ListGamesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	games, err := h.gameService.GetUserGames(c.Request.Context(), userID.(uint), &query)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, games)
+}
+
+// GetActiveGames lists the user's games that haven't finished
+func (h *GameHandler) GetActiveGames(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	games, err := h.gameService.GetActiveGamesForUser(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, games)
+}
+
 func (h *GameHandler) JoinGame(c *gin.Context) {
 	var req services.JoinGameRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	player, err := h.gameService.JoinGame(&req)
+	pin, err := services.NormalizePin(req.Pin)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Pin = pin
+
+	// A valid guest token carries the player's history into this game; anyone
+	// else becomes a new guest. An expired token just starts a new history.
+	if req.GuestToken != "" {
+		if guest, err := h.authService.ValidateGuestToken(req.GuestToken); err == nil {
+			req.GuestID = guest.ID
+		}
+	}
+	if req.GuestID == "" {
+		guestID, err := services.NewGuestID()
+		if err != nil {
+			RespondError(c, http.StatusInternalServerError, "Failed to create guest identity")
+			return
+		}
+		req.GuestID = guestID
+	}
+
+	player, rejoined, err := h.gameService.JoinGame(c.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrGameNotFound):
+			RespondError(c, http.StatusNotFound, "Game not found")
+		case errors.Is(err, services.ErrGameNotJoinable):
+			RespondError(c, http.StatusConflict, err.Error())
+		default:
+			RespondError(c, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	// Issue a token the player can use to resume this identity after a disconnect
+	reconnectToken, err := h.gameService.IssueReconnectToken(req.Pin, player.ID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "Failed to issue reconnect token")
+		return
+	}
+
+	socketToken, err := h.gameService.IssueSocketToken(req.Pin, player.ID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "Failed to issue socket token")
+		return
+	}
+
+	// A device that rejoined keeps the guest it first joined as
+	guest := services.GuestIdentity{ID: req.GuestID, Name: player.Name}
+	if player.GuestID != "" {
+		guest.ID = player.GuestID
+	}
+	guestToken, err := h.authService.IssueGuestToken(guest)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "Failed to issue guest token")
 		return
 	}
 
 	// Broadcast player update to all connected clients in this game
-	if h.hub != nil {
+	if h.hub != nil && !rejoined {
 		h.hub.BroadcastPlayerUpdate(req.Pin, *player, "joined")
+		h.gameService.CheckAutoStart(c.Request.Context(), req.Pin, h.hub)
 	}
 
-	c.JSON(http.StatusOK, player)
+	c.JSON(http.StatusOK, services.JoinGameResponse{
+		Player:         *player,
+		ReconnectToken: reconnectToken,
+		SocketToken:    socketToken,
+		Rejoined:       rejoined,
+		GuestToken:     guestToken,
+	})
+}
+
+// gamePinParam reads and normalizes the :pin path parameter, responding 400
+// when it isn't a well-formed PIN so malformed values never reach the database
+func gamePinParam(c *gin.Context) (string, bool) {
+	pin, err := services.NormalizePin(c.Param("pin"))
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return pin, true
 }
 
 func (h *GameHandler) GetGameByPin(c *gin.Context) {
-	pin := c.Param("pin")
-	if pin == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Game PIN required"})
+	normalizedPin, ok := gamePinParam(c)
+	if !ok {
 		return
 	}
 
-	// Normalize pin to lowercase for consistent handling
-	normalizedPin := strings.ToLower(pin)
-
-	game, err := h.gameService.GetGameByPin(normalizedPin)
+	game, err := h.gameService.GetGameWithState(c.Request.Context(), normalizedPin)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Game not found"})
+		if errors.Is(err, services.ErrGameNotFound) {
+			RespondError(c, http.StatusNotFound, "Game not found")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	c.JSON(http.StatusOK, game)
 }
 
-func (h *GameHandler) SubmitAnswer(c *gin.Context) {
-	gamePin := c.Param("pin")
-	if gamePin == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Game PIN required"})
+// GetGuestGames lists the games played by the guest whose token is sent in
+// the X-Guest-Token header
+func (h *GameHandler) GetGuestGames(c *gin.Context) {
+	guest, err := h.authService.ValidateGuestToken(c.GetHeader("X-Guest-Token"))
+	if err != nil {
+		RespondError(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	// Normalize game pin to lowercase for consistent handling
-	normalizedPin := strings.ToLower(gamePin)
+	games, err := h.gameService.GetGuestGames(c.Request.Context(), guest.ID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	var req services.SubmitAnswerRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, gin.H{"guest": guest, "games": games})
+}
+
+// GameExists lets the join screen check a PIN before asking for a name
+func (h *GameHandler) GameExists(c *gin.Context) {
+	normalizedPin, ok := gamePinParam(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.gameService.IsJoinable(c.Request.Context(), normalizedPin)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *GameHandler) CancelGame(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	normalizedPin, ok := gamePinParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.gameService.CancelGame(c.Request.Context(), normalizedPin, userID.(uint), h.hub); err != nil {
+		switch {
+		case errors.Is(err, services.ErrGameNotFound):
+			RespondError(c, http.StatusNotFound, "Game not found")
+		case errors.Is(err, services.ErrNotGameOwner):
+			RespondError(c, http.StatusForbidden, err.Error())
+		case errors.Is(err, services.ErrGameNotWaiting):
+			RespondError(c, http.StatusConflict, err.Error())
+		default:
+			RespondError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Game cancelled successfully"})
+}
+
+func (h *GameHandler) EndGame(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	normalizedPin, ok := gamePinParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.gameService.EndGame(c.Request.Context(), normalizedPin, userID.(uint), h.hub); err != nil {
+		switch {
+		case errors.Is(err, services.ErrGameNotFound):
+			RespondError(c, http.StatusNotFound, "Game not found")
+		case errors.Is(err, services.ErrNotGameOwner):
+			RespondError(c, http.StatusForbidden, err.Error())
+		case errors.Is(err, services.ErrGameNotActive):
+			RespondError(c, http.StatusConflict, err.Error())
+		default:
+			RespondError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Game ended successfully"})
+}
+
+func (h *GameHandler) GetGameResults(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	normalizedPin, ok := gamePinParam(c)
+	if !ok {
+		return
+	}
+
+	results, err := h.gameService.GetGameResults(c.Request.Context(), normalizedPin, userID.(uint))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrGameNotFound):
+			RespondError(c, http.StatusNotFound, "Game not found")
+		case errors.Is(err, services.ErrNotGameOwner):
+			RespondError(c, http.StatusForbidden, err.Error())
+		default:
+			RespondError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (h *GameHandler) GetPlayerAnswers(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	normalizedPin, ok := gamePinParam(c)
+	if !ok {
+		return
+	}
+
+	playerID, err := strconv.ParseUint(c.Param("playerID"), 10, 32)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid player ID")
+		return
+	}
+
+	answers, err := h.gameService.GetPlayerAnswers(c.Request.Context(), normalizedPin, uint(playerID), userID.(uint))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrGameNotFound):
+			RespondError(c, http.StatusNotFound, "Game not found")
+		case errors.Is(err, services.ErrPlayerNotInGame):
+			RespondError(c, http.StatusNotFound, "Player not found")
+		case errors.Is(err, services.ErrNotGameOwner):
+			RespondError(c, http.StatusForbidden, err.Error())
+		default:
+			RespondError(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, answers)
+}
+
+func (h *GameHandler) GetQuestionTimer(c *gin.Context) {
+	normalizedPin, ok := gamePinParam(c)
+	if !ok {
 		return
 	}
 
-	// Get player ID from the request body
-	if req.PlayerID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Player ID required"})
+	timer, err := h.gameService.GetQuestionTimer(c.Request.Context(), normalizedPin)
+	if err != nil {
+		if errors.Is(err, services.ErrGameNotFound) {
+			RespondError(c, http.StatusNotFound, "Game not found")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, timer)
+}
+
+func (h *GameHandler) SubmitAnswer(c *gin.Context) {
+	normalizedPin, ok := gamePinParam(c)
+	if !ok {
 		return
 	}
 
-	err := h.gameService.SubmitAnswer(normalizedPin, req.PlayerID, &req, h.hub)
+	// The player is whoever the socket token from joining was issued to, so
+	// knowing a pin and player ID isn't enough to answer for someone
+	socketToken := c.GetHeader("X-Socket-Token")
+	if socketToken == "" {
+		RespondError(c, http.StatusUnauthorized, "Socket token required")
+		return
+	}
+	playerID, err := h.gameService.ValidateSocketToken(normalizedPin, socketToken)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusUnauthorized, "Invalid socket token")
+		return
+	}
+
+	var req services.SubmitAnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.PlayerID != 0 && req.PlayerID != playerID {
+		RespondError(c, http.StatusForbidden, "Socket token was issued to another player")
+		return
+	}
+	req.PlayerID = playerID
+
+	if err := h.gameService.SubmitAnswer(c.Request.Context(), normalizedPin, playerID, &req, h.hub); err != nil {
+		switch {
+		case errors.Is(err, services.ErrGameNotFound):
+			RespondError(c, http.StatusNotFound, "Game not found")
+		case errors.Is(err, services.ErrPlayerNotInGame):
+			RespondError(c, http.StatusForbidden, err.Error())
+		case errors.Is(err, services.ErrGameNotActive),
+			errors.Is(err, services.ErrQuestionNotActive),
+			errors.Is(err, services.ErrAnswerAlreadySubmitted):
+			RespondError(c, http.StatusConflict, err.Error())
+		default:
+			RespondError(c, http.StatusBadRequest, err.Error())
+		}
 		return
 	}
 
@@ -118,65 +457,62 @@ func (h *GameHandler) SubmitAnswer(c *gin.Context) {
 func (h *GameHandler) StartQuiz(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
-	gamePin := c.Param("pin")
-	if gamePin == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Game PIN required"})
+	normalizedPin, ok := gamePinParam(c)
+	if !ok {
 		return
 	}
 
-	// Normalize game pin to lowercase for consistent handling
-	normalizedPin := strings.ToLower(gamePin)
-
 	// Start the quiz using the game service
-	game, err := h.gameService.StartQuiz(normalizedPin, userID.(uint))
+	game, err := h.gameService.StartQuiz(c.Request.Context(), normalizedPin, userID.(uint), h.hub)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Start the first question
-	if err := h.gameService.StartQuestion(normalizedPin, 0, h.hub); err != nil {
-		log.Printf("Error starting first question: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start first question"})
+	if err := h.gameService.StartQuestion(c.Request.Context(), normalizedPin, 0, h.hub); err != nil {
+		slog.Error("Error starting first question", "game_pin", normalizedPin, "error", err)
+		RespondError(c, http.StatusInternalServerError, "Failed to start first question")
 		return
 	}
 
 	// Get connected players and log them
 	connectedPlayers := h.hub.GetConnectedPlayers(normalizedPin)
-	log.Printf("Quiz started for game %s. Connected players: %v", normalizedPin, connectedPlayers)
+	slog.Info("Quiz started", "game_pin", normalizedPin, "connected_players", connectedPlayers)
 
+	game.Pin = services.DisplayPin(game.Pin)
 	c.JSON(http.StatusOK, gin.H{"message": "Quiz started successfully", "game": game})
 }
 
 func (h *GameHandler) NextQuestion(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
-	gamePin := c.Param("pin")
-	if gamePin == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Game PIN required"})
+	normalizedPin, ok := gamePinParam(c)
+	if !ok {
 		return
 	}
 
-	// Normalize game pin to lowercase for consistent handling
-	normalizedPin := strings.ToLower(gamePin)
-
 	// Check if user owns the game
-	if err := h.gameService.CheckGameOwnership(normalizedPin, userID.(uint)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	if err := h.gameService.CheckGameOwnership(c.Request.Context(), normalizedPin, userID.(uint)); err != nil {
+		RespondError(c, http.StatusUnauthorized, err.Error())
 		return
 	}
 
 	// Advance to next question
-	if err := h.gameService.NextQuestion(normalizedPin, h.hub); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.gameService.NextQuestion(c.Request.Context(), normalizedPin, h.hub); err != nil {
+		if errors.Is(err, services.ErrRevealInProgress) || errors.Is(err, services.ErrQuestionStarting) {
+			RespondError(c, http.StatusConflict, err.Error())
+			return
+		}
+		RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"openquiz/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testJWTSecret = "test-secret"
+
+// newTestGameHandler builds a game handler whose service has no database or
+// Redis, for requests rejected before either is needed
+func newTestGameHandler() (*GameHandler, *services.GameService) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	gameService := services.NewGameService(nil, nil, logger, testJWTSecret, "", 0, 0, "hex", 6, 0)
+	return NewGameHandler(gameService, nil, nil, "http://localhost:3000"), gameService
+}
+
+// decodeError reads the error envelope from a response
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) ErrorResponse {
+	t.Helper()
+
+	var body ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error body %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+func TestSubmitAnswerRequiresSocketToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h, gameService := newTestGameHandler()
+	router := gin.New()
+	router.POST("/api/games/:pin/answer", h.SubmitAnswer)
+
+	playerToken, err := gameService.IssueSocketToken("abc123", 5)
+	if err != nil {
+		t.Fatalf("IssueSocketToken: %v", err)
+	}
+	otherGameToken, err := gameService.IssueSocketToken("def456", 5)
+	if err != nil {
+		t.Fatalf("IssueSocketToken: %v", err)
+	}
+	reconnectToken, err := gameService.IssueReconnectToken("abc123", 5)
+	if err != nil {
+		t.Fatalf("IssueReconnectToken: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"missing token", "", `{"question_id": 1, "option_id": 2}`, http.StatusUnauthorized},
+		{"malformed token", "not-a-token", `{"question_id": 1, "option_id": 2}`, http.StatusUnauthorized},
+		{"token for another game", otherGameToken, `{"question_id": 1, "option_id": 2}`, http.StatusUnauthorized},
+		{"token of another type", reconnectToken, `{"question_id": 1, "option_id": 2}`, http.StatusUnauthorized},
+		{"player ID of someone else", playerToken, `{"player_id": 6, "question_id": 1, "option_id": 2}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/games/ABC123/answer", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("X-Socket-Token", tt.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if body := decodeError(t, w); body.Code == "" || body.Message == "" {
+				t.Errorf("error envelope = %+v, want a code and message", body)
+			}
+		})
+	}
+}
+
+// The handler's service has no database, so reaching it would panic
+func TestMalformedPinsAreRejectedBeforeLookup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h, _ := newTestGameHandler()
+	router := gin.New()
+	router.GET("/api/games/:pin", h.GetGameByPin)
+	router.POST("/api/games/join", h.JoinGame)
+
+	for _, pin := range []string{"abc", "zzzzzz", "%20%20%20%20", "ab%21cd", "0123456789abc"} {
+		t.Run("lookup "+pin, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/games/"+pin, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, http.StatusBadRequest, w.Body.String())
+			}
+		})
+	}
+
+	for _, pin := range []string{" ", "abc", "zzzzzz", "ab/cd1", "0a1b2c "} {
+		t.Run("join "+pin, func(t *testing.T) {
+			body := `{"pin": "` + pin + `", "name": "Ada"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/games/join", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, http.StatusBadRequest, w.Body.String())
+			}
+			if got := decodeError(t, w).Message; got != services.ErrInvalidPin.Error() {
+				t.Errorf("message = %q, want %q", got, services.ErrInvalidPin.Error())
+			}
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"openquiz/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
 )
 
 type QuizHandler struct {
@@ -22,19 +26,23 @@ func NewQuizHandler(quizService *services.QuizService) *QuizHandler {
 func (h *QuizHandler) CreateQuiz(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	var req services.CreateQuizRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	quiz, err := h.quizService.CreateQuiz(userID.(uint), &req)
+	quiz, err := h.quizService.CreateQuiz(c.Request.Context(), userID.(uint), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if isQuizLimitError(err) {
+			RespondError(c, http.StatusForbidden, err.Error())
+			return
+		}
+		RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -44,13 +52,30 @@ func (h *QuizHandler) CreateQuiz(c *gin.Context) {
 func (h *QuizHandler) GetUserQuizzes(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
-	quizzes, err := h.quizService.GetUserQuizzes(userID.(uint))
+	quizzes, err := h.quizService.GetUserQuizzes(c.Request.Context(), userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, quizzes)
+}
+
+// GetDeletedQuizzes lists the caller's quizzes in the trash
+func (h *QuizHandler) GetDeletedQuizzes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	quizzes, err := h.quizService.GetDeletedQuizzes(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -60,47 +85,208 @@ func (h *QuizHandler) GetUserQuizzes(c *gin.Context) {
 func (h *QuizHandler) GetQuizByID(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	quizID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID"})
+		RespondError(c, http.StatusBadRequest, "Invalid quiz ID")
 		return
 	}
 
-	quiz, err := h.quizService.GetQuizByID(uint(quizID), userID.(uint))
+	// ?include=author adds the quiz author's account
+	getQuiz := h.quizService.GetQuizByID
+	if c.Query("include") == "author" {
+		getQuiz = h.quizService.GetQuizWithAuthor
+	}
+
+	quiz, err := getQuiz(c.Request.Context(), uint(quizID), userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz not found"})
+		RespondError(c, http.StatusNotFound, "Quiz not found")
 		return
 	}
 
 	c.JSON(http.StatusOK, quiz)
 }
 
+// CreateQuizzes creates a batch of quizzes from a JSON array, all or none
+func (h *QuizHandler) CreateQuizzes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// Bind without validation first so a failure can name the quiz at fault
+	var reqs []services.CreateQuizRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&reqs); err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	for i := range reqs {
+		if err := binding.Validator.ValidateStruct(&reqs[i]); err != nil {
+			RespondError(c, http.StatusBadRequest, (&services.BatchQuizError{Index: i, Err: err}).Error())
+			return
+		}
+	}
+
+	quizzes, err := h.quizService.CreateQuizzes(c.Request.Context(), userID.(uint), reqs)
+	if err != nil {
+		if isQuizLimitError(err) {
+			RespondError(c, http.StatusForbidden, err.Error())
+			return
+		}
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, quizzes)
+}
+
+// PreviewQuiz shows the owner how a game will present the quiz's questions
+func (h *QuizHandler) PreviewQuiz(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid quiz ID")
+		return
+	}
+
+	preview, err := h.quizService.PreviewQuiz(c.Request.Context(), uint(quizID), userID.(uint))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondError(c, http.StatusNotFound, "Quiz not found")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
 func (h *QuizHandler) UpdateQuiz(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	quizID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID"})
+		RespondError(c, http.StatusBadRequest, "Invalid quiz ID")
 		return
 	}
 
 	var req services.UpdateQuizRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	quiz, err := h.quizService.UpdateQuiz(c.Request.Context(), uint(quizID), userID.(uint), &req)
+	if err != nil {
+		if isQuizLimitError(err) {
+			RespondError(c, http.StatusForbidden, err.Error())
+			return
+		}
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, quiz)
+}
+
+func (h *QuizHandler) UpdateTimeLimits(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid quiz ID")
+		return
+	}
+
+	var req services.UpdateTimeLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	quiz, err := h.quizService.UpdateTimeLimits(c.Request.Context(), uint(quizID), userID.(uint), &req)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, quiz)
+}
+
+func (h *QuizHandler) ReorderQuestions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid quiz ID")
+		return
+	}
+
+	var req services.ReorderQuestionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	quiz, err := h.quizService.UpdateQuiz(uint(quizID), userID.(uint), &req)
+	quiz, err := h.quizService.ReorderQuestions(c.Request.Context(), uint(quizID), userID.(uint), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, quiz)
+}
+
+func (h *QuizHandler) ReorderOptions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid quiz ID")
+		return
+	}
+
+	questionID, err := strconv.ParseUint(c.Param("qid"), 10, 32)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid question ID")
+		return
+	}
+
+	var req services.ReorderOptionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	quiz, err := h.quizService.ReorderOptions(c.Request.Context(), uint(quizID), uint(questionID), userID.(uint), &req)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -110,21 +296,81 @@ func (h *QuizHandler) UpdateQuiz(c *gin.Context) {
 func (h *QuizHandler) DeleteQuiz(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
 	quizID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiz ID"})
+		RespondError(c, http.StatusBadRequest, "Invalid quiz ID")
 		return
 	}
 
-	err = h.quizService.DeleteQuiz(uint(quizID), userID.(uint))
+	err = h.quizService.DeleteQuiz(c.Request.Context(), uint(quizID), userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Quiz deleted successfully"})
 }
+
+func (h *QuizHandler) RestoreQuiz(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid quiz ID")
+		return
+	}
+
+	quiz, err := h.quizService.RestoreQuiz(c.Request.Context(), uint(quizID), userID.(uint))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondError(c, http.StatusNotFound, "Deleted quiz not found")
+			return
+		}
+		if isQuizLimitError(err) {
+			RespondError(c, http.StatusForbidden, err.Error())
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, quiz)
+}
+
+func (h *QuizHandler) PermanentlyDeleteQuiz(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	quizID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, http.StatusBadRequest, "Invalid quiz ID")
+		return
+	}
+
+	if err := h.quizService.PermanentlyDeleteQuiz(c.Request.Context(), uint(quizID), userID.(uint)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondError(c, http.StatusNotFound, "Quiz not found")
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quiz permanently deleted"})
+}
+
+// isQuizLimitError reports whether err is one of the per-user content caps
+func isQuizLimitError(err error) bool {
+	return errors.Is(err, services.ErrQuizLimitReached) || errors.Is(err, services.ErrQuestionLimitExceeded)
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
 	"openquiz/config"
 	"openquiz/handlers"
+	"openquiz/metrics"
 	"openquiz/middleware"
 	"openquiz/models"
 	"openquiz/routes"
 	"openquiz/services"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,10 +33,22 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Structured logging; packages without an injected logger use the default
+	logger := config.InitLogger(cfg)
+	slog.SetDefault(logger)
+
 	// Initialize database
 	db, err := config.InitDB(cfg)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logger.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+
+	// Answers recorded twice before the one-answer index existed would stop
+	// the migration from creating it
+	if err := models.RemoveDuplicateAnswers(db); err != nil {
+		logger.Error("Failed to remove duplicate answers", "error", err)
+		os.Exit(1)
 	}
 
 	// Auto-migrate database models
@@ -42,43 +62,103 @@ func main() {
 		&models.GameAnswer{},
 	)
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		logger.Error("Failed to migrate database", "error", err)
+		os.Exit(1)
 	}
 
 	// Initialize Redis
-	redisClient := config.InitRedis(cfg)
+	redisClient, err := config.InitRedis(cfg)
+	if err != nil {
+		logger.Error("Failed to connect to Redis", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize services
-	authService := services.NewAuthService(db, cfg.JWTSecret)
-	quizService := services.NewQuizService(db)
-	gameService := services.NewGameService(db, redisClient)
+	authService := services.NewAuthService(db, redisClient, logger, cfg.JWTSecret, cfg.JWTIssuer,
+		time.Duration(cfg.JWTExpiryMinutes)*time.Minute, cfg.RedisKeyPrefix, cfg.LoginMaxAttempts, time.Duration(cfg.LoginLockoutSeconds)*time.Second)
+	quizService := services.NewQuizService(db, cfg.MaxQuizzesPerUser, cfg.MaxQuestionsPerQuiz)
+	gameService := services.NewGameService(db, redisClient, logger, cfg.JWTSecret, cfg.RedisKeyPrefix,
+		time.Duration(cfg.GameMaxDurationMinutes)*time.Minute, time.Duration(cfg.GameStateTTLMinutes)*time.Minute,
+		cfg.PinAlphabet, cfg.PinLength, time.Duration(cfg.FinishedGameGraceSeconds)*time.Second)
 
 	// Initialize WebSocket hub
-	hub := services.NewHub(gameService)
+	hub := services.NewHub(gameService, logger, cfg.WSMessageRateLimit, cfg.WSMaxMessageSize,
+		time.Duration(cfg.HostReconnectGraceSeconds)*time.Second)
 	go hub.Run()
 
+	// Games that were running when the server last stopped carry on
+	if resumed := gameService.ResumeActiveGames(context.Background(), hub); resumed > 0 {
+		logger.Info("Resumed active games", "count", resumed)
+	}
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	quizHandler := handlers.NewQuizHandler(quizService)
-	gameHandler := handlers.NewGameHandler(gameService, hub)
+	adminHandler := handlers.NewAdminHandler(authService, quizService, hub)
+	gameHandler := handlers.NewGameHandler(gameService, authService, hub, cfg.PublicBaseURL)
 
 	// Setup Gin router
 	router := gin.Default()
 
 	// Add CORS middleware
-	router.Use(middleware.CORS())
+	router.Use(middleware.CORS(cfg.AllowedOrigins))
+
+	// Prometheus metrics, recorded for every route registered after this
+	if cfg.MetricsEnabled {
+		router.Use(metrics.Middleware())
+		router.GET("/metrics", metrics.Handler())
+	}
 
 	// Setup routes
-	routes.SetupRoutes(router, authHandler, quizHandler, gameHandler, hub, gameService, cfg.JWTSecret)
+	routes.SetupRoutes(router, authHandler, authService, quizHandler, adminHandler, gameHandler, hub, gameService, db, redisClient, cfg.JWTSecret, cfg.JWTIssuer, cfg.AllowedOrigins,
+		time.Duration(cfg.RequestTimeoutSeconds)*time.Second)
 
-	// Start server
-	log.Printf("Server starting on port %s", cfg.Port)
+	// Use config to control binding address; an empty address listens on all interfaces
+	serverAddr := net.JoinHostPort(cfg.BindAddress, cfg.Port)
+	listener, err := net.Listen("tcp", serverAddr)
+	if err != nil {
+		logger.Error("Failed to listen", "address", serverAddr, "error", err)
+		os.Exit(1)
+	}
 
-	// Use config to control binding address
-	serverAddr := cfg.BindAddress + ":" + cfg.Port
+	logger.Info("Server starting", "address", listener.Addr().String())
+	server := &http.Server{
+		Handler: router,
+	}
 
-	log.Printf("Server binding to %s", serverAddr)
-	if err := router.Run(serverAddr); err != nil {
-		log.Fatal("Failed to start server:", err)
+	// Stop the HTTP server and the hub together on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Failed to start server", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // a second signal kills the process without waiting for the drain
+
+	shutdownTimeout := time.Duration(cfg.ShutdownTimeoutSeconds) * time.Second
+	logger.Info("Shutdown signal received, draining", "timeout", shutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and let in-flight API requests finish;
+	// WebSocket connections are hijacked, so the hub closes those next
+	logger.Info("Draining HTTP server")
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Error shutting down server", "error", err)
+	} else {
+		logger.Info("HTTP server drained")
 	}
+
+	logger.Info("Draining WebSocket hub")
+	if err := hub.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Error shutting down hub", "error", err)
+	}
+
+	logger.Info("Shutdown complete")
 }
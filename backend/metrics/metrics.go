@@ -0,0 +1,62 @@
+// Package metrics defines the server's operational metrics and serves them
+// for Prometheus to scrape.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	ActiveGames = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "openquiz_active_games",
+		Help: "Games currently in progress.",
+	})
+	ConnectedClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "openquiz_connected_clients",
+		Help: "WebSocket clients connected to the hub.",
+	})
+	AnswersSubmitted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "openquiz_answers_submitted_total",
+		Help: "Answers submitted by players.",
+	})
+	GamesStarted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "openquiz_games_started_total",
+		Help: "Games started.",
+	})
+	GamesFinished = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "openquiz_games_finished_total",
+		Help: "Games finished.",
+	})
+
+	requestDurations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "openquiz_http_request_duration_seconds",
+		Help:    "HTTP request latencies by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Middleware records the duration of every request under its route pattern
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		requestDurations.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves all registered metrics in the Prometheus exposition format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
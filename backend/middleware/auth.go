@@ -1,54 +1,173 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
+
+	"openquiz/handlers"
+	"openquiz/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// TokenRevocationChecker reports whether an access token has been revoked
+type TokenRevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, tokenID string, userID uint, issuedAt time.Time) (bool, error)
+}
+
+// AdminMiddleware only lets admins through; it must run after AuthMiddleware
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != models.RoleAdmin {
+			handlers.AbortWithError(c, http.StatusForbidden, "Admin access required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// ValidateAccessToken checks an access token passed outside the Authorization
+// header, such as on a WebSocket upgrade where browsers can't set headers, and
+// returns the user it was issued to
+func ValidateAccessToken(ctx context.Context, tokenString string, jwtSecret string, jwtIssuer string, revocations TokenRevocationChecker) (uint, error) {
+	token, err := parseAccessToken(tokenString, jwtSecret, jwtIssuer)
+	if err != nil || !token.Valid {
+		return 0, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, errors.New("invalid token claims")
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, errors.New("invalid user ID in token")
+	}
+
+	tokenID, _ := claims["jti"].(string)
+	var issuedAt time.Time
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		issuedAt = iat.Time
+	}
+	revoked, err := revocations.IsTokenRevoked(ctx, tokenID, uint(userID), issuedAt)
+	if err != nil {
+		return 0, err
+	}
+	if revoked {
+		return 0, errors.New("token has been revoked")
+	}
+
+	return uint(userID), nil
+}
+
+// parseAccessToken verifies an access token's signature, expiry and issuer;
+// tokens without an expiry or from another issuer are rejected
+func parseAccessToken(tokenString string, jwtSecret string, jwtIssuer string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithIssuer(jwtIssuer), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+}
+
+// authRealm names the protection space in WWW-Authenticate challenges
+const authRealm = "openquiz"
+
+// abortUnauthorized rejects a request with a Bearer challenge (RFC 6750). An
+// empty errorCode means no credentials were sent, so clients should log in;
+// "invalid_token" tells them the token was refused and may be refreshed.
+func abortUnauthorized(c *gin.Context, errorCode string, message string) {
+	challenge := `Bearer realm="` + authRealm + `"`
+	if errorCode != "" {
+		challenge += `, error="` + errorCode + `", error_description="` + message + `"`
+	}
+	c.Header("WWW-Authenticate", challenge)
+	handlers.AbortWithError(c, http.StatusUnauthorized, message)
+}
+
+// tokenErrorMessage tells an expired token apart from one that isn't a JWT at
+// all and one that fails verification
+func tokenErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "Token has expired"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "Malformed token"
+	default:
+		return "Invalid token"
+	}
+}
+
+func AuthMiddleware(jwtSecret string, jwtIssuer string, revocations TokenRevocationChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			abortUnauthorized(c, "", "Authorization header required")
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
-			c.Abort()
+			abortUnauthorized(c, "invalid_request", "Bearer token required")
+			return
+		}
+		if strings.TrimSpace(tokenString) == "" {
+			abortUnauthorized(c, "", "Bearer token required")
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
-
+		token, err := parseAccessToken(tokenString, jwtSecret, jwtIssuer)
 		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			abortUnauthorized(c, "invalid_token", tokenErrorMessage(err))
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
+			abortUnauthorized(c, "invalid_token", "Invalid token claims")
 			return
 		}
 
 		userID, ok := claims["user_id"].(float64)
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
-			c.Abort()
+			abortUnauthorized(c, "invalid_token", "Invalid user ID in token")
+			return
+		}
+
+		// Reject tokens revoked by logging out or changing password
+		tokenID, _ := claims["jti"].(string)
+		var issuedAt time.Time
+		if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+			issuedAt = iat.Time
+		}
+		revoked, err := revocations.IsTokenRevoked(c.Request.Context(), tokenID, uint(userID), issuedAt)
+		if err != nil {
+			slog.Error("Error checking token revocation", "error", err)
+			handlers.AbortWithError(c, http.StatusServiceUnavailable, "Unable to verify token")
+			return
+		}
+		if revoked {
+			abortUnauthorized(c, "invalid_token", "Token has been revoked")
 			return
 		}
 
+		role, _ := claims["role"].(string)
+		if role == "" {
+			role = models.RoleUser
+		}
+
 		c.Set("user_id", uint(userID))
+		c.Set("role", role)
+		c.Set("token_id", tokenID)
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.Set("token_expires_at", exp.Time)
+		} else {
+			c.Set("token_expires_at", time.Time{})
+		}
 		c.Next()
 	}
 }
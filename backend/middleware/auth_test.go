@@ -0,0 +1,299 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"openquiz/handlers"
+	"openquiz/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	testJWTSecret = "test-secret"
+	testJWTIssuer = "openquiz-test"
+)
+
+// noRevocations treats every token as live
+type noRevocations struct{}
+
+func (noRevocations) IsTokenRevoked(ctx context.Context, tokenID string, userID uint, issuedAt time.Time) (bool, error) {
+	return false, nil
+}
+
+// signTestToken signs an access token for user 7 that expires at expiresAt
+func signTestToken(t *testing.T, expiresAt time.Time) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 7,
+		"role":    "user",
+		"jti":     fmt.Sprintf("test-token-%d", time.Now().UnixNano()),
+		"iss":     testJWTIssuer,
+		"iat":     time.Now().Add(-2 * time.Hour).Unix(),
+		"exp":     expiresAt.Unix(),
+	})
+	signed, err := token.SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func TestAuthMiddlewareExplainsRejectedTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/quizzes", AuthMiddleware(testJWTSecret, testJWTIssuer, noRevocations{}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("user_id")})
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantMessage   string
+		wantChallenge string
+	}{
+		{
+			name:          "missing token",
+			wantStatus:    http.StatusUnauthorized,
+			wantMessage:   "Authorization header required",
+			wantChallenge: `Bearer realm="openquiz"`,
+		},
+		{
+			name:          "malformed token",
+			authorization: "Bearer not.a-jwt",
+			wantStatus:    http.StatusUnauthorized,
+			wantMessage:   "Malformed token",
+			wantChallenge: `Bearer realm="openquiz", error="invalid_token", error_description="Malformed token"`,
+		},
+		{
+			name:          "expired token",
+			authorization: "Bearer " + signTestToken(t, time.Now().Add(-time.Minute)),
+			wantStatus:    http.StatusUnauthorized,
+			wantMessage:   "Token has expired",
+			wantChallenge: `Bearer realm="openquiz", error="invalid_token", error_description="Token has expired"`,
+		},
+		{
+			name:          "valid token",
+			authorization: "Bearer " + signTestToken(t, time.Now().Add(time.Hour)),
+			wantStatus:    http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/quizzes", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.wantChallenge)
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+
+			var body handlers.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body %q: %v", w.Body.String(), err)
+			}
+			if body.Code != handlers.ErrCodeUnauthorized || body.Message != tt.wantMessage {
+				t.Errorf("body = %+v, want %s %q", body, handlers.ErrCodeUnauthorized, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareRejectsTokensFromAnotherIssuer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/quizzes", AuthMiddleware(testJWTSecret, testJWTIssuer, noRevocations{}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	sign := func(claims jwt.MapClaims) string {
+		t.Helper()
+		claims["user_id"] = 7
+		claims["iat"] = time.Now().Unix()
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return signed
+	}
+	expiresAt := time.Now().Add(time.Hour).Unix()
+
+	for name, token := range map[string]string{
+		"wrong issuer":   sign(jwt.MapClaims{"iss": "someone-else", "exp": expiresAt}),
+		"missing issuer": sign(jwt.MapClaims{"exp": expiresAt}),
+		"missing expiry": sign(jwt.MapClaims{"iss": testJWTIssuer}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/quizzes", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var body handlers.ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if w.Code != http.StatusUnauthorized || body.Message != "Invalid token" {
+				t.Errorf("got %d %+v, want 401 Invalid token", w.Code, body)
+			}
+		})
+	}
+}
+
+func TestTokenErrorMessage(t *testing.T) {
+	_, err := parseAccessToken(signTestToken(t, time.Now().Add(time.Hour)), "other-secret", testJWTIssuer)
+	if got := tokenErrorMessage(err); got != "Invalid token" {
+		t.Errorf("bad signature: got %q, want Invalid token", got)
+	}
+	if got := tokenErrorMessage(jwt.ErrTokenMalformed); got != "Malformed token" {
+		t.Errorf("malformed: got %q, want Malformed token", got)
+	}
+}
+
+// testAuthService builds an auth service on the Redis named by TEST_REDIS_URL,
+// skipping the test when it isn't set. Its keys are deleted afterwards.
+func testAuthService(t *testing.T) *services.AuthService {
+	t.Helper()
+
+	url := os.Getenv("TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatalf("parse TEST_REDIS_URL: %v", err)
+	}
+	client := redis.NewClient(opts)
+	prefix := fmt.Sprintf("test:%s:%d:", t.Name(), time.Now().UnixNano())
+	t.Cleanup(func() {
+		ctx := context.Background()
+		iter := client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			client.Del(ctx, iter.Val())
+		}
+		client.Close()
+	})
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return services.NewAuthService(nil, client, logger, testJWTSecret, testJWTIssuer, time.Hour, prefix, 0, 0)
+}
+
+func TestLoggedOutTokenIsRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authService := testAuthService(t)
+	router := gin.New()
+	protected := router.Group("/api", AuthMiddleware(testJWTSecret, testJWTIssuer, authService))
+	protected.GET("/quizzes", func(c *gin.Context) { c.Status(http.StatusOK) })
+	protected.POST("/auth/logout", handlers.NewAuthHandler(authService).Logout)
+
+	token := signTestToken(t, time.Now().Add(time.Hour))
+	other := signTestToken(t, time.Now().Add(time.Hour))
+	request := func(method string, path string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request(http.MethodGet, "/api/quizzes", token); w.Code != http.StatusOK {
+		t.Fatalf("before logout: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := request(http.MethodPost, "/api/auth/logout", token); w.Code != http.StatusOK {
+		t.Fatalf("logout: status = %d, want %d (body %s)", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	w := request(http.MethodGet, "/api/quizzes", token)
+	var body handlers.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusUnauthorized || body.Message != "Token has been revoked" {
+		t.Errorf("after logout: got %d %+v, want 401 Token has been revoked", w.Code, body)
+	}
+
+	// Other sessions of the same user carry on
+	if w := request(http.MethodGet, "/api/quizzes", other); w.Code != http.StatusOK {
+		t.Errorf("another token after logout: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestAdminMiddlewareOnlyLetsAdminsThrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for role, wantStatus := range map[string]int{
+		"admin": http.StatusOK,
+		"user":  http.StatusForbidden,
+		"":      http.StatusForbidden,
+	} {
+		t.Run("role "+role, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/api/admin/users", func(c *gin.Context) {
+				if role != "" {
+					c.Set("role", role)
+				}
+			}, AdminMiddleware(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/users", nil))
+			if w.Code != wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, wantStatus)
+			}
+			if wantStatus == http.StatusForbidden {
+				var body handlers.ErrorResponse
+				json.Unmarshal(w.Body.Bytes(), &body)
+				if body.Code != handlers.ErrCodeForbidden {
+					t.Errorf("body = %s, want a forbidden error", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareSetsTheRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var role string
+	router.GET("/", AuthMiddleware(testJWTSecret, testJWTIssuer, noRevocations{}), func(c *gin.Context) {
+		role = c.GetString("role")
+	})
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 1,
+		"iss":     testJWTIssuer,
+		"iat":     time.Now().Unix(),
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Tokens minted before roles existed belong to ordinary users
+	if role != "user" {
+		t.Errorf("role = %q for a token without one, want user", role)
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// slowQuery stands in for a database call that takes d unless its context
+// ends first
+func slowQuery(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRequestTimeoutCancelsASlowQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var queryErr error
+	router.GET("/api/quizzes", RequestTimeout(50*time.Millisecond), func(c *gin.Context) {
+		queryErr = slowQuery(c.Request.Context(), 5*time.Second)
+		c.Status(http.StatusOK)
+	})
+
+	started := time.Now()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/quizzes", nil))
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("request took %v, want it cut off near the 50ms deadline", elapsed)
+	}
+	if !errors.Is(queryErr, context.DeadlineExceeded) {
+		t.Errorf("query error = %v, want context.DeadlineExceeded", queryErr)
+	}
+}
+
+func TestRequestTimeoutEndsWhenTheClientGoesAway(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var queryErr error
+	router.GET("/api/quizzes", RequestTimeout(time.Minute), func(c *gin.Context) {
+		queryErr = slowQuery(c.Request.Context(), 5*time.Second)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	req := httptest.NewRequest(http.MethodGet, "/api/quizzes", nil).WithContext(ctx)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if !errors.Is(queryErr, context.Canceled) {
+		t.Errorf("query error = %v, want context.Canceled", queryErr)
+	}
+}
+
+func TestZeroRequestTimeoutSetsNoDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	hasDeadline := true
+	router.GET("/api/quizzes", RequestTimeout(0), func(c *gin.Context) {
+		_, hasDeadline = c.Request.Context().Deadline()
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/quizzes", nil))
+	if hasDeadline {
+		t.Error("a zero timeout put a deadline on the request")
+	}
+}
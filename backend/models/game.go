@@ -7,15 +7,35 @@ import (
 )
 
 type Game struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	QuizID    uint           `json:"quiz_id" gorm:"not null"`
-	Pin       string         `json:"pin" gorm:"uniqueIndex;not null"`
-	Status    string         `json:"status" gorm:"not null;default:'waiting'"` // waiting, active, finished
-	StartedAt *time.Time     `json:"started_at"`
-	EndedAt   *time.Time     `json:"ended_at"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	QuizID       uint           `json:"quiz_id" gorm:"not null"`
+	Pin          string         `json:"pin" gorm:"uniqueIndex;not null"`
+	Status       string         `json:"status" gorm:"not null;default:'waiting'"` // waiting, active, finished
+	ScheduledAt  *time.Time     `json:"scheduled_at"`
+	TrainingMode bool           `json:"training_mode" gorm:"not null;default:false"` // reveal correctness live
+	MaxDuration  int            `json:"max_duration" gorm:"not null;default:0"`      // minutes once started, 0 uses the server ceiling
+	StartedAt    *time.Time     `json:"started_at"`
+	EndedAt      *time.Time     `json:"ended_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Players needed to start the game without the host, 0 for manual start only
+	AutoStartPlayers int `json:"auto_start_players" gorm:"not null;default:0"`
+	// Questions are played in an order shuffled once per game
+	ShuffleQuestions bool `json:"shuffle_questions" gorm:"not null;default:false"`
+	// Each player sees a question's options in their own order
+	ShuffleOptions bool `json:"shuffle_options" gorm:"not null;default:false"`
+	// Players who must join before the game can start, 0 for no minimum
+	MinPlayers int `json:"min_players" gorm:"not null;default:0"`
+	// Broadcast the standings after each question, not only at the end
+	ShowLeaderboard bool `json:"show_leaderboard" gorm:"not null;default:false"`
+	// End a question once every connected player has answered and move on
+	AutoAdvance bool `json:"auto_advance" gorm:"not null;default:false"`
+	// Each device may join only once; joining again returns its player
+	OneJoinPerDevice bool `json:"one_join_per_device" gorm:"not null;default:false"`
+	// The host's question_start marks the correct options; players never get them
+	ShowAnswersToHost bool `json:"show_answers_to_host" gorm:"not null;default:false"`
 
 	// Relationships
 	Quiz    Quiz         `json:"quiz,omitempty"`
@@ -6,14 +6,17 @@ import (
 	"gorm.io/gorm"
 )
 
+// One answer per player per question; the unique index makes concurrent
+// submissions from the same player fail instead of scoring twice
 type GameAnswer struct {
 	ID         uint           `json:"id" gorm:"primaryKey"`
-	GameID     uint           `json:"game_id" gorm:"not null"`
-	PlayerID   uint           `json:"player_id" gorm:"not null"`
-	QuestionID uint           `json:"question_id" gorm:"not null"`
+	GameID     uint           `json:"game_id" gorm:"not null;uniqueIndex:idx_game_answers_player_question"`
+	PlayerID   uint           `json:"player_id" gorm:"not null;uniqueIndex:idx_game_answers_player_question"`
+	QuestionID uint           `json:"question_id" gorm:"not null;uniqueIndex:idx_game_answers_player_question"`
 	OptionID   uint           `json:"option_id" gorm:"not null"`
 	IsCorrect  bool           `json:"is_correct" gorm:"not null"`
-	TimeSpent  int            `json:"time_spent" gorm:"not null"` // seconds
+	TimeSpent  int            `json:"time_spent" gorm:"not null"`           // seconds
+	Confidence int            `json:"confidence" gorm:"not null;default:0"` // 1-3, 0 if not given
 	Points     int            `json:"points" gorm:"not null"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
@@ -25,3 +28,20 @@ type GameAnswer struct {
 	Question Question `json:"question,omitempty"`
 	Option   Option   `json:"option,omitempty"`
 }
+
+// answerIndex is the unique index that allows one answer per player per question
+const answerIndex = "idx_game_answers_player_question"
+
+// RemoveDuplicateAnswers deletes all but the earliest answer per player per
+// question, so the unique index can be created on a database that recorded
+// duplicates before it existed. It does nothing once the index is in place.
+func RemoveDuplicateAnswers(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&GameAnswer{}) || migrator.HasIndex(&GameAnswer{}, answerIndex) {
+		return nil
+	}
+
+	return db.Exec(`DELETE FROM game_answers a USING game_answers b
+		WHERE a.game_id = b.game_id AND a.player_id = b.player_id AND a.question_id = b.question_id
+		AND (a.created_at, a.id) > (b.created_at, b.id)`).Error
+}
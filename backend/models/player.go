@@ -16,6 +16,11 @@ type Player struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// Client-generated ID of the device that joined, for games allowing one join per device
+	DeviceID string `json:"-" gorm:"index"`
+	// Guest identity of a player without an account, to list their past games
+	GuestID string `json:"-" gorm:"index"`
+
 	// Relationships
 	Game Game `json:"game,omitempty"`
 }
@@ -6,16 +6,25 @@ import (
 	"gorm.io/gorm"
 )
 
+// Question types; polls have no correct option and collect votes instead
+const (
+	QuestionTypeMultipleChoice = "multiple_choice"
+	QuestionTypePoll           = "poll"
+)
+
 type Question struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	QuizID    uint           `json:"quiz_id" gorm:"not null"`
 	Text      string         `json:"text" gorm:"not null"`
 	TimeLimit int            `json:"time_limit" gorm:"not null;default:30"` // seconds
-	Order     int            `json:"order" gorm:"not null"`
+	Order     int            `json:"order" gorm:"not null"`                 // 0-based position, normalized on save
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// QuestionTypeMultipleChoice or QuestionTypePoll
+	Type string `json:"type" gorm:"not null;default:multiple_choice"`
+
 	// Relationships
 	Quiz    Quiz     `json:"quiz,omitempty"`
 	Options []Option `json:"options,omitempty" gorm:"foreignKey:QuestionID"`
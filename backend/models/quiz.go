@@ -6,17 +6,43 @@ import (
 	"gorm.io/gorm"
 )
 
+// GradeBand awards Label to players scoring at least MinPercent of the maximum
+// possible score, up to the next band's threshold
+type GradeBand struct {
+	MinPercent float64 `json:"min_percent"`
+	Label      string  `json:"label"`
+}
+
 type Quiz struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	Title       string         `json:"title" gorm:"not null"`
-	Description string         `json:"description"`
-	UserID      uint           `json:"user_id" gorm:"not null"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	Title             string         `json:"title" gorm:"not null"`
+	Description       string         `json:"description"`
+	UserID            uint           `json:"user_id" gorm:"not null"`
+	ConfidenceScoring bool           `json:"confidence_scoring" gorm:"not null;default:false"` // weight points by answer confidence
+	FixedOptionCount  int            `json:"fixed_option_count" gorm:"not null;default:0"`     // options every question must have, 0 for any 2-6
+	GradeRubric       []GradeBand    `json:"grade_rubric,omitempty" gorm:"type:text;serializer:json"`
+	BasePoints        *int           `json:"base_points,omitempty"`    // points for a correct answer, nil for 100
+	MaxTimeBonus      *int           `json:"max_time_bonus,omitempty"` // extra points for an instant answer, nil for 50
+	SpeedBonus        *bool          `json:"speed_bonus,omitempty"`    // whether answering faster earns the time bonus, nil for true
+	PenaltyPoints     *int           `json:"penalty_points,omitempty"` // deducted for a wrong answer, nil for none
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Keep penalties from taking a score below 0
+	FloorScoreAtZero bool `json:"floor_score_at_zero" gorm:"not null;default:false"`
+
+	// Time limit in seconds for questions created without one, nil for 30
+	DefaultTimeLimit *int `json:"default_time_limit,omitempty"`
+	// Seconds each question's results are shown before the game can move on, nil for 5
+	RevealSeconds *int `json:"reveal_seconds,omitempty"`
+	// Seconds counted down before each question appears, nil for 3
+	QuestionCountdown *int `json:"question_countdown,omitempty"`
+	// Extra points for the fastest correct answer to each question, nil for none
+	FastestBonus *int `json:"fastest_bonus,omitempty"`
 
 	// Relationships
-	User      User       `json:"user,omitempty"`
+	User      *User      `json:"user,omitempty"` // the author, only when preloaded
 	Questions []Question `json:"questions,omitempty" gorm:"foreignKey:QuizID"`
 	Games     []Game     `json:"games,omitempty" gorm:"foreignKey:QuizID"`
 }
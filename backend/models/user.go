@@ -6,11 +6,18 @@ import (
 	"gorm.io/gorm"
 )
 
+// User roles; admins can see and moderate every user's content
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	Username  string         `json:"username" gorm:"uniqueIndex;not null"`
 	Email     string         `json:"email" gorm:"uniqueIndex;not null"`
-	Password  string         `json:"-" gorm:"not null"`
+	Password  string         `json:"-" gorm:"not null"` // bcrypt hash; never serialized
+	Role      string         `json:"role" gorm:"not null;default:user"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -18,3 +25,10 @@ type User struct {
 	// Relationships
 	Quizzes []Quiz `json:"quizzes,omitempty" gorm:"foreignKey:UserID"`
 }
+
+// Sanitized returns a copy of the user with the password hash cleared, for
+// handing to code that builds responses
+func (u User) Sanitized() User {
+	u.Password = ""
+	return u
+}
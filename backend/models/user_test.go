@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUserPasswordIsNeverSerialized(t *testing.T) {
+	const hash = "$2a$10$abcdefghijklmnopqrstuv"
+	user := User{ID: 1, Username: "ada", Email: "ada@example.com", Password: hash, Role: RoleUser}
+
+	for name, value := range map[string]interface{}{
+		"user":                    user,
+		"quiz with its author":    Quiz{ID: 1, Title: "Quiz", UserID: 1, User: &user},
+		"user with their quizzes": User{ID: 1, Password: hash, Quizzes: []Quiz{{ID: 1, User: &user}}},
+	} {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", name, err)
+		}
+		if strings.Contains(string(data), hash) || strings.Contains(string(data), `"password"`) {
+			t.Errorf("%s: JSON exposes the password: %s", name, data)
+		}
+	}
+
+	if sanitized := user.Sanitized(); sanitized.Password != "" || sanitized.Email != user.Email {
+		t.Errorf("Sanitized() = %+v, want the user without its password", sanitized)
+	}
+	if user.Password != hash {
+		t.Error("Sanitized() cleared the original user's password")
+	}
+}
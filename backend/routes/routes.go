@@ -1,10 +1,13 @@
 package routes
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
 	"openquiz/handlers"
 	"openquiz/middleware"
@@ -12,56 +15,99 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
-}
+// readinessTimeout bounds each dependency ping in the readiness check
+const readinessTimeout = 2 * time.Second
 
 func SetupRoutes(
 	router *gin.Engine,
 	authHandler *handlers.AuthHandler,
+	authService *services.AuthService,
 	quizHandler *handlers.QuizHandler,
+	adminHandler *handlers.AdminHandler,
 	gameHandler *handlers.GameHandler,
 	hub *services.Hub,
 	gameService *services.GameService,
+	db *gorm.DB,
+	redisClient *redis.Client,
 	jwtSecret string,
+	jwtIssuer string,
+	allowedOrigins []string,
+	requestTimeout time.Duration,
 ) {
+	// WebSocket upgrades accept the same origins as CORS (all when none are configured)
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return middleware.IsOriginAllowed(r.Header.Get("Origin"), allowedOrigins)
+		},
+	}
+
 	// API routes
+	// WebSocket connections are long-lived, so only API requests are bounded
 	api := router.Group("/api")
+	api.Use(middleware.RequestTimeout(requestTimeout))
 	{
 		// Auth routes (public)
 		auth := api.Group("/auth")
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/refresh", authHandler.Refresh)
 		}
 
 		// Protected routes
 		protected := api.Group("/")
-		protected.Use(middleware.AuthMiddleware(jwtSecret))
+		protected.Use(middleware.AuthMiddleware(jwtSecret, jwtIssuer, authService))
 		{
 			// User profile
 			protected.GET("/auth/profile", authHandler.GetProfile)
+			protected.POST("/auth/logout", authHandler.Logout)
+			protected.POST("/auth/change-password", authHandler.ChangePassword)
 
 			// Quiz routes
 			quizzes := protected.Group("/quizzes")
 			{
 				quizzes.GET("", quizHandler.GetUserQuizzes)
 				quizzes.POST("", quizHandler.CreateQuiz)
+				quizzes.POST("/batch", quizHandler.CreateQuizzes)
+				quizzes.GET("/trash", quizHandler.GetDeletedQuizzes)
 				quizzes.GET("/:id", quizHandler.GetQuizByID)
+				quizzes.GET("/:id/preview", quizHandler.PreviewQuiz)
 				quizzes.PUT("/:id", quizHandler.UpdateQuiz)
+				quizzes.PATCH("/:id/time-limits", quizHandler.UpdateTimeLimits)
+				quizzes.PUT("/:id/questions/reorder", quizHandler.ReorderQuestions)
+				quizzes.PUT("/:id/questions/:qid/options/reorder", quizHandler.ReorderOptions)
 				quizzes.DELETE("/:id", quizHandler.DeleteQuiz)
+				quizzes.POST("/:id/restore", quizHandler.RestoreQuiz)
+				quizzes.DELETE("/:id/permanent", quizHandler.PermanentlyDeleteQuiz)
 			}
 
 			// Game routes
 			games := protected.Group("/games")
 			{
+				games.GET("", gameHandler.GetUserGames)
+				games.GET("/active", gameHandler.GetActiveGames)
 				games.POST("", gameHandler.StartGame)
+				games.POST("/prepare", gameHandler.PrepareGame)
 				games.POST("/:pin/start", gameHandler.StartQuiz)
 				games.POST("/:pin/next", gameHandler.NextQuestion)
+				games.POST("/:pin/end", gameHandler.EndGame)
+				games.DELETE("/:pin", gameHandler.CancelGame)
+				games.GET("/:pin/results", gameHandler.GetGameResults)
+				games.GET("/:pin/players/:playerID/answers", gameHandler.GetPlayerAnswers)
+			}
+
+			// Admin routes, not scoped to the requesting user
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminMiddleware())
+			{
+				admin.GET("/users", adminHandler.ListUsers)
+				admin.GET("/quizzes", adminHandler.ListQuizzes)
+				admin.DELETE("/quizzes/:id", adminHandler.DeleteQuiz)
+				admin.GET("/hub/stats", adminHandler.HubStats)
 			}
 		}
 
@@ -70,91 +116,206 @@ func SetupRoutes(
 		{
 			games.POST("/:pin/join", gameHandler.JoinGame)
 			games.GET("/:pin", gameHandler.GetGameByPin)
+			games.GET("/:pin/exists", gameHandler.GameExists)
+			games.GET("/:pin/timer", gameHandler.GetQuestionTimer)
 			games.POST("/:pin/answer", gameHandler.SubmitAnswer)
 		}
+
+		// History for players who join without an account
+		api.GET("/guest/games", gameHandler.GetGuestGames)
 	}
 
 	// WebSocket endpoint for real-time game communication
 	router.GET("/ws/:gamePin/:playerID", func(c *gin.Context) {
-		gamePin := strings.ToLower(c.Param("gamePin")) // Normalize game pin to lowercase
+		gamePin, err := services.NormalizePin(c.Param("gamePin"))
+		if err != nil {
+			handlers.RespondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
 		playerIDStr := c.Param("playerID")
 		playerName := c.Query("playerName") // Get player name from query parameter
 
-		log.Printf("WebSocket connection attempt - Game: %s, PlayerID: %s, PlayerName: %s", gamePin, playerIDStr, playerName)
+		slog.Debug("WebSocket connection attempt", "game_pin", gamePin, "player_id", playerIDStr, "player_name", playerName)
 
 		// Parse player ID (can be either user ID for host or player ID for players)
 		var playerID uint
-		log.Printf("Attempting to parse player ID string '%s' to uint for game %s", playerIDStr, gamePin)
 		if _, err := fmt.Sscanf(playerIDStr, "%d", &playerID); err != nil {
-			log.Printf("Failed to parse player ID '%s' for game %s: %v", playerIDStr, gamePin, err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid player ID"})
+			slog.Debug("Invalid player ID", "game_pin", gamePin, "player_id", playerIDStr, "error", err)
+			handlers.RespondError(c, http.StatusBadRequest, "Invalid player ID")
 			return
 		}
-		log.Printf("Successfully parsed player ID: %s -> %d (uint) for game %s", playerIDStr, playerID, gamePin)
 
-		// Validate that the player exists in the game
+		// A reconnect token re-associates a returning player with their identity;
+		// otherwise hosts present their access token and players the socket
+		// token issued when they joined, checked before the upgrade
 		// This prevents unauthorized access to game WebSocket
-		if err := validatePlayerAccess(gameService, gamePin, playerID); err != nil {
-			log.Printf("Player access validation failed for game %s, player %d: %v", gamePin, playerID, err)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Player not found in game"})
+		resumed := false
+		role := services.RolePlayer
+		if c.Query("role") == services.RoleSpectator {
+			// Spectators watch without a player row and can't answer; they
+			// only need the game to exist
+			if _, err := gameService.GetGameByPin(c.Request.Context(), gamePin); err != nil {
+				slog.Info("Spectator access validation failed", "game_pin", gamePin, "error", err)
+				handlers.RespondError(c, http.StatusNotFound, "Game not found")
+				return
+			}
+			role = services.RoleSpectator
+			playerID = 0
+			if playerName == "" {
+				playerName = "Spectator"
+			}
+		} else if reconnectToken := c.Query("reconnect_token"); reconnectToken != "" {
+			tokenPlayerID, err := gameService.ValidateReconnectToken(gamePin, reconnectToken)
+			if err != nil || tokenPlayerID != playerID {
+				slog.Info("Reconnect token rejected", "game_pin", gamePin, "player_id", playerID, "error", err)
+				handlers.RespondError(c, http.StatusUnauthorized, "Invalid reconnect token")
+				return
+			}
+			if err := validatePlayerAccess(c.Request.Context(), gameService, gamePin, playerID); err != nil {
+				slog.Info("Player access validation failed", "game_pin", gamePin, "player_id", playerID, "error", err)
+				handlers.RespondError(c, http.StatusUnauthorized, "Player not found in game")
+				return
+			}
+			resumed = true
+		} else if accessToken := c.Query("token"); accessToken != "" {
+			userID, err := middleware.ValidateAccessToken(c.Request.Context(), accessToken, jwtSecret, jwtIssuer, authService)
+			if err != nil || userID != playerID {
+				slog.Info("Host token rejected", "game_pin", gamePin, "player_id", playerID, "error", err)
+				handlers.RespondError(c, http.StatusUnauthorized, "Invalid token")
+				return
+			}
+			if err := validateHostAccess(c.Request.Context(), gameService, gamePin, userID); err != nil {
+				slog.Info("Host access validation failed", "game_pin", gamePin, "user_id", userID, "error", err)
+				handlers.RespondError(c, http.StatusForbidden, "Only the quiz owner can host this game")
+				return
+			}
+			role = services.RoleHost
+		} else if socketToken := c.Query("socket_token"); socketToken != "" {
+			tokenPlayerID, err := gameService.ValidateSocketToken(gamePin, socketToken)
+			if err != nil || tokenPlayerID != playerID {
+				slog.Info("Socket token rejected", "game_pin", gamePin, "player_id", playerID, "error", err)
+				handlers.RespondError(c, http.StatusUnauthorized, "Invalid socket token")
+				return
+			}
+			if err := validatePlayerAccess(c.Request.Context(), gameService, gamePin, playerID); err != nil {
+				slog.Info("Player access validation failed", "game_pin", gamePin, "player_id", playerID, "error", err)
+				handlers.RespondError(c, http.StatusUnauthorized, "Player not found in game")
+				return
+			}
+		} else {
+			slog.Info("Unauthenticated WebSocket connection rejected", "game_pin", gamePin, "player_id", playerID)
+			handlers.RespondError(c, http.StatusUnauthorized, "A token or socket_token is required")
 			return
 		}
 
 		// Upgrade HTTP connection to WebSocket
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
-			log.Printf("WebSocket upgrade failed for game %s, player %s: %v", gamePin, playerIDStr, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upgrade connection"})
+			slog.Warn("WebSocket upgrade failed", "game_pin", gamePin, "player_id", playerID, "error", err)
+			handlers.RespondError(c, http.StatusInternalServerError, "Failed to upgrade connection")
 			return
 		}
 
 		// If no player name provided, try to get it from the game service
 		if playerName == "" {
 			// Get player name from the game service
-			if player, err := gameService.GetPlayerByID(playerID); err == nil {
+			if player, err := gameService.GetPlayerByID(c.Request.Context(), playerID); err == nil {
 				playerName = player.Name
-				log.Printf("Retrieved player name '%s' for player %d in game %s", playerName, playerID, gamePin)
+				slog.Debug("Retrieved player name", "game_pin", gamePin, "player_id", playerID, "player_name", playerName)
 			} else {
 				playerName = "Unknown Player"
-				log.Printf("Could not retrieve player name for player %d in game %s, using default", playerName, playerID, gamePin)
+				slog.Debug("Could not retrieve player name, using default", "game_pin", gamePin, "player_id", playerID)
 			}
 		}
 
-		log.Printf("WebSocket connection established successfully for game %s, player %d (%s)", gamePin, playerID, playerName)
+		slog.Info("WebSocket connection established", "game_pin", gamePin, "player_id", playerID, "player_name", playerName)
 
 		// Register client with hub - this will handle all message processing
-		hub.RegisterClient(conn, gamePin, playerID, playerName)
+		client := hub.RegisterClient(conn, gamePin, playerID, playerName, role)
+
+		// A resuming player catches up on the current question and time left right away,
+		// plus any events broadcast after the last one they saw
+		if resumed && client != nil {
+			slog.Info("Player resumed connection", "game_pin", gamePin, "player_id", playerID)
+			if err := hub.SendGameStateSync(client, "", 0, nil); errors.Is(err, services.ErrGameNotFound) {
+				return
+			}
+
+			lastSeq, err := strconv.ParseInt(c.Query("last_seq"), 10, 64)
+			if err != nil {
+				lastSeq = -1 // client doesn't track sequence numbers
+			}
+			hub.ReplayMissedEvents(client, lastSeq)
+		}
 	})
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
+	// Health checks: liveness only shows the process is serving requests,
+	// readiness (also served at /health) checks Postgres and Redis
+	router.GET("/health/live", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
+	router.GET("/health/ready", readinessCheck(db, redisClient))
+	router.GET("/health", readinessCheck(db, redisClient))
 }
 
-// validatePlayerAccess checks if a player has access to a specific game
-func validatePlayerAccess(gameService *services.GameService, gamePin string, playerID uint) error {
-	// Normalize game pin to lowercase for consistent comparison
-	gamePin = strings.ToLower(gamePin)
+// readinessCheck pings each dependency and responds 503 with per-dependency
+// status when any of them is unreachable
+func readinessCheck(db *gorm.DB, redisClient *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		checks := gin.H{"database": "ok", "redis": "ok"}
+		ready := true
+
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.PingContext(ctx)
+		}
+		if err != nil {
+			slog.Warn("Readiness check: database unreachable", "error", err)
+			checks["database"] = "unreachable"
+			ready = false
+		}
+
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			slog.Warn("Readiness check: redis unreachable", "error", err)
+			checks["redis"] = "unreachable"
+			ready = false
+		}
+
+		if !ready {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+	}
+}
 
-	// First check if the game exists
-	game, err := gameService.GetGameByPin(gamePin)
+// validatePlayerAccess checks that a player is still in the game
+func validatePlayerAccess(ctx context.Context, gameService *services.GameService, gamePin string, playerID uint) error {
+	game, err := gameService.GetGameByPin(ctx, gamePin)
 	if err != nil {
 		return fmt.Errorf("game not found: %v", err)
 	}
 
-	// Check if the player exists in this game
 	for _, player := range game.Players {
 		if player.ID == playerID {
-			return nil // Player found in game
+			return nil
 		}
 	}
+	return fmt.Errorf("player %d not found in game %s", playerID, gamePin)
+}
 
-	// If player not found in game.Players, check if this might be the host (quiz creator)
-	// The host would have a user ID that matches the quiz creator's user_id
-	if game.Quiz.UserID == playerID {
-		return nil // Host found
+// validateHostAccess checks that the user owns the quiz the game is for
+func validateHostAccess(ctx context.Context, gameService *services.GameService, gamePin string, userID uint) error {
+	game, err := gameService.GetGameByPin(ctx, gamePin)
+	if err != nil {
+		return fmt.Errorf("game not found: %v", err)
 	}
 
-	return fmt.Errorf("player %d not found in game %s", playerID, gamePin)
+	if game.Quiz.UserID != userID {
+		return fmt.Errorf("user %d does not own the quiz for game %s", userID, gamePin)
+	}
+	return nil
 }
@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// closedAddr returns a local address nothing is listening on
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve a port: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// unreachableDependencies returns clients pointed at ports nothing listens on
+func unreachableDependencies(t *testing.T) (*gorm.DB, *redis.Client) {
+	t.Helper()
+
+	host, port, _ := net.SplitHostPort(closedAddr(t))
+	dsn := fmt.Sprintf("host=%s port=%s user=openquiz dbname=openquiz sslmode=disable connect_timeout=1", host, port)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database handle: %v", err)
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: closedAddr(t), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return db, client
+}
+
+func TestLivenessNeedsNoDependencies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/health/live", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestReadinessReportsUnreachableDependencies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, redisClient := unreachableDependencies(t)
+
+	router := gin.New()
+	router.GET("/health/ready", readinessCheck(db, redisClient))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	var body struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Status != "unavailable" {
+		t.Errorf("status = %q, want %q", body.Status, "unavailable")
+	}
+	for _, dep := range []string{"database", "redis"} {
+		if body.Checks[dep] != "unreachable" {
+			t.Errorf("checks[%q] = %q, want %q", dep, body.Checks[dep], "unreachable")
+		}
+	}
+}
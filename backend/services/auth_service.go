@@ -1,28 +1,78 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"openquiz/models"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+const (
+	refreshTokenTTL = 30 * 24 * time.Hour
+	guestTokenTTL   = 90 * 24 * time.Hour
+)
+
+// ErrInvalidGuestToken is returned for a guest token that is malformed,
+// tampered with or expired
+var ErrInvalidGuestToken = errors.New("invalid guest token")
+
 type AuthService struct {
-	db        *gorm.DB
-	jwtSecret string
+	db               *gorm.DB
+	redis            *redis.Client
+	logger           *slog.Logger
+	jwtSecret        string
+	jwtIssuer        string        // "iss" claim on access tokens
+	accessTokenTTL   time.Duration // access token lifetime
+	keyPrefix        string
+	loginMaxAttempts int           // failed logins per email and IP before lockout, 0 disables
+	loginLockout     time.Duration // window failures are counted over and lockout length
 }
 
-func NewAuthService(db *gorm.DB, jwtSecret string) *AuthService {
+func NewAuthService(db *gorm.DB, redis *redis.Client, logger *slog.Logger, jwtSecret string, jwtIssuer string, accessTokenTTL time.Duration, keyPrefix string, loginMaxAttempts int, loginLockout time.Duration) *AuthService {
 	return &AuthService{
-		db:        db,
-		jwtSecret: jwtSecret,
+		db:               db,
+		redis:            redis,
+		logger:           logger,
+		jwtSecret:        jwtSecret,
+		jwtIssuer:        jwtIssuer,
+		accessTokenTTL:   accessTokenTTL,
+		keyPrefix:        keyPrefix,
+		loginMaxAttempts: loginMaxAttempts,
+		loginLockout:     loginLockout,
 	}
 }
 
+// GuestIdentity lets a player without an account keep a display name and a
+// history of games between visits. It lives only in a signed token.
+type GuestIdentity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// LoginLockedError is returned while an email and IP pair is locked out after
+// too many failed logins
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return "too many failed login attempts, please try again later"
+}
+
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
@@ -34,15 +84,31 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword   string `json:"current_password" binding:"required"`
+	NewPassword       string `json:"new_password" binding:"required"`
+	KeepOtherSessions bool   `json:"keep_other_sessions"` // by default every other session must log in again
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"` // optional, revoked along with the access token
+}
+
 type AuthResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    int64       `json:"expires_in"` // access token lifetime in seconds
+	User         models.User `json:"user"`
 }
 
-func (s *AuthService) Register(req *RegisterRequest) (*AuthResponse, error) {
+func (s *AuthService) Register(ctx context.Context, req *RegisterRequest) (*AuthResponse, error) {
 	// Check if user already exists
 	var existingUser models.User
-	if err := s.db.Where("email = ? OR username = ?", req.Email, req.Username).First(&existingUser).Error; err == nil {
+	if err := s.db.WithContext(ctx).Where("email = ? OR username = ?", req.Email, req.Username).First(&existingUser).Error; err == nil {
 		return nil, errors.New("user already exists")
 	}
 
@@ -57,62 +123,366 @@ func (s *AuthService) Register(req *RegisterRequest) (*AuthResponse, error) {
 		Username: req.Username,
 		Email:    req.Email,
 		Password: string(hashedPassword),
+		Role:     models.RoleUser,
 	}
 
-	if err := s.db.Create(&user).Error; err != nil {
+	if err := s.db.WithContext(ctx).Create(&user).Error; err != nil {
 		return nil, err
 	}
 
-	// Generate token
-	token, err := s.generateToken(user.ID)
-	if err != nil {
+	// Generate access and refresh tokens
+	return s.issueTokens(ctx, user)
+}
+
+func (s *AuthService) Login(ctx context.Context, req *LoginRequest, clientIP string) (*AuthResponse, error) {
+	failuresKey := s.loginFailuresKey(req.Email, clientIP)
+	if err := s.checkLoginLockout(ctx, failuresKey); err != nil {
 		return nil, err
 	}
 
-	return &AuthResponse{
-		Token: token,
-		User:  user,
-	}, nil
-}
-
-func (s *AuthService) Login(req *LoginRequest) (*AuthResponse, error) {
 	var user models.User
-	if err := s.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("email = ?", req.Email).First(&user).Error; err != nil {
+		s.recordLoginFailure(ctx, failuresKey)
 		return nil, errors.New("invalid credentials")
 	}
 
 	// Check password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		s.recordLoginFailure(ctx, failuresKey)
 		return nil, errors.New("invalid credentials")
 	}
 
-	// Generate token
-	token, err := s.generateToken(user.ID)
+	if s.loginMaxAttempts > 0 {
+		s.redis.Del(ctx, failuresKey)
+	}
+
+	// Generate access and refresh tokens
+	return s.issueTokens(ctx, user)
+}
+
+// checkLoginLockout returns a LoginLockedError once the failure count for the
+// key reaches the limit. Redis errors let the attempt through rather than
+// locking everyone out.
+func (s *AuthService) checkLoginLockout(ctx context.Context, failuresKey string) error {
+	if s.loginMaxAttempts <= 0 {
+		return nil
+	}
+
+	failures, err := s.redis.Get(ctx, failuresKey).Int()
+	if err != nil {
+		if err != redis.Nil {
+			s.logger.Error("Failed to read login failures", "error", err)
+		}
+		return nil
+	}
+	if failures < s.loginMaxAttempts {
+		return nil
+	}
+
+	retryAfter, err := s.redis.TTL(ctx, failuresKey).Result()
+	if err != nil || retryAfter <= 0 {
+		retryAfter = s.loginLockout
+	}
+	return &LoginLockedError{RetryAfter: retryAfter}
+}
+
+// recordLoginFailure counts a failed login. Failures expire after the lockout
+// window, and reaching the limit restarts the window so the lockout lasts in full.
+func (s *AuthService) recordLoginFailure(ctx context.Context, failuresKey string) {
+	if s.loginMaxAttempts <= 0 {
+		return
+	}
+
+	failures, err := s.redis.Incr(ctx, failuresKey).Result()
 	if err != nil {
+		s.logger.Error("Failed to record login failure", "error", err)
+		return
+	}
+	if failures == 1 || failures == int64(s.loginMaxAttempts) {
+		s.redis.Expire(ctx, failuresKey, s.loginLockout)
+	}
+}
+
+// Refresh exchanges a refresh token for a new access token. The refresh token
+// is single-use: it is deleted on redemption and a new one is issued.
+func (s *AuthService) Refresh(ctx context.Context, req *RefreshRequest) (*AuthResponse, error) {
+	value, err := s.redis.GetDel(ctx, s.refreshKey(req.RefreshToken)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, errors.New("invalid or expired refresh token")
+		}
 		return nil, err
 	}
 
-	return &AuthResponse{
-		Token: token,
-		User:  user,
-	}, nil
+	// Stored as "<user id>:<issued at unix>"
+	userIDStr, issuedAtStr, _ := strings.Cut(value, ":")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		return nil, errors.New("invalid or expired refresh token")
+	}
+	issuedAt, _ := strconv.ParseInt(issuedAtStr, 10, 64)
+
+	// Refresh tokens issued before a password change are no longer valid
+	revoked, err := s.sessionsRevokedSince(ctx, uint(userID), time.Unix(issuedAt, 0))
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("invalid or expired refresh token")
+	}
+
+	user, err := s.GetUserByID(ctx, uint(userID))
+	if err != nil {
+		return nil, errors.New("invalid or expired refresh token")
+	}
+
+	return s.issueTokens(ctx, *user)
+}
+
+// ChangePassword replaces the user's password after verifying the current one.
+// Unless asked to keep them, all of the user's other sessions are invalidated;
+// the returned tokens keep the caller logged in.
+func (s *AuthService) ChangePassword(ctx context.Context, userID uint, req *ChangePasswordRequest) (*AuthResponse, error) {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return nil, errors.New("current password is incorrect")
+	}
+
+	if err := validatePasswordStrength(req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Model(user).Update("password", string(hashedPassword)).Error; err != nil {
+		return nil, err
+	}
+
+	if !req.KeepOtherSessions {
+		// Tokens issued before this second are rejected from now on
+		err := s.redis.Set(ctx, s.sessionsKey(userID), time.Now().Unix(), refreshTokenTTL).Err()
+		if err != nil {
+			return nil, fmt.Errorf("failed to invalidate other sessions: %v", err)
+		}
+	}
+
+	return s.issueTokens(ctx, *user)
+}
+
+// Logout revokes an access token by its ID until it would have expired anyway,
+// along with the refresh token issued with it if one is given
+func (s *AuthService) Logout(ctx context.Context, tokenID string, expiresAt time.Time, req *LogoutRequest) error {
+	if ttl := time.Until(expiresAt); ttl > 0 {
+		if err := s.redis.Set(ctx, s.revokedKey(tokenID), 1, ttl).Err(); err != nil {
+			return fmt.Errorf("failed to revoke token: %v", err)
+		}
+	}
+
+	if req.RefreshToken != "" {
+		if err := s.redis.Del(ctx, s.refreshKey(req.RefreshToken)).Err(); err != nil {
+			return fmt.Errorf("failed to revoke refresh token: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// IsTokenRevoked reports whether an access token was revoked, either by logging
+// out or by a password change that invalidated the user's earlier sessions
+func (s *AuthService) IsTokenRevoked(ctx context.Context, tokenID string, userID uint, issuedAt time.Time) (bool, error) {
+	if tokenID != "" {
+		n, err := s.redis.Exists(ctx, s.revokedKey(tokenID)).Result()
+		if err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+
+	return s.sessionsRevokedSince(ctx, userID, issuedAt)
 }
 
-func (s *AuthService) GetUserByID(userID uint) (*models.User, error) {
+// sessionsRevokedSince reports whether the user's sessions were invalidated
+// after a token issued at issuedAt
+func (s *AuthService) sessionsRevokedSince(ctx context.Context, userID uint, issuedAt time.Time) (bool, error) {
+	cutoff, err := s.redis.Get(ctx, s.sessionsKey(userID)).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, err
+	}
+	return issuedAt.Unix() < cutoff, nil
+}
+
+func (s *AuthService) GetUserByID(ctx context.Context, userID uint) (*models.User, error) {
 	var user models.User
-	if err := s.db.First(&user, userID).Error; err != nil {
+	if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (s *AuthService) generateToken(userID uint) (string, error) {
+// ListUsers returns every user, for admins
+func (s *AuthService) ListUsers(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	err := s.db.WithContext(ctx).Order("created_at DESC").Find(&users).Error
+	return users, err
+}
+
+func (s *AuthService) generateToken(user models.User) (string, error) {
+	tokenID := make([]byte, 16)
+	if _, err := rand.Read(tokenID); err != nil {
+		return "", err
+	}
+
 	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
+		"jti":     hex.EncodeToString(tokenID),
+		"user_id": user.ID,
+		"role":    user.Role,
+		"iss":     s.jwtIssuer,
+		"exp":     time.Now().Add(s.accessTokenTTL).Unix(),
 		"iat":     time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(s.jwtSecret))
 }
+
+// NewGuestID returns a random ID for a guest joining for the first time
+func NewGuestID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// IssueGuestToken signs a guest identity into a token the guest keeps
+func (s *AuthService) IssueGuestToken(guest GuestIdentity) (string, error) {
+	claims := jwt.MapClaims{
+		"type":     "guest",
+		"guest_id": guest.ID,
+		"name":     guest.Name,
+		"exp":      time.Now().Add(guestTokenTTL).Unix(),
+		"iat":      time.Now().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.jwtSecret))
+}
+
+// ValidateGuestToken returns the guest identity a token was issued for
+func (s *AuthService) ValidateGuestToken(tokenString string) (*GuestIdentity, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidGuestToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["type"] != "guest" {
+		return nil, ErrInvalidGuestToken
+	}
+
+	guestID, _ := claims["guest_id"].(string)
+	if guestID == "" {
+		return nil, ErrInvalidGuestToken
+	}
+	name, _ := claims["name"].(string)
+
+	return &GuestIdentity{ID: guestID, Name: name}, nil
+}
+
+// issueTokens creates a short-lived access token and a refresh token for the user
+func (s *AuthService) issueTokens(ctx context.Context, user models.User) (*AuthResponse, error) {
+	token, err := s.generateToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.generateRefreshToken(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTokenTTL.Seconds()),
+		User:         user.Sanitized(),
+	}, nil
+}
+
+// generateRefreshToken creates a random refresh token and stores it in Redis
+// (hashed) against the user it was issued to
+func (s *AuthService) generateRefreshToken(ctx context.Context, userID uint) (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	refreshToken := hex.EncodeToString(bytes)
+
+	value := fmt.Sprintf("%d:%d", userID, time.Now().Unix())
+	err := s.redis.Set(ctx, s.refreshKey(refreshToken), value, refreshTokenTTL).Err()
+	if err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %v", err)
+	}
+
+	return refreshToken, nil
+}
+
+// refreshKey is the Redis key for a refresh token; only its hash is stored
+func (s *AuthService) refreshKey(refreshToken string) string {
+	hash := sha256.Sum256([]byte(refreshToken))
+	return s.keyPrefix + "refresh:" + hex.EncodeToString(hash[:])
+}
+
+// loginFailuresKey is the Redis key counting failed logins for an email from one IP
+func (s *AuthService) loginFailuresKey(email string, clientIP string) string {
+	return s.keyPrefix + "login_failures:" + strings.ToLower(email) + ":" + clientIP
+}
+
+// revokedKey is the Redis key marking an access token ID as revoked
+func (s *AuthService) revokedKey(tokenID string) string {
+	return s.keyPrefix + "revoked:" + tokenID
+}
+
+// sessionsKey is the Redis key holding when the user's sessions were last invalidated
+func (s *AuthService) sessionsKey(userID uint) string {
+	return s.keyPrefix + "sessions_valid_after:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// validatePasswordStrength requires at least 8 characters including a letter and a digit
+func validatePasswordStrength(password string) error {
+	if len(password) < 8 {
+		return errors.New("new password must be at least 8 characters")
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return errors.New("new password must contain both letters and digits")
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"openquiz/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRefreshTokenIsSingleUse(t *testing.T) {
+	s := newTestAuthService(t)
+	ctx := context.Background()
+	registered := registerTestUser(t, s, "password1")
+
+	if registered.RefreshToken == "" || registered.ExpiresIn != 3600 {
+		t.Fatalf("register response = %+v, want a refresh token and an hour's access", registered)
+	}
+
+	// Only a hash of the token is stored
+	keys := s.redis.Keys(ctx, s.keyPrefix+"refresh:*").Val()
+	if len(keys) != 1 || strings.Contains(keys[0], registered.RefreshToken) {
+		t.Errorf("refresh keys = %v, want one keyed by the token's hash", keys)
+	}
+
+	refreshed, err := s.Refresh(ctx, &RefreshRequest{RefreshToken: registered.RefreshToken})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed.Token == "" || refreshed.RefreshToken == registered.RefreshToken || refreshed.User.ID != registered.User.ID {
+		t.Errorf("refresh response = %+v, want new tokens for the same user", refreshed)
+	}
+
+	if _, err := s.Refresh(ctx, &RefreshRequest{RefreshToken: registered.RefreshToken}); err == nil {
+		t.Error("a refresh token was redeemed twice")
+	}
+	if _, err := s.Refresh(ctx, &RefreshRequest{RefreshToken: "not-a-refresh-token"}); err == nil {
+		t.Error("an unknown refresh token was redeemed")
+	}
+	if _, err := s.Refresh(ctx, &RefreshRequest{RefreshToken: refreshed.RefreshToken}); err != nil {
+		t.Errorf("the new refresh token was refused: %v", err)
+	}
+}
+
+func TestValidatePasswordStrength(t *testing.T) {
+	for password, wantErr := range map[string]bool{
+		"passw0rd":  false,
+		"pass1":     true,
+		"password":  true,
+		"12345678":  true,
+		"pässwörd9": false,
+	} {
+		if err := validatePasswordStrength(password); (err != nil) != wantErr {
+			t.Errorf("validatePasswordStrength(%q) = %v, want error %v", password, err, wantErr)
+		}
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	s := newTestAuthService(t)
+	ctx := context.Background()
+	registered := registerTestUser(t, s, "password1")
+	userID := registered.User.ID
+
+	_, err := s.ChangePassword(ctx, userID, &ChangePasswordRequest{CurrentPassword: "wrong-password1", NewPassword: "newpassword2"})
+	if err == nil || err.Error() != "current password is incorrect" {
+		t.Errorf("wrong current password: got %v, want current password is incorrect", err)
+	}
+	if _, err := s.ChangePassword(ctx, userID, &ChangePasswordRequest{CurrentPassword: "password1", NewPassword: "short"}); err == nil {
+		t.Error("changed to a weak password")
+	}
+
+	// Sessions are invalidated to the second, so let the first one age past it
+	time.Sleep(time.Second)
+	changed, err := s.ChangePassword(ctx, userID, &ChangePasswordRequest{CurrentPassword: "password1", NewPassword: "newpassword2"})
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if changed.Token == "" || changed.RefreshToken == "" {
+		t.Errorf("change response = %+v, want fresh tokens", changed)
+	}
+
+	email := registered.User.Email
+	if _, err := s.Login(ctx, &LoginRequest{Email: email, Password: "password1"}, "127.0.0.1"); err == nil {
+		t.Error("logged in with the old password")
+	}
+	if _, err := s.Login(ctx, &LoginRequest{Email: email, Password: "newpassword2"}, "127.0.0.1"); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+
+	// The earlier session must log in again; the one that changed it carries on
+	if _, err := s.Refresh(ctx, &RefreshRequest{RefreshToken: registered.RefreshToken}); err == nil {
+		t.Error("a refresh token from before the change still works")
+	}
+	if _, err := s.Refresh(ctx, &RefreshRequest{RefreshToken: changed.RefreshToken}); err != nil {
+		t.Errorf("the refresh token issued with the change: %v", err)
+	}
+}
+
+func TestLoginLocksOutAfterRepeatedFailures(t *testing.T) {
+	s := newTestAuthService(t)
+	ctx := context.Background()
+	email := registerTestUser(t, s, "password1").User.Email
+
+	login := func(password string, clientIP string) error {
+		_, err := s.Login(ctx, &LoginRequest{Email: email, Password: password}, clientIP)
+		return err
+	}
+
+	// A success resets the count, so failures must be consecutive
+	for i := 0; i < 4; i++ {
+		login("wrong", "10.0.0.1")
+	}
+	if err := login("password1", "10.0.0.1"); err != nil {
+		t.Fatalf("login after 4 failures: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		login("wrong", "10.0.0.1")
+	}
+	if err := login("password1", "10.0.0.1"); err != nil {
+		t.Fatalf("login after the count was reset: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := login("wrong", "10.0.0.1"); err == nil {
+			t.Fatal("logged in with the wrong password")
+		}
+	}
+	var locked *LoginLockedError
+	if err := login("password1", "10.0.0.1"); !errors.As(err, &locked) {
+		t.Fatalf("login after 5 failures = %v, want LoginLockedError", err)
+	}
+	if locked.RetryAfter <= 0 || locked.RetryAfter > 15*time.Minute {
+		t.Errorf("RetryAfter = %v, want up to the 15 minute lockout", locked.RetryAfter)
+	}
+
+	// The lockout is per email and IP
+	if err := login("password1", "10.0.0.2"); err != nil {
+		t.Errorf("login from another IP: %v", err)
+	}
+}
+
+func TestGuestTokenRoundTrip(t *testing.T) {
+	s := NewAuthService(nil, nil, testLogger, "test-secret", "openquiz", time.Hour, "", 0, 0)
+
+	id, err := NewGuestID()
+	if err != nil {
+		t.Fatalf("NewGuestID: %v", err)
+	}
+	if other, _ := NewGuestID(); other == id || len(id) != 32 {
+		t.Fatalf("guest IDs %q and %q, want two distinct 32-character IDs", id, other)
+	}
+
+	token, err := s.IssueGuestToken(GuestIdentity{ID: id, Name: "Ada"})
+	if err != nil {
+		t.Fatalf("IssueGuestToken: %v", err)
+	}
+	guest, err := s.ValidateGuestToken(token)
+	if err != nil {
+		t.Fatalf("ValidateGuestToken: %v", err)
+	}
+	if guest.ID != id || guest.Name != "Ada" {
+		t.Errorf("guest = %+v, want %s named Ada", guest, id)
+	}
+}
+
+func TestInvalidGuestTokensAreRejected(t *testing.T) {
+	s := NewAuthService(nil, nil, testLogger, "test-secret", "openquiz", time.Hour, "", 0, 0)
+	sign := func(secret string, claims jwt.MapClaims) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return token
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	accessToken, err := s.generateToken(models.User{ID: 1, Role: "user"})
+	if err != nil {
+		t.Fatalf("generateToken: %v", err)
+	}
+
+	tests := map[string]string{
+		"garbage":         "not-a-token",
+		"other secret":    sign("other-secret", jwt.MapClaims{"type": "guest", "guest_id": "abc", "exp": exp}),
+		"expired":         sign("test-secret", jwt.MapClaims{"type": "guest", "guest_id": "abc", "exp": time.Now().Add(-time.Minute).Unix()}),
+		"no guest id":     sign("test-secret", jwt.MapClaims{"type": "guest", "exp": exp}),
+		"an access token": accessToken,
+	}
+	for name, token := range tests {
+		if _, err := s.ValidateGuestToken(token); !errors.Is(err, ErrInvalidGuestToken) {
+			t.Errorf("%s: ValidateGuestToken() = %v, want ErrInvalidGuestToken", name, err)
+		}
+	}
+}
+
+func TestCancelledContextAbortsRevocationCheck(t *testing.T) {
+	s := newTestAuthService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.IsTokenRevoked(ctx, "some-token", 1, time.Now()); !errors.Is(err, context.Canceled) {
+		t.Errorf("IsTokenRevoked() with a cancelled context = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	dbRetryAttempts = 3
+	dbRetryBackoff  = 200 * time.Millisecond
+)
+
+// withDBRetry runs a game-flow database call, retrying briefly when the
+// connection drops so a short blip doesn't abort a live question
+func withDBRetry(op func() error) error {
+	var err error
+	for attempt := 1; attempt <= dbRetryAttempts; attempt++ {
+		if err = op(); err == nil || !isTransientDBError(err) {
+			return err
+		}
+		if attempt < dbRetryAttempts {
+			slog.Warn("Transient database error, retrying", "attempt", attempt, "max_attempts", dbRetryAttempts, "error", err)
+			time.Sleep(dbRetryBackoff * time.Duration(attempt))
+		}
+	}
+	return err
+}
+
+// isTransientDBError reports connection failures where the statement is known
+// not to have run, so retrying is safe even for non-idempotent writes
+func isTransientDBError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var retryable interface{ SafeToRetry() bool }
+	if errors.As(err, &retryable) && retryable.SafeToRetry() {
+		return true
+	}
+
+	// Class 08 is connection exceptions; 57P01-57P03 mean the server is
+	// shutting down or not yet accepting connections
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") ||
+			pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+
+	return false
+}
+
+// isUniqueViolation reports whether an insert failed on a unique constraint
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestWithDBRetryRecoversFromTransientErrors(t *testing.T) {
+	calls := 0
+	err := withDBRetry(func() error {
+		calls++
+		if calls < 3 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("withDBRetry() = %v after %d calls, want success on the third", err, calls)
+	}
+}
+
+func TestWithDBRetryGivesUp(t *testing.T) {
+	calls := 0
+	err := withDBRetry(func() error {
+		calls++
+		return &pgconn.PgError{Code: "57P01"}
+	})
+	if err == nil || calls != dbRetryAttempts {
+		t.Errorf("withDBRetry() = %v after %d calls, want the error after %d", err, calls, dbRetryAttempts)
+	}
+
+	// Anything that isn't a dropped connection fails straight away
+	calls = 0
+	notFound := errors.New("record not found")
+	if err := withDBRetry(func() error {
+		calls++
+		return notFound
+	}); !errors.Is(err, notFound) || calls != 1 {
+		t.Errorf("withDBRetry() = %v after %d calls, want the error after 1", err, calls)
+	}
+}
+
+func TestIsTransientDBError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad connection", driver.ErrBadConn, true},
+		{"wrapped bad connection", fmt.Errorf("query: %w", driver.ErrBadConn), true},
+		{"connection exception", &pgconn.PgError{Code: "08006"}, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"cannot connect now", &pgconn.PgError{Code: "57P03"}, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"query cancelled", &pgconn.PgError{Code: "57014"}, false},
+		{"other error", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientDBError(tt.err); got != tt.want {
+				t.Errorf("isTransientDBError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unique violation", &pgconn.PgError{Code: "23505"}, true},
+		{"wrapped unique violation", fmt.Errorf("insert answer: %w", &pgconn.PgError{Code: "23505"}), true},
+		{"foreign key violation", &pgconn.PgError{Code: "23503"}, false},
+		{"other error", errors.New("boom"), false},
+		{"no error", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUniqueViolation(tt.err); got != tt.want {
+				t.Errorf("isUniqueViolation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
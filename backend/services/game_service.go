@@ -3,48 +3,294 @@ package services
 import (
 	"context"
 	"crypto/rand"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
+	"math/big"
+	mathrand "math/rand/v2"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"openquiz/metrics"
 	"openquiz/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
+// ErrGameNotFound is returned when a game pin matches no game in Redis or the database
+var ErrGameNotFound = errors.New("game not found")
+
+// ErrNotGameOwner is returned when a user acts on a game for a quiz they don't own
+var ErrNotGameOwner = errors.New("unauthorized to control this game")
+
+// ErrGameNotWaiting is returned when cancelling a game that has already started
+var ErrGameNotWaiting = errors.New("only games that haven't started can be cancelled")
+
+// ErrGameNotJoinable is returned when joining a game that has already finished
+var ErrGameNotJoinable = errors.New("game has already finished")
+
+// ErrGameNotActive is returned when answering in a game that isn't running
+var ErrGameNotActive = errors.New("game is not active")
+
+// ErrPlayerNotInGame is returned when answering as a player who hasn't joined
+// the game, which is how spectators are kept from answering
+var ErrPlayerNotInGame = errors.New("player is not in this game")
+
+// ErrAnswerAlreadySubmitted is returned when a player answers the same question twice
+var ErrAnswerAlreadySubmitted = errors.New("answer already submitted")
+
+// ErrQuestionNotActive is returned when an answer is for a question other than
+// the one on screen, or arrives after its time is up
+var ErrQuestionNotActive = errors.New("question is no longer accepting answers")
+
+// ErrGameAlreadyStarted is returned when starting a game that isn't waiting
+var ErrGameAlreadyStarted = errors.New("game has already started")
+
+// ErrOptionNotInQuestion is returned when an answer's option belongs to a
+// different question than the one being answered
+var ErrOptionNotInQuestion = errors.New("option does not belong to this question")
+
+// ErrNotEnoughPlayers is returned when starting a game before its minimum
+// number of players has joined
+var ErrNotEnoughPlayers = errors.New("not enough players have joined")
+
+// ErrRevealInProgress is returned when moving on while the last question's
+// results are still being shown
+var ErrRevealInProgress = errors.New("question results are still being revealed")
+
+// ErrQuestionStarting is returned when moving on while the next question is
+// still counting down
+var ErrQuestionStarting = errors.New("the next question is about to start")
+
+// ErrDeviceIDRequired is returned when joining a game that allows one join per
+// device without saying which device is joining
+var ErrDeviceIDRequired = errors.New("device_id is required to join this game")
+
+// ErrInvalidPin is returned when a game PIN isn't in the generated format
+var ErrInvalidPin = errors.New("invalid game PIN")
+
+// defaultGameStateTTL is used when no positive game state TTL is configured
+const defaultGameStateTTL = 2 * time.Hour
+
+// autoStartCountdown is how many seconds an auto-started game counts down
+// once its lobby is full
+const autoStartCountdown = 5
+
+// Scoring for quizzes that don't set their own profile
+const (
+	defaultBasePoints   = 100
+	defaultMaxTimeBonus = 50
+)
+
+// answerProgressInterval is the most often answer_progress is sent per game;
+// answers arriving in between are counted in the next one
+const answerProgressInterval = 500 * time.Millisecond
+
+// leaderboardUpdateSize is how many players the between-question leaderboard shows
+const leaderboardUpdateSize = 10
+
+// defaultRevealSeconds is how long a question's results stay on screen before
+// the game can move on, for quizzes that don't set their own
+const defaultRevealSeconds = 5
+
+// defaultQuestionCountdown is how many seconds are counted down before each
+// question appears, for quizzes that don't set their own
+const defaultQuestionCountdown = 3
+
+// Characters generated PINs are drawn from; numeric PINs are easier to type
+// on a phone keypad
+const (
+	pinAlphabetHex     = "hex"
+	pinAlphabetNumeric = "numeric"
+)
+
+// Lengths a PIN may be generated with; every PIN in this range is accepted
+// back, so changing the length doesn't lock out games already created
+const (
+	minPinLength     = 4
+	maxPinLength     = 10
+	defaultPinLength = 6
+)
+
+// pinAttempts is how many PINs are tried before giving up on finding an unused one
+const pinAttempts = 10
+
 type GameService struct {
-	db    *gorm.DB
-	redis *redis.Client
+	db              *gorm.DB
+	redis           *redis.Client
+	logger          *slog.Logger
+	jwtSecret       string
+	keyPrefix       string        // namespace for all Redis keys, e.g. "staging:"
+	maxGameDuration time.Duration // ceiling on how long a started game may run, 0 for none
+	gameStateTTL    time.Duration // how long game keys live after their last write
+	pinChars        string        // characters generated PINs are made of
+	pinLength       int
+	finishedGrace   time.Duration // how long a finished game's Redis state is kept for late syncs
+
+	// Running auto-start countdowns by pin, so a manual start can cancel them
+	autoStartMu sync.Mutex
+	autoStarts  map[string]context.CancelFunc
+
+	// Games with an answer_progress broadcast waiting to go out
+	progressMu      sync.Mutex
+	progressPending map[string]bool
 }
 
-func NewGameService(db *gorm.DB, redis *redis.Client) *GameService {
+func NewGameService(db *gorm.DB, redis *redis.Client, logger *slog.Logger, jwtSecret string, keyPrefix string, maxGameDuration, gameStateTTL time.Duration, pinAlphabet string, pinLength int, finishedGrace time.Duration) *GameService {
+	if gameStateTTL <= 0 {
+		gameStateTTL = defaultGameStateTTL
+	}
+	pinChars := "0123456789abcdef"
+	if pinAlphabet == pinAlphabetNumeric {
+		pinChars = "0123456789"
+	}
+	if pinLength < minPinLength || pinLength > maxPinLength {
+		pinLength = defaultPinLength
+	}
 	return &GameService{
-		db:    db,
-		redis: redis,
+		db:              db,
+		redis:           redis,
+		logger:          logger,
+		jwtSecret:       jwtSecret,
+		keyPrefix:       keyPrefix,
+		maxGameDuration: maxGameDuration,
+		gameStateTTL:    gameStateTTL,
+		pinChars:        pinChars,
+		pinLength:       pinLength,
+		finishedGrace:   finishedGrace,
+		autoStarts:      make(map[string]context.CancelFunc),
+		progressPending: make(map[string]bool),
 	}
 }
 
 type StartGameRequest struct {
-	QuizID uint `json:"quiz_id" binding:"required"`
+	QuizID       uint `json:"quiz_id" binding:"required"`
+	TrainingMode bool `json:"training_mode"`
+	MaxDuration  int  `json:"max_duration" binding:"omitempty,min=1"` // minutes, capped by the server ceiling
+
+	// Start without the host once this many players have joined
+	AutoStartPlayers int `json:"auto_start_players" binding:"omitempty,min=1"`
+	// Play the questions in a random order fixed for this game
+	ShuffleQuestions bool `json:"shuffle_questions"`
+	// Refuse to start until this many players have joined
+	MinPlayers int `json:"min_players" binding:"omitempty,min=1"`
+	// Show the standings after every question, not just at the end
+	ShowLeaderboard bool `json:"show_leaderboard"`
+	// Show each player the options in a different order
+	ShuffleOptions bool `json:"shuffle_options"`
+	// End a question once everyone has answered and move on after the results
+	AutoAdvance bool `json:"auto_advance"`
+	// Let each device join only once; joining again returns the same player
+	OneJoinPerDevice bool `json:"one_join_per_device"`
+	// Mark the correct options on the host's screen while a question is live
+	ShowAnswersToHost bool `json:"show_answers_to_host"`
+}
+
+type PrepareGameRequest struct {
+	QuizID       uint      `json:"quiz_id" binding:"required"`
+	ScheduledAt  time.Time `json:"scheduled_at" binding:"required"`
+	TrainingMode bool      `json:"training_mode"`
+	MaxDuration  int       `json:"max_duration" binding:"omitempty,min=1"`
 }
 
 type JoinGameRequest struct {
-	Pin  string `json:"pin" binding:"required"`
-	Name string `json:"name" binding:"required"`
+	Pin      string `json:"pin" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	DeviceID string `json:"device_id" binding:"max=128"` // client-generated, required by games allowing one join per device
+
+	// Returning guests send the token from their last join to keep their history
+	GuestToken string `json:"guest_token"`
+	GuestID    string `json:"-"` // set by the handler from a valid guest token
+}
+
+// StartGameResponse is the started game with what players need to join it
+type StartGameResponse struct {
+	models.Game
+	JoinURL  string `json:"join_url"`  // encode this for the lobby QR code
+	JoinCode string `json:"join_code"` // the PIN as it should be displayed
+}
+
+// JoinURL is the frontend page players open to join the game with this PIN
+func JoinURL(publicBaseURL string, pin string) string {
+	return strings.TrimRight(publicBaseURL, "/") + "/join?pin=" + url.QueryEscape(DisplayPin(pin))
+}
+
+type JoinableStatus struct {
+	Exists   bool   `json:"exists"`
+	Joinable bool   `json:"joinable"`
+	Status   string `json:"status,omitempty"` // left out when the game doesn't exist
+}
+
+type JoinGameResponse struct {
+	models.Player
+	ReconnectToken string `json:"reconnect_token"`
+	SocketToken    string `json:"socket_token"` // required to open the player's WebSocket
+	Rejoined       bool   `json:"rejoined"`     // the device had already joined, so its existing player was returned
+	GuestToken     string `json:"guest_token"`  // keep and send on the next join to carry the guest's history
+}
+
+// GuestGame is a game a guest has played, for their history
+type GuestGame struct {
+	Pin        string    `json:"pin"`
+	QuizTitle  string    `json:"quiz_title"`
+	Status     string    `json:"status"`
+	PlayerName string    `json:"player_name"`
+	Score      int       `json:"score"`
+	JoinedAt   time.Time `json:"joined_at"`
 }
 
 type SubmitAnswerRequest struct {
-	PlayerID   uint `json:"player_id" binding:"required"`
+	PlayerID   uint `json:"player_id"` // optional over REST, where the player comes from the socket token
 	QuestionID uint `json:"question_id" binding:"required"`
 	OptionID   uint `json:"option_id" binding:"required"`
-	TimeSpent  int  `json:"time_spent"`
+	TimeSpent  int  `json:"time_spent"`                                 // advisory; used only when the server lacks the question's start time
+	Confidence int  `json:"confidence" binding:"omitempty,min=1,max=3"` // optional: 1 = guessing, 3 = certain
+}
+
+type ListGamesQuery struct {
+	Status   string    `form:"status" binding:"omitempty,oneof=waiting active finished"`
+	From     time.Time `form:"from" time_format:"2006-01-02"`
+	To       time.Time `form:"to" time_format:"2006-01-02"`
+	Page     int       `form:"page" binding:"omitempty,min=1"`
+	PageSize int       `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+type GameSummary struct {
+	ID          uint       `json:"id"`
+	Pin         string     `json:"pin"`
+	QuizID      uint       `json:"quiz_id"`
+	QuizTitle   string     `json:"quiz_title"`
+	Status      string     `json:"status"`
+	PlayerCount int        `json:"player_count"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ActiveGame is a game that hasn't finished yet, with how far it has got
+type ActiveGame struct {
+	GameSummary
+	CurrentQuestionIndex int `json:"current_question_index"` // -1 until the first question starts
+	TotalQuestions       int `json:"total_questions"`
+}
+
+type GameListResponse struct {
+	Games    []GameSummary `json:"games"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	Total    int64         `json:"total"`
 }
 
 type GameState struct {
@@ -53,53 +299,162 @@ type GameState struct {
 	Pin                  string        `json:"pin"`
 	Status               string        `json:"status"`
 	CurrentQuestion      *GameQuestion `json:"current_question,omitempty"`
+	HostQuestion         *GameQuestion `json:"host_question,omitempty"` // the current question with its answers, for games showing them to the host
 	CurrentQuestionIndex int           `json:"current_question_index"`
 	Players              []GamePlayer  `json:"players"`
-	Leaderboard          []GamePlayer  `json:"leaderboard"`
+	Leaderboard          []GamePlayer  `json:"-"` // ranked as in the final leaderboard; kept under its own key
 	TotalQuestions       int           `json:"total_questions"`
+	QuestionOrder        []uint        `json:"question_order,omitempty"` // question IDs in play order when shuffled
+	ShuffleOptions       bool          `json:"shuffle_options,omitempty"`
+	ScheduledAt          *time.Time    `json:"scheduled_at,omitempty"`
+	QuestionStartsAt     *time.Time    `json:"question_starts_at,omitempty"` // the current question appears then, after its countdown
+	QuestionStartedAt    *time.Time    `json:"question_started_at,omitempty"`
+	QuestionEndsAt       *time.Time    `json:"question_ends_at,omitempty"`
+	RevealEndsAt         *time.Time    `json:"reveal_ends_at,omitempty"` // results of the last question are shown until then
+	GameEndsAt           *time.Time    `json:"game_ends_at,omitempty"`   // finished automatically at this time
+}
+
+type GameResults struct {
+	Pin       string         `json:"pin"`
+	QuizTitle string         `json:"quiz_title"`
+	Status    string         `json:"status"`
+	MaxScore  int            `json:"max_score"`
+	Players   []PlayerResult `json:"players"`
+}
+
+type PlayerResult struct {
+	ID      uint    `json:"id"`
+	Name    string  `json:"name"`
+	Score   int     `json:"score"`
+	Rank    int     `json:"rank"`            // tied players share a rank
+	Percent float64 `json:"percent"`         // of the maximum possible score
+	Grade   string  `json:"grade,omitempty"` // only when the quiz has a grade rubric
+}
+
+// PlayerAnswers is one player's answers in a game, for reviewing results
+type PlayerAnswers struct {
+	PlayerID   uint           `json:"player_id"`
+	PlayerName string         `json:"player_name"`
+	Score      int            `json:"score"`
+	Answers    []PlayerAnswer `json:"answers"`
+}
+
+type PlayerAnswer struct {
+	QuestionID        uint   `json:"question_id"`
+	QuestionText      string `json:"question_text"`
+	OptionID          uint   `json:"option_id"`
+	OptionText        string `json:"option_text"`
+	CorrectOptionText string `json:"correct_option_text,omitempty"` // empty for polls
+	IsCorrect         bool   `json:"is_correct"`
+	Points            int    `json:"points"`
+	TimeSpent         int    `json:"time_spent"` // seconds
+}
+
+// GameWithState is a game record plus its live state, so a client arriving
+// mid-game can render the right screen without a WebSocket round trip
+type GameWithState struct {
+	models.Game
+	State *LiveGameState `json:"state,omitempty"` // omitted if the live state can't be read
+}
+
+type LiveGameState struct {
+	Status               string     `json:"status"`
+	CurrentQuestionIndex int        `json:"current_question_index"` // -1 before the first question
+	TotalQuestions       int        `json:"total_questions"`
+	TimeLeft             int        `json:"time_left"` // seconds left on the current question, 0 when none is running
+	QuestionEndsAt       *time.Time `json:"question_ends_at,omitempty"`
+}
+
+type QuestionTimer struct {
+	Active        bool       `json:"active"` // false when no question is running
+	QuestionIndex int        `json:"question_index"`
+	TimeLimit     int        `json:"time_limit"`
+	TimeLeft      int        `json:"time_left"`
+	EndsAt        *time.Time `json:"ends_at,omitempty"`
 }
 
 type GameQuestion struct {
 	ID        uint         `json:"id"`
 	Text      string       `json:"text"`
+	Type      string       `json:"type"`
 	TimeLimit int          `json:"time_limit"`
 	Options   []GameOption `json:"options"`
 	TimeLeft  int          `json:"time_left"`
 }
 
 type GameOption struct {
-	ID   uint   `json:"id"`
-	Text string `json:"text"`
-	// Don't include IsCorrect during active quiz
+	ID        uint   `json:"id"`
+	Text      string `json:"text"`
+	IsCorrect *bool  `json:"is_correct,omitempty"` // only set for the owner's preview, never during a game
 }
 
 type GamePlayer struct {
 	ID    uint   `json:"id"`
 	Name  string `json:"name"`
 	Score int    `json:"score"`
+	Rank  int    `json:"rank,omitempty"` // only in the final leaderboard; tied players share a rank
+}
+
+// RosterPlayer is a player in a game's roster along with whether they have a
+// WebSocket connection open
+type RosterPlayer struct {
+	GamePlayer
+	Online bool `json:"online"`
+}
+
+func (s *GameService) StartGame(ctx context.Context, userID uint, req *StartGameRequest) (*models.Game, error) {
+	return s.createGame(ctx, userID, &models.Game{
+		QuizID:            req.QuizID,
+		TrainingMode:      req.TrainingMode,
+		MaxDuration:       req.MaxDuration,
+		AutoStartPlayers:  req.AutoStartPlayers,
+		ShuffleQuestions:  req.ShuffleQuestions,
+		ShuffleOptions:    req.ShuffleOptions,
+		MinPlayers:        req.MinPlayers,
+		ShowLeaderboard:   req.ShowLeaderboard,
+		AutoAdvance:       req.AutoAdvance,
+		OneJoinPerDevice:  req.OneJoinPerDevice,
+		ShowAnswersToHost: req.ShowAnswersToHost,
+	})
+}
+
+// PrepareGame creates a game ahead of its scheduled start and warms its Redis
+// state so the pin can be shared in advance and players can join while waiting
+func (s *GameService) PrepareGame(ctx context.Context, userID uint, req *PrepareGameRequest) (*models.Game, error) {
+	if !req.ScheduledAt.After(time.Now()) {
+		return nil, errors.New("scheduled time must be in the future")
+	}
+
+	scheduledAt := req.ScheduledAt.UTC()
+	return s.createGame(ctx, userID, &models.Game{
+		QuizID:       req.QuizID,
+		ScheduledAt:  &scheduledAt,
+		TrainingMode: req.TrainingMode,
+		MaxDuration:  req.MaxDuration,
+	})
 }
 
-func (s *GameService) StartGame(userID uint, req *StartGameRequest) (*models.Game, error) {
+// createGame creates a waiting game with the given settings for a quiz owned
+// by the user and stores its initial state in Redis
+func (s *GameService) createGame(ctx context.Context, userID uint, game *models.Game) (*models.Game, error) {
 	// Check if quiz exists and belongs to user
 	var quiz models.Quiz
-	if err := s.db.Where("id = ? AND user_id = ?", req.QuizID, userID).
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", game.QuizID, userID).
 		Preload("Questions").
 		Preload("Questions.Options").
 		First(&quiz).Error; err != nil {
 		return nil, errors.New("quiz not found")
 	}
 
-	// Generate unique PIN
-	pin := s.generatePin()
-
-	// Create game
-	game := models.Game{
-		QuizID: req.QuizID,
-		Pin:    pin,
-		Status: "waiting",
+	pin, err := s.generatePin(ctx)
+	if err != nil {
+		return nil, err
 	}
+	game.Pin = pin
+	game.Status = "waiting"
 
-	if err := s.db.Create(&game).Error; err != nil {
+	// Create game
+	if err := s.db.WithContext(ctx).Create(game).Error; err != nil {
 		return nil, err
 	}
 
@@ -112,48 +467,71 @@ func (s *GameService) StartGame(userID uint, req *StartGameRequest) (*models.Gam
 		CurrentQuestionIndex: -1, // -1 means no question active yet
 		Players:              []GamePlayer{},
 		TotalQuestions:       len(quiz.Questions),
+		ScheduledAt:          game.ScheduledAt,
 	}
 
 	// Normalize game pin to lowercase for consistent Redis storage
 	normalizedPin := strings.ToLower(game.Pin)
-	if err := s.storeGameState(normalizedPin, gameState); err != nil {
-		log.Printf("Failed to store game state in Redis: %v", err)
+	if err := s.storeGameState(ctx, normalizedPin, gameState); err != nil {
+		s.logger.Error("Failed to store game state in Redis", "game_pin", game.Pin, "error", err)
 	}
 
-	return &game, nil
+	return game, nil
 }
 
-func (s *GameService) StartQuiz(gamePin string, userID uint) (*models.Game, error) {
+func (s *GameService) StartQuiz(ctx context.Context, gamePin string, userID uint, hub *Hub) (*models.Game, error) {
 	// Normalize pin
 	normalizedPin := strings.ToLower(gamePin)
 
 	// Get game and verify ownership
 	var game models.Game
-	if err := s.db.Where("LOWER(pin) = ?", normalizedPin).
-		Preload("Quiz").
-		Preload("Quiz.Questions").
-		Preload("Quiz.Questions.Options").
-		First(&game).Error; err != nil {
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Where("LOWER(pin) = ?", normalizedPin).
+			Scopes(preloadOrderedQuestions).
+			First(&game).Error
+	}); err != nil {
 		return nil, errors.New("game not found")
 	}
+	s.applyQuestionOrder(ctx, &game)
 
 	// Check if user owns the quiz
 	var quiz models.Quiz
-	if err := s.db.Where("id = ? AND user_id = ?", game.QuizID, userID).First(&quiz).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", game.QuizID, userID).First(&quiz).Error; err != nil {
 		return nil, errors.New("unauthorized to start this game")
 	}
 
-	// Update game status to active
-	if err := s.db.Model(&game).Update("status", "active").Error; err != nil {
-		return nil, err
+	if game.MinPlayers > 0 {
+		var playerCount int64
+		if err := s.db.WithContext(ctx).Model(&models.Player{}).Where("game_id = ?", game.ID).Count(&playerCount).Error; err != nil {
+			return nil, err
+		}
+		if playerCount < int64(game.MinPlayers) {
+			return nil, fmt.Errorf("%w: %d of %d needed", ErrNotEnoughPlayers, playerCount, game.MinPlayers)
+		}
+	}
+
+	// A manual start supersedes any auto-start countdown
+	s.cancelAutoStart(normalizedPin)
+
+	// Update game status to active; only one caller can move the game out of
+	// waiting, so a manual start and an auto-start can't both run it
+	now := time.Now()
+	result := s.db.WithContext(ctx).Model(&game).Where("status = ?", "waiting").
+		Updates(map[string]interface{}{"status": "active", "started_at": now})
+	if result.Error != nil {
+		return nil, result.Error
 	}
+	if result.RowsAffected == 0 {
+		return nil, ErrGameAlreadyStarted
+	}
+	game.StartedAt = &now
 
 	// Get current players from database
 	var players []models.Player
-	s.db.Where("game_id = ?", game.ID).Find(&players)
+	s.db.WithContext(ctx).Where("game_id = ?", game.ID).Find(&players)
 
 	// Get or create game state in Redis
-	gameState := s.getGameState(normalizedPin)
+	gameState := s.getGameState(ctx, normalizedPin)
 	if gameState == nil {
 		// Create new game state if it doesn't exist
 		gameState = &GameState{
@@ -170,6 +548,10 @@ func (s *GameService) StartQuiz(gamePin string, userID uint) (*models.Game, erro
 		gameState.Status = "active"
 		gameState.TotalQuestions = len(game.Quiz.Questions)
 	}
+	if game.ShuffleQuestions {
+		gameState.QuestionOrder = questionOrder(&game)
+	}
+	gameState.ShuffleOptions = game.ShuffleOptions
 
 	// Update players in game state
 	gameState.Players = []GamePlayer{}
@@ -181,280 +563,834 @@ func (s *GameService) StartQuiz(gamePin string, userID uint) (*models.Game, erro
 		})
 	}
 
+	// Games are finished automatically once they run past their duration
+	var gameEndsAt time.Time
+	if duration := s.gameDuration(&game); duration > 0 {
+		gameEndsAt = time.Now().Add(duration)
+		gameState.GameEndsAt = &gameEndsAt
+	}
+
 	// Store the updated game state
-	if err := s.storeGameState(normalizedPin, gameState); err != nil {
-		log.Printf("Failed to update game state in Redis: %v", err)
+	if err := s.storeGameState(ctx, normalizedPin, gameState); err != nil {
+		s.logger.Error("Failed to update game state in Redis", "game_pin", normalizedPin, "error", err)
 		return nil, errors.New("failed to update game state")
 	}
 
-	log.Printf("Quiz started for game %s. Ready to start first question...", gamePin)
+	if gameState.GameEndsAt != nil {
+		go s.runGameDeadline(context.WithoutCancel(ctx), normalizedPin, gameEndsAt, hub)
+	}
+
+	metrics.GamesStarted.Inc()
+	metrics.ActiveGames.Inc()
+
+	s.logger.Info("Quiz started, ready to start first question", "game_pin", normalizedPin)
 	return &game, nil
 }
 
 // StartQuestion starts a specific question with timer
-func (s *GameService) StartQuestion(gamePin string, questionIndex int, hub *Hub) error {
+func (s *GameService) StartQuestion(ctx context.Context, gamePin string, questionIndex int, hub *Hub) error {
 	normalizedPin := strings.ToLower(gamePin)
 
 	// Get game with quiz and questions
 	var game models.Game
-	if err := s.db.Where("LOWER(pin) = ?", normalizedPin).
-		Preload("Quiz").
-		Preload("Quiz.Questions").
-		Preload("Quiz.Questions.Options").
-		First(&game).Error; err != nil {
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Where("LOWER(pin) = ?", normalizedPin).
+			Scopes(preloadOrderedQuestions).
+			First(&game).Error
+	}); err != nil {
 		return errors.New("game not found")
 	}
+	s.applyQuestionOrder(ctx, &game)
 
 	if questionIndex >= len(game.Quiz.Questions) {
 		return errors.New("question index out of range")
 	}
 
-	question := game.Quiz.Questions[questionIndex]
-
-	// Update game state in Redis
-	gameState := s.getGameState(normalizedPin)
-	if gameState == nil {
-		return errors.New("game state not found in Redis")
-	}
-
-	gameState.CurrentQuestionIndex = questionIndex
-	gameState.CurrentQuestion = &GameQuestion{
-		ID:        question.ID,
-		Text:      question.Text,
-		TimeLimit: question.TimeLimit,
-		Options:   make([]GameOption, len(question.Options)),
-		TimeLeft:  question.TimeLimit,
+	// Update game state in Redis, rebuilding it if the key was lost
+	gameState, err := s.gameStateOrRebuild(ctx, &game)
+	if err != nil {
+		return err
 	}
 
-	// Copy options WITHOUT revealing correct answers during active quiz
-	for i, option := range question.Options {
-		gameState.CurrentQuestion.Options[i] = GameOption{
-			ID:   option.ID,
-			Text: option.Text,
-			// IsCorrect is intentionally omitted during active quiz
+	// Count down first; the question and its timer start once it runs out
+	if countdown := questionCountdown(&game.Quiz); countdown > 0 && hub != nil {
+		startsAt := time.Now().Add(time.Duration(countdown) * time.Second)
+		gameState.CurrentQuestionIndex = questionIndex
+		gameState.CurrentQuestion = nil
+		gameState.HostQuestion = nil
+		gameState.QuestionStartedAt = nil
+		gameState.QuestionEndsAt = nil
+		gameState.RevealEndsAt = nil
+		gameState.QuestionStartsAt = &startsAt
+
+		if err := s.storeGameState(ctx, normalizedPin, gameState); err != nil {
+			s.logger.Error("Failed to store game state", "game_pin", normalizedPin, "error", err)
+			return errors.New("failed to update game state")
 		}
+
+		go s.runQuestionCountdown(context.WithoutCancel(ctx), &game, questionIndex, countdown, hub)
+		return nil
 	}
 
-	if err := s.storeGameState(normalizedPin, gameState); err != nil {
-		log.Printf("Failed to store game state: %v", err)
+	return s.beginQuestion(ctx, &game, gameState, questionIndex, hub)
+}
+
+// beginQuestion shows a question to the game and starts its timer
+func (s *GameService) beginQuestion(ctx context.Context, game *models.Game, gameState *GameState, questionIndex int, hub *Hub) error {
+	normalizedPin := strings.ToLower(game.Pin)
+	question := game.Quiz.Questions[questionIndex]
+
+	startedAt := time.Now()
+	endsAt := startedAt.Add(time.Duration(question.TimeLimit) * time.Second)
+	gameState.CurrentQuestionIndex = questionIndex
+	gameState.QuestionStartedAt = &startedAt
+	gameState.QuestionEndsAt = &endsAt
+	gameState.RevealEndsAt = nil
+	gameState.QuestionStartsAt = nil
+	// Options are copied WITHOUT revealing correct answers during active quiz
+	gameState.CurrentQuestion = toGameQuestion(question, false)
+	// Only the host's copy includes IsCorrect, and only when the game asks for it
+	gameState.HostQuestion = nil
+	if game.ShowAnswersToHost {
+		gameState.HostQuestion = toGameQuestion(question, true)
+	}
+
+	if err := s.storeGameState(ctx, normalizedPin, gameState); err != nil {
+		s.logger.Error("Failed to store game state", "game_pin", normalizedPin, "error", err)
 		return errors.New("failed to update game state")
 	}
 
 	// Broadcast question start to all connected clients
 	if hub != nil {
-		log.Printf("Broadcasting question start to game %s: question %d", normalizedPin, questionIndex)
-
-		// Create question data for broadcast (without correct answers)
-		broadcastQuestion := gin.H{
-			"id":         question.ID,
-			"text":       question.Text,
-			"time_limit": question.TimeLimit,
-			"options":    gameState.CurrentQuestion.Options, // This doesn't include IsCorrect
-		}
+		s.logger.Debug("Broadcasting question start", "game_pin", normalizedPin, "question_index", questionIndex)
 
-		hub.BroadcastToGame(normalizedPin, "question_start", gin.H{
-			"question_index":  questionIndex,
-			"question":        broadcastQuestion,
-			"total_questions": len(game.Quiz.Questions),
-		})
+		// Players may each get their own order of the options
+		hub.BroadcastQuestionStart(normalizedPin, questionIndex, gameState.CurrentQuestion, gameState.HostQuestion, len(game.Quiz.Questions), game.ShuffleOptions)
 
 		// Start timer for this question
-		go s.runQuestionTimer(normalizedPin, questionIndex, question.TimeLimit, hub)
+		go s.runQuestionTimer(context.WithoutCancel(ctx), normalizedPin, questionIndex, question.TimeLimit, hub)
 	}
 
 	return nil
 }
 
-// NextQuestion advances to the next question or ends the quiz
-func (s *GameService) NextQuestion(gamePin string, hub *Hub) error {
-	normalizedPin := strings.ToLower(gamePin)
+// runQuestionCountdown broadcasts a question_countdown event each second
+// before a question appears, then starts it unless the game has moved on
+func (s *GameService) runQuestionCountdown(ctx context.Context, game *models.Game, questionIndex int, seconds int, hub *Hub) {
+	normalizedPin := strings.ToLower(game.Pin)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 
-	// Get current game state
-	gameState := s.getGameState(normalizedPin)
-	if gameState == nil {
-		log.Printf("Game state not found for pin: %s", normalizedPin)
-		return errors.New("game state not found")
+	for secondsLeft := seconds; secondsLeft > 0; secondsLeft-- {
+		hub.BroadcastToGame(normalizedPin, "question_countdown", gin.H{
+			"question_index": questionIndex,
+			"seconds_left":   secondsLeft,
+		})
+		<-ticker.C
+	}
+
+	// The game may have been ended or cancelled meanwhile
+	gameState := s.getGameState(ctx, normalizedPin)
+	if gameState == nil || gameState.Status != "active" || gameState.CurrentQuestionIndex != questionIndex ||
+		gameState.QuestionStartsAt == nil {
+		s.logger.Debug("Game moved on during question countdown", "game_pin", normalizedPin, "question_index", questionIndex)
+		return
+	}
+
+	if err := s.beginQuestion(ctx, game, gameState, questionIndex, hub); err != nil {
+		s.logger.Error("Failed to start question after countdown", "game_pin", normalizedPin, "question_index", questionIndex, "error", err)
+	}
+}
+
+// toGameQuestion converts a question into the shape games broadcast, with its
+// time fully left. Correct answers are only included when revealAnswers is set.
+func toGameQuestion(question models.Question, revealAnswers bool) *GameQuestion {
+	gameQuestion := &GameQuestion{
+		ID:        question.ID,
+		Text:      question.Text,
+		Type:      question.Type,
+		TimeLimit: question.TimeLimit,
+		Options:   make([]GameOption, len(question.Options)),
+		TimeLeft:  question.TimeLimit,
+	}
+	for i, option := range question.Options {
+		gameQuestion.Options[i] = GameOption{
+			ID:   option.ID,
+			Text: option.Text,
+		}
+		if revealAnswers {
+			isCorrect := option.IsCorrect
+			gameQuestion.Options[i].IsCorrect = &isCorrect
+		}
 	}
+	return gameQuestion
+}
+
+// NextQuestion advances to the next question or ends the quiz
+func (s *GameService) NextQuestion(ctx context.Context, gamePin string, hub *Hub) error {
+	normalizedPin := strings.ToLower(gamePin)
 
 	// Get game with quiz to check total questions
 	var game models.Game
-	if err := s.db.Where("LOWER(pin) = ?", normalizedPin).
-		Preload("Quiz").
-		Preload("Quiz.Questions").
-		Preload("Quiz.Questions.Options").
-		First(&game).Error; err != nil {
-		log.Printf("Game not found in database for pin: %s", normalizedPin)
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Where("LOWER(pin) = ?", normalizedPin).
+			Scopes(preloadOrderedQuestions).
+			First(&game).Error
+	}); err != nil {
+		s.logger.Warn("Game not found in database", "game_pin", normalizedPin)
 		return errors.New("game not found")
 	}
+	s.applyQuestionOrder(ctx, &game)
+
+	// Get current game state, rebuilding it if the key was lost
+	gameState, err := s.gameStateOrRebuild(ctx, &game)
+	if err != nil {
+		return err
+	}
+
+	// Give players the whole reveal window to see the results
+	if gameState.RevealEndsAt != nil && time.Now().Before(*gameState.RevealEndsAt) {
+		return fmt.Errorf("%w: %d seconds left", ErrRevealInProgress, secondsUntil(*gameState.RevealEndsAt))
+	}
+	if gameState.QuestionStartsAt != nil {
+		return ErrQuestionStarting
+	}
 
 	nextQuestionIndex := gameState.CurrentQuestionIndex + 1
-	log.Printf("Next question index: %d, Total questions: %d", nextQuestionIndex, len(game.Quiz.Questions))
+	s.logger.Debug("Advancing to next question", "game_pin", normalizedPin, "question_index", nextQuestionIndex, "total_questions", len(game.Quiz.Questions))
 
 	if nextQuestionIndex >= len(game.Quiz.Questions) {
 		// Quiz is finished
-		log.Printf("Quiz finished for game %s", normalizedPin)
+		s.logger.Info("Quiz finished", "game_pin", normalizedPin)
+		return s.FinishGame(ctx, normalizedPin, &game, gameState, hub, "completed")
+	}
 
-		if err := s.db.Model(&game).Update("status", "finished").Error; err != nil {
-			return err
-		}
+	// Start the next question
+	return s.StartQuestion(ctx, normalizedPin, nextQuestionIndex, hub)
+}
 
-		// Update game state
-		gameState.Status = "finished"
-		gameState.CurrentQuestion = nil
-		gameState.CurrentQuestionIndex = len(game.Quiz.Questions) - 1 // Set to last question index to indicate completion
+// EndGame lets the game's owner finish an active game before its last
+// question; players get the same game_end as when a game completes
+func (s *GameService) EndGame(ctx context.Context, gamePin string, userID uint, hub *Hub) error {
+	normalizedPin := strings.ToLower(gamePin)
 
-		if err := s.storeGameState(normalizedPin, gameState); err != nil {
-			log.Printf("Failed to store final game state: %v", err)
+	var game models.Game
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Where("LOWER(pin) = ?", normalizedPin).
+			Scopes(preloadOrderedQuestions).
+			First(&game).Error
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrGameNotFound
 		}
+		return err
+	}
+	s.applyQuestionOrder(ctx, &game)
+	if game.Quiz.UserID != userID {
+		return ErrNotGameOwner
+	}
+	if game.Status != "active" {
+		return ErrGameNotActive
+	}
 
-		// Get final leaderboard
-		var players []models.Player
-		s.db.Where("game_id = ?", game.ID).Order("score DESC").Find(&players)
-
-		finalLeaderboard := []GamePlayer{}
-		for _, player := range players {
-			finalLeaderboard = append(finalLeaderboard, GamePlayer{
-				ID:    player.ID,
-				Name:  player.Name,
-				Score: player.Score,
-			})
+	gameState, err := s.gameStateOrRebuild(ctx, &game)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("Host ended game early", "game_pin", normalizedPin, "question_index", gameState.CurrentQuestionIndex)
+	return s.FinishGame(ctx, normalizedPin, &game, gameState, hub, "ended_by_host")
+}
+
+// EndGameForDepartedCreator finishes a game whose creator left and didn't
+// come back in time, the same way as any other early end
+func (s *GameService) EndGameForDepartedCreator(ctx context.Context, gamePin string, hub *Hub) error {
+	normalizedPin := strings.ToLower(gamePin)
+
+	var game models.Game
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Where("LOWER(pin) = ?", normalizedPin).
+			Scopes(preloadOrderedQuestions).
+			First(&game).Error
+	}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrGameNotFound
 		}
+		return err
+	}
+	s.applyQuestionOrder(ctx, &game)
+	if game.Status == "finished" {
+		return nil
+	}
 
-		// Broadcast quiz end with final results
-		if hub != nil {
-			hub.BroadcastToGame(normalizedPin, "game_end", gin.H{
-				"message":           "Quiz completed! Here are the final results:",
-				"final_leaderboard": finalLeaderboard,
-				"total_questions":   len(game.Quiz.Questions),
-			})
+	// A game still in its lobby has no state to rebuild from answers, but
+	// its final state is stored all the same
+	gameState := s.getGameState(ctx, normalizedPin)
+	if gameState == nil {
+		var err error
+		if gameState, err = s.rebuildGameState(ctx, &game); err != nil {
+			return err
 		}
+	}
+
+	s.logger.Info("Ending game after creator left", "game_pin", normalizedPin, "status", game.Status)
+	return s.FinishGame(ctx, normalizedPin, &game, gameState, hub, "creator_disconnected")
+}
 
+// FinishGame marks the game finished and broadcasts the final leaderboard.
+// It runs at most once per game, whether the last question ended, the game
+// ran out of time or the host ended it early.
+func (s *GameService) FinishGame(ctx context.Context, normalizedPin string, game *models.Game, gameState *GameState, hub *Hub, reason string) error {
+	if !s.markGameFinished(ctx, normalizedPin) {
+		s.logger.Debug("Game already finished", "game_pin", normalizedPin)
 		return nil
 	}
 
-	// Start the next question
-	return s.StartQuestion(normalizedPin, nextQuestionIndex, hub)
-}
+	wasActive := game.Status == "active"
+	endedAt := time.Now()
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Model(game).Updates(map[string]interface{}{"status": "finished", "ended_at": endedAt}).Error
+	}); err != nil {
+		return err
+	}
+	game.EndedAt = &endedAt
 
-// runQuestionTimer runs a countdown timer for a question
-func (s *GameService) runQuestionTimer(gamePin string, questionIndex int, timeLimit int, hub *Hub) {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+	metrics.GamesFinished.Inc()
+	if wasActive {
+		metrics.ActiveGames.Dec()
+	}
 
-	timeLeft := timeLimit
-	normalizedPin := strings.ToLower(gamePin)
-	log.Printf("Starting timer for question %d in game %s: %d seconds", questionIndex, normalizedPin, timeLimit)
+	// Stop the running question's timer without scoring it
+	if gameState.CurrentQuestion != nil {
+		s.markQuestionEnded(ctx, normalizedPin, gameState.CurrentQuestionIndex)
+	}
 
-	for timeLeft > 0 {
-		<-ticker.C
-		timeLeft--
+	// Update game state
+	gameState.Status = "finished"
+	gameState.CurrentQuestion = nil
+	gameState.HostQuestion = nil
+	gameState.QuestionStartsAt = nil
+	gameState.QuestionStartedAt = nil
+	gameState.QuestionEndsAt = nil
+	gameState.GameEndsAt = nil
+	gameState.CurrentQuestionIndex = len(game.Quiz.Questions) - 1 // Set to last question index to indicate completion
 
-		// Update game state with current time
-		gameState := s.getGameState(normalizedPin)
-		if gameState != nil && gameState.CurrentQuestion != nil {
-			gameState.CurrentQuestion.TimeLeft = timeLeft
-			s.storeGameState(normalizedPin, gameState)
-		}
+	if err := s.storeGameState(ctx, normalizedPin, gameState); err != nil {
+		s.logger.Error("Failed to store final game state", "game_pin", normalizedPin, "error", err)
+	}
 
-		// Broadcast timer update every second
-		if hub != nil {
-			hub.BroadcastToGame(normalizedPin, "timer_update", gin.H{
-				"question_index": questionIndex,
-				"time_left":      timeLeft,
-			})
+	// Keep the final state briefly for clients still syncing, then free it
+	// rather than waiting out the state TTL
+	time.AfterFunc(s.finishedGrace, func() {
+		if err := s.ClearGameState(context.WithoutCancel(ctx), normalizedPin); err != nil {
+			s.logger.Error("Failed to clear finished game state", "game_pin", normalizedPin, "error", err)
 		}
+	})
 
-		// Log timer updates for debugging
-		if timeLeft%10 == 0 || timeLeft <= 5 {
-			log.Printf("Timer for question %d in game %s: %d seconds remaining", questionIndex, normalizedPin, timeLeft)
-		}
-	}
+	finalLeaderboard := s.rankedLeaderboard(ctx, game.ID)
 
-	log.Printf("Timer expired for question %d in game %s", questionIndex, normalizedPin)
+	message := "Quiz completed! Here are the final results:"
+	switch reason {
+	case "time_limit_reached":
+		message = "Time's up for this game! Here are the final results:"
+	case "ended_by_host":
+		message = "The host ended the game early. Here are the final results:"
+	case "creator_disconnected":
+		message = "Quiz creator has left the game. Here are the final results:"
+	}
 
-	// Time's up! End the question and show results
+	// Broadcast quiz end with final results
 	if hub != nil {
-		s.EndQuestion(normalizedPin, hub, questionIndex)
+		hub.BroadcastToGame(normalizedPin, "game_end", gin.H{
+			"message":           message,
+			"reason":            reason,
+			"final_leaderboard": finalLeaderboard,
+			"podium":            podium(finalLeaderboard),
+			"total_questions":   len(game.Quiz.Questions),
+		})
 	}
-}
 
-// EndQuestion ends the current question and shows results with correct answers
-func (s *GameService) EndQuestion(gamePin string, hub *Hub, questionIndex int) error {
-	normalizedPin := strings.ToLower(gamePin)
+	return nil
+}
 
-	// Get game and question details
+// GetLeaderboard returns the game's current standings with ranks, limited to
+// the top limit players when limit is positive
+func (s *GameService) GetLeaderboard(ctx context.Context, gamePin string, limit int) ([]GamePlayer, error) {
 	var game models.Game
-	if err := s.db.Where("LOWER(pin) = ?", normalizedPin).
-		Preload("Quiz").
-		Preload("Quiz.Questions").
-		Preload("Quiz.Questions.Options").
-		First(&game).Error; err != nil {
-		return errors.New("game not found")
+	if err := s.db.WithContext(ctx).Where("LOWER(pin) = ?", strings.ToLower(gamePin)).First(&game).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGameNotFound
+		}
+		return nil, err
 	}
+	return s.topPlayers(ctx, game.ID, limit), nil
+}
 
-	if questionIndex >= len(game.Quiz.Questions) {
-		return errors.New("invalid question index")
+// refreshLeaderboard re-ranks the game's players and stores the result under
+// its own Redis key, so it never rewrites the game state the question timer
+// and transitions update
+func (s *GameService) refreshLeaderboard(ctx context.Context, normalizedPin string, gameID uint) []GamePlayer {
+	leaderboard := s.rankedLeaderboard(ctx, gameID)
+	data, err := json.Marshal(leaderboard)
+	if err != nil {
+		s.logger.Error("Failed to marshal leaderboard", "game_pin", normalizedPin, "error", err)
+		return leaderboard
 	}
+	if err := s.redis.Set(ctx, s.gameKey(normalizedPin, "leaderboard"), data, s.eventLogExpiration(ctx, normalizedPin)).Err(); err != nil {
+		s.logger.Error("Failed to store leaderboard", "game_pin", normalizedPin, "error", err)
+	}
+	return leaderboard
+}
 
-	question := game.Quiz.Questions[questionIndex]
-
-	// Get all answers for this question
-	var gameAnswers []models.GameAnswer
-	if err := s.db.Where("game_id = ? AND question_id = ?", game.ID, question.ID).
-		Preload("Player").
-		Find(&gameAnswers).Error; err != nil {
-		log.Printf("Error fetching answers: %v", err)
+// storedLeaderboard returns the leaderboard last stored for the game, ranking
+// it afresh when none is stored
+func (s *GameService) storedLeaderboard(ctx context.Context, normalizedPin string, gameID uint) []GamePlayer {
+	data, err := s.redis.Get(ctx, s.gameKey(normalizedPin, "leaderboard")).Bytes()
+	if err == nil {
+		var leaderboard []GamePlayer
+		if err := json.Unmarshal(data, &leaderboard); err == nil {
+			return leaderboard
+		}
+	} else if err != redis.Nil {
+		s.logger.Warn("Failed to load leaderboard", "game_pin", normalizedPin, "error", err)
 	}
+	return s.rankedLeaderboard(ctx, gameID)
+}
 
-	// Get all players in the game to include those who didn't answer
-	var allPlayers []models.Player
-	if err := s.db.Where("game_id = ?", game.ID).Find(&allPlayers).Error; err != nil {
-		log.Printf("Error fetching players: %v", err)
+// topPlayers returns the first limit entries of the ranked leaderboard, or all
+// of them when limit isn't positive
+func (s *GameService) topPlayers(ctx context.Context, gameID uint, limit int) []GamePlayer {
+	leaderboard := s.rankedLeaderboard(ctx, gameID)
+	if limit > 0 && len(leaderboard) > limit {
+		leaderboard = leaderboard[:limit]
 	}
+	return leaderboard
+}
 
-	// Create a map of players who answered
-	answeredPlayers := make(map[uint]bool)
-	for _, answer := range gameAnswers {
-		answeredPlayers[answer.PlayerID] = true
+// rankedLeaderboard returns the game's players ordered for the standings.
+// Equal scores are broken by the lower total answer time; players level on
+// both share a rank, and the next rank skips accordingly (1, 1, 3)
+func (s *GameService) rankedLeaderboard(ctx context.Context, gameID uint) []GamePlayer {
+	var players []models.Player
+	if err := s.db.WithContext(ctx).Where("game_id = ?", gameID).Order("id").Find(&players).Error; err != nil {
+		s.logger.Error("Error fetching players for leaderboard", "game_id", gameID, "error", err)
+	}
+
+	var totals []struct {
+		PlayerID  uint
+		TimeSpent int
+	}
+	if err := s.db.WithContext(ctx).Model(&models.GameAnswer{}).
+		Select("player_id, SUM(time_spent) AS time_spent").
+		Where("game_id = ?", gameID).
+		Group("player_id").
+		Scan(&totals).Error; err != nil {
+		s.logger.Error("Error fetching answer times for leaderboard", "game_id", gameID, "error", err)
+	}
+	timeSpent := make(map[uint]int, len(totals))
+	for _, total := range totals {
+		timeSpent[total.PlayerID] = total.TimeSpent
+	}
+
+	sort.SliceStable(players, func(i, j int) bool {
+		if players[i].Score != players[j].Score {
+			return players[i].Score > players[j].Score
+		}
+		return timeSpent[players[i].ID] < timeSpent[players[j].ID]
+	})
+
+	leaderboard := make([]GamePlayer, len(players))
+	for i, player := range players {
+		rank := i + 1
+		if i > 0 {
+			prev := players[i-1]
+			if prev.Score == player.Score && timeSpent[prev.ID] == timeSpent[player.ID] {
+				rank = leaderboard[i-1].Rank
+			}
+		}
+		leaderboard[i] = GamePlayer{
+			ID:    player.ID,
+			Name:  player.Name,
+			Score: player.Score,
+			Rank:  rank,
+		}
+	}
+	return leaderboard
+}
+
+// podium returns the ranked players in the top three places, which can be
+// more than three players when some are tied
+func podium(leaderboard []GamePlayer) []GamePlayer {
+	top := []GamePlayer{}
+	for _, player := range leaderboard {
+		if player.Rank > 3 {
+			break
+		}
+		top = append(top, player)
+	}
+	return top
+}
+
+// gameDuration is how long the game may run once started: its own max
+// duration capped by the server ceiling, or the ceiling when it has none
+func (s *GameService) gameDuration(game *models.Game) time.Duration {
+	duration := time.Duration(game.MaxDuration) * time.Minute
+	if duration <= 0 || (s.maxGameDuration > 0 && duration > s.maxGameDuration) {
+		return s.maxGameDuration
+	}
+	return duration
+}
+
+// CheckAutoStart begins the lobby countdown for a game that opted into
+// auto-start once enough players have joined. It does nothing for games
+// without a threshold, games already counting down, and games that started
+func (s *GameService) CheckAutoStart(ctx context.Context, gamePin string, hub *Hub) {
+	normalizedPin := strings.ToLower(gamePin)
+
+	var game models.Game
+	if err := s.db.WithContext(ctx).Where("LOWER(pin) = ?", normalizedPin).Preload("Quiz").First(&game).Error; err != nil {
+		return
+	}
+	if game.AutoStartPlayers <= 0 || game.Status != "waiting" || hub == nil {
+		return
+	}
+
+	var playerCount int64
+	if err := s.db.WithContext(ctx).Model(&models.Player{}).Where("game_id = ?", game.ID).Count(&playerCount).Error; err != nil {
+		s.logger.Error("Failed to count players for auto-start", "game_pin", normalizedPin, "error", err)
+		return
+	}
+	if playerCount < int64(game.AutoStartPlayers) || playerCount < int64(game.MinPlayers) {
+		return
+	}
+
+	s.autoStartMu.Lock()
+	if _, running := s.autoStarts[normalizedPin]; running {
+		s.autoStartMu.Unlock()
+		return
+	}
+	// The countdown outlives the request that filled the lobby
+	countdown, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.autoStarts[normalizedPin] = cancel
+	s.autoStartMu.Unlock()
+
+	s.logger.Info("Lobby full, starting auto-start countdown", "game_pin", normalizedPin, "players", playerCount)
+	go s.runAutoStartCountdown(countdown, normalizedPin, game.Quiz.UserID, hub)
+}
+
+// runAutoStartCountdown broadcasts a countdown event each second and then
+// starts the game on the owner's behalf, unless it is cancelled first
+func (s *GameService) runAutoStartCountdown(ctx context.Context, normalizedPin string, ownerID uint, hub *Hub) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for secondsLeft := autoStartCountdown; secondsLeft > 0; secondsLeft-- {
+		hub.BroadcastToGame(normalizedPin, "countdown", gin.H{"seconds_left": secondsLeft})
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Auto-start countdown cancelled", "game_pin", normalizedPin)
+			return
+		case <-ticker.C:
+		}
+	}
+
+	// Drop the countdown before starting so StartQuiz doesn't cancel itself
+	s.autoStartMu.Lock()
+	delete(s.autoStarts, normalizedPin)
+	s.autoStartMu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+
+	if _, err := s.StartQuiz(ctx, normalizedPin, ownerID, hub); err != nil {
+		s.logger.Warn("Auto-start failed", "game_pin", normalizedPin, "error", err)
+		return
+	}
+	if err := s.StartQuestion(ctx, normalizedPin, 0, hub); err != nil {
+		s.logger.Error("Error starting first question after auto-start", "game_pin", normalizedPin, "error", err)
+	}
+}
+
+// cancelAutoStart stops a running auto-start countdown for the game, if any
+func (s *GameService) cancelAutoStart(normalizedPin string) {
+	s.autoStartMu.Lock()
+	defer s.autoStartMu.Unlock()
+	if cancel, running := s.autoStarts[normalizedPin]; running {
+		cancel()
+		delete(s.autoStarts, normalizedPin)
+	}
+}
+
+// ResumeActiveGames picks up games that were running when the server stopped:
+// it restores their Redis state, re-arms their deadline and lets the current
+// question's countdown or timer run out the time it had left, starting or
+// ending the question straight away if that ran out while the server was down.
+// It returns how many games were resumed.
+func (s *GameService) ResumeActiveGames(ctx context.Context, hub *Hub) int {
+	var games []models.Game
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Where("status = ?", "active").
+			Scopes(preloadOrderedQuestions).
+			Find(&games).Error
+	}); err != nil {
+		s.logger.Error("Failed to load active games to resume", "error", err)
+		return 0
+	}
+
+	resumed := 0
+	for i := range games {
+		if s.resumeGame(ctx, &games[i], hub) {
+			resumed++
+		}
+	}
+
+	return resumed
+}
+
+// resumeGame restores one active game's state and re-arms its deadline and
+// whichever of the question countdown or timer was running
+func (s *GameService) resumeGame(ctx context.Context, game *models.Game, hub *Hub) bool {
+	s.applyQuestionOrder(ctx, game)
+	normalizedPin := strings.ToLower(game.Pin)
+
+	gameState, err := s.gameStateOrRebuild(ctx, game)
+	if err != nil {
+		s.logger.Error("Failed to restore game state", "game_pin", normalizedPin, "error", err)
+		return false
+	}
+	metrics.ActiveGames.Inc()
+
+	if gameState.GameEndsAt != nil {
+		go s.runGameDeadline(context.WithoutCancel(ctx), normalizedPin, *gameState.GameEndsAt, hub)
+	}
+
+	// A question still counting down starts when the countdown would have run
+	// out, or straight away if that has already passed
+	questionIndex := gameState.CurrentQuestionIndex
+	if gameState.QuestionStartsAt != nil && questionIndex >= 0 && questionIndex < len(game.Quiz.Questions) {
+		if secondsLeft := secondsUntil(*gameState.QuestionStartsAt); secondsLeft > 0 && hub != nil {
+			go s.runQuestionCountdown(context.WithoutCancel(ctx), game, questionIndex, secondsLeft, hub)
+		} else if err := s.beginQuestion(ctx, game, gameState, questionIndex, hub); err != nil {
+			s.logger.Error("Failed to start question after countdown", "game_pin", normalizedPin, "question_index", questionIndex, "error", err)
+		}
+		s.logger.Info("Resumed game and its question countdown", "game_pin", normalizedPin, "question_index", questionIndex)
+		return true
+	}
+
+	// A state rebuilt from the database doesn't know when the question
+	// started, so there is no timer to re-arm and the host moves on by hand
+	if questionIndex < 0 || gameState.QuestionEndsAt == nil || s.isQuestionEnded(ctx, normalizedPin, questionIndex) {
+		s.logger.Info("Resumed game", "game_pin", normalizedPin, "question_index", questionIndex)
+		return true
+	}
+
+	if timeLeft := secondsUntil(*gameState.QuestionEndsAt); timeLeft > 0 {
+		go s.runQuestionTimer(context.WithoutCancel(ctx), normalizedPin, questionIndex, timeLeft, hub)
+	} else {
+		go s.EndQuestion(context.WithoutCancel(ctx), normalizedPin, hub, questionIndex)
+	}
+	s.logger.Info("Resumed game and its question timer", "game_pin", normalizedPin, "question_index", questionIndex)
+	return true
+}
+
+// runGameDeadline finishes the game if it is still active when its deadline
+// passes, so abandoned games don't keep running question timers
+func (s *GameService) runGameDeadline(ctx context.Context, normalizedPin string, deadline time.Time, hub *Hub) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	<-timer.C
+
+	gameState := s.getGameState(ctx, normalizedPin)
+	if gameState == nil || gameState.Status != "active" {
+		return
+	}
+
+	var game models.Game
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Where("LOWER(pin) = ?", normalizedPin).
+			Scopes(preloadOrderedQuestions).
+			First(&game).Error
+	}); err != nil {
+		s.logger.Error("Game not found at its deadline", "game_pin", normalizedPin, "error", err)
+		return
+	}
+	s.applyQuestionOrder(ctx, &game)
+	if game.Status != "active" {
+		return
+	}
+
+	s.logger.Info("Game reached its time limit, finishing", "game_pin", normalizedPin)
+	if err := s.FinishGame(ctx, normalizedPin, &game, gameState, hub, "time_limit_reached"); err != nil {
+		s.logger.Error("Failed to finish game at its time limit", "game_pin", normalizedPin, "error", err)
+	}
+}
+
+// runQuestionTimer runs a countdown timer for a question
+func (s *GameService) runQuestionTimer(ctx context.Context, gamePin string, questionIndex int, timeLimit int, hub *Hub) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	timeLeft := timeLimit
+	normalizedPin := strings.ToLower(gamePin)
+	s.logger.Debug("Starting question timer", "game_pin", normalizedPin, "question_index", questionIndex, "time_limit", timeLimit)
+
+	for timeLeft > 0 {
+		<-ticker.C
+		timeLeft--
+
+		// Stop if the question was already ended early (e.g. everyone answered)
+		if s.isQuestionEnded(ctx, normalizedPin, questionIndex) {
+			s.logger.Debug("Question ended early, stopping timer", "game_pin", normalizedPin, "question_index", questionIndex)
+			return
+		}
+
+		// Update game state with current time
+		gameState := s.getGameState(ctx, normalizedPin)
+		if gameState != nil && gameState.CurrentQuestion != nil {
+			gameState.CurrentQuestion.TimeLeft = timeLeft
+			s.storeGameState(ctx, normalizedPin, gameState)
+		}
+
+		// Broadcast timer update every second
+		if hub != nil {
+			hub.BroadcastToGame(normalizedPin, "timer_update", gin.H{
+				"question_index": questionIndex,
+				"time_left":      timeLeft,
+			})
+		}
+
+		// Log timer updates for debugging
+		if timeLeft%10 == 0 || timeLeft <= 5 {
+			s.logger.Debug("Question timer", "game_pin", normalizedPin, "question_index", questionIndex, "time_left", timeLeft)
+		}
+	}
+
+	s.logger.Debug("Question timer expired", "game_pin", normalizedPin, "question_index", questionIndex)
+
+	// Time's up! End the question and show results
+	if hub != nil {
+		s.EndQuestion(ctx, normalizedPin, hub, questionIndex)
+	}
+}
+
+// EndQuestion ends the current question and shows results with correct answers
+func (s *GameService) EndQuestion(ctx context.Context, gamePin string, hub *Hub, questionIndex int) error {
+	normalizedPin := strings.ToLower(gamePin)
+
+	// Get game and question details
+	var game models.Game
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Where("LOWER(pin) = ?", normalizedPin).
+			Scopes(preloadOrderedQuestions).
+			First(&game).Error
+	}); err != nil {
+		return errors.New("game not found")
+	}
+	s.applyQuestionOrder(ctx, &game)
+
+	if questionIndex >= len(game.Quiz.Questions) {
+		return errors.New("invalid question index")
+	}
+
+	question := game.Quiz.Questions[questionIndex]
+
+	// Results must only be processed once, whether the timer expired or the
+	// question was ended early
+	if !s.markQuestionEnded(ctx, normalizedPin, questionIndex) {
+		s.logger.Debug("Question already ended", "game_pin", normalizedPin, "question_index", questionIndex)
+		return nil
+	}
+
+	// Get all answers for this question
+	var gameAnswers []models.GameAnswer
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Where("game_id = ? AND question_id = ?", game.ID, question.ID).
+			Preload("Player").
+			Find(&gameAnswers).Error
+	}); err != nil {
+		s.logger.Error("Error fetching answers", "game_pin", normalizedPin, "question_index", questionIndex, "error", err)
+	}
+
+	// Get all players in the game to include those who didn't answer
+	var allPlayers []models.Player
+	if err := s.db.WithContext(ctx).Where("game_id = ?", game.ID).Find(&allPlayers).Error; err != nil {
+		s.logger.Error("Error fetching players", "game_pin", normalizedPin, "error", err)
+	}
+
+	// Create a map of players who answered
+	answeredPlayers := make(map[uint]bool)
+	for _, answer := range gameAnswers {
+		answeredPlayers[answer.PlayerID] = true
 	}
 
 	// Process all answers and update scores
+	scoring := quizScoring(&game.Quiz)
+	isPoll := question.Type == models.QuestionTypePoll
+	var fastest *models.GameAnswer
+	if !isPoll {
+		fastest = fastestCorrectAnswer(gameAnswers)
+	}
 	for i := range gameAnswers {
 		answer := &gameAnswers[i]
 
+		// Poll votes have no right answer and score nothing
+		if isPoll {
+			continue
+		}
+
 		// Calculate points based on time spent and correctness
-		points := s.calculatePoints(answer.TimeSpent, question.TimeLimit, answer.IsCorrect)
+		points := s.calculatePoints(scoring, answer.TimeSpent, question.TimeLimit, answer.IsCorrect)
+		if game.Quiz.ConfidenceScoring {
+			points = applyConfidence(points, answer.Confidence, answer.IsCorrect)
+		}
+		if fastest != nil && answer.ID == fastest.ID {
+			points += scoring.fastestBonus
+		}
 
 		// Update the answer with calculated points
 		answer.Points = points
-		if err := s.db.Model(answer).Update("points", points).Error; err != nil {
-			log.Printf("Error updating answer points: %v", err)
+		if err := withDBRetry(func() error {
+			return s.db.WithContext(ctx).Model(answer).Update("points", points).Error
+		}); err != nil {
+			s.logger.Error("Error updating answer points", "game_pin", normalizedPin, "player_id", answer.PlayerID, "error", err)
 		}
 
-		// Update player score
-		if err := s.db.Model(&models.Player{}).Where("id = ?", answer.PlayerID).
-			Update("score", gorm.Expr("score + ?", points)).Error; err != nil {
-			log.Printf("Error updating player score: %v", err)
+		// Update player score; penalties may take it below zero unless the quiz floors it
+		scoreExpr := gorm.Expr("score + ?", points)
+		if game.Quiz.FloorScoreAtZero {
+			scoreExpr = gorm.Expr("GREATEST(score + ?, 0)", points)
+		}
+		if err := withDBRetry(func() error {
+			return s.db.WithContext(ctx).Model(&models.Player{}).Where("id = ?", answer.PlayerID).
+				Update("score", scoreExpr).Error
+		}); err != nil {
+			s.logger.Error("Error updating player score", "game_pin", normalizedPin, "player_id", answer.PlayerID, "error", err)
 		}
 	}
 
 	// Update game state in Redis with new scores
-	gameState := s.getGameState(normalizedPin)
+	gameState := s.getGameState(ctx, normalizedPin)
 	if gameState != nil {
 		// Get updated players with new scores
 		var updatedPlayers []models.Player
-		s.db.Where("game_id = ?", game.ID).Find(&updatedPlayers)
-
-		// Update game state with new player scores
-		gameState.Players = make([]GamePlayer, len(updatedPlayers))
-		for i, player := range updatedPlayers {
-			gameState.Players[i] = GamePlayer{
-				ID:    player.ID,
-				Name:  player.Name,
-				Score: player.Score,
+		if err := withDBRetry(func() error {
+			return s.db.WithContext(ctx).Where("game_id = ?", game.ID).Order("id").Find(&updatedPlayers).Error
+		}); err != nil {
+			// Keep the cached scores rather than wiping them
+			s.logger.Error("Error refreshing player scores", "game_pin", normalizedPin, "error", err)
+		} else {
+			s.refreshLeaderboard(ctx, normalizedPin, game.ID)
+			// Update game state with new player scores
+			gameState.Players = make([]GamePlayer, len(updatedPlayers))
+			for i, player := range updatedPlayers {
+				gameState.Players[i] = GamePlayer{
+					ID:    player.ID,
+					Name:  player.Name,
+					Score: player.Score,
+				}
 			}
+			s.storeGameState(ctx, normalizedPin, gameState)
 		}
-		s.storeGameState(normalizedPin, gameState)
 	}
 
 	// Prepare answer results with correct answer revealed
@@ -462,6 +1398,7 @@ func (s *GameService) EndQuestion(gamePin string, hub *Hub, questionIndex int) e
 	answerResults := []gin.H{}
 
 	// First, add players who answered
+	confidenceDistribution := map[int]int{1: 0, 2: 0, 3: 0}
 	for _, answer := range gameAnswers {
 		answerResults = append(answerResults, gin.H{
 			"player_id":   answer.PlayerID,
@@ -470,7 +1407,11 @@ func (s *GameService) EndQuestion(gamePin string, hub *Hub, questionIndex int) e
 			"is_correct":  answer.IsCorrect,
 			"points":      answer.Points,
 			"time_spent":  answer.TimeSpent,
+			"confidence":  answer.Confidence,
 		})
+		if answer.Confidence > 0 {
+			confidenceDistribution[answer.Confidence]++
+		}
 	}
 
 	// Then add players who didn't answer
@@ -498,205 +1439,1225 @@ func (s *GameService) EndQuestion(gamePin string, hub *Hub, questionIndex int) e
 
 	// Get updated players for broadcast
 	var updatedPlayers []models.Player
-	s.db.Where("game_id = ?", game.ID).Order("score DESC").Find(&updatedPlayers)
+	s.db.WithContext(ctx).Where("game_id = ?", game.ID).Order("score DESC").Find(&updatedPlayers)
 
 	// Broadcast question end with results, correct answer, and updated leaderboard
 	if hub != nil {
-		hub.BroadcastToGame(normalizedPin, "question_end", gin.H{
+		payload := gin.H{
 			"question_index":  questionIndex,
 			"question":        question, // Now includes correct answers
 			"correct_option":  correctOption,
 			"answers":         answerResults,
 			"players":         updatedPlayers, // Updated leaderboard
 			"total_questions": len(game.Quiz.Questions),
+
+			"confidence_distribution": confidenceDistribution,
+		}
+		// Polls have no correct option; show how the votes split instead
+		if isPoll {
+			payload["vote_distribution"] = voteDistribution(question.Options, gameAnswers)
+		} else if fastest != nil {
+			payload["fastest_answer"] = gin.H{
+				"player_id":    fastest.PlayerID,
+				"player_name":  fastest.Player.Name,
+				"time_spent":   fastest.TimeSpent,
+				"bonus_points": scoring.fastestBonus,
+			}
+		} else {
+			payload["fastest_answer"] = nil // nobody answered correctly
+		}
+		hub.BroadcastToGame(normalizedPin, "question_end", payload)
+
+		// Each player who answered also gets their own result and new score
+		scores := make(map[uint]int, len(updatedPlayers))
+		for _, player := range updatedPlayers {
+			scores[player.ID] = player.Score
+		}
+		for _, answer := range gameAnswers {
+			result := gin.H{
+				"question_index": questionIndex,
+				"question_id":    question.ID,
+				"option_id":      answer.OptionID,
+				"points":         answer.Points,
+				"score":          scores[answer.PlayerID],
+			}
+			if !isPoll {
+				result["is_correct"] = answer.IsCorrect
+			}
+			hub.SendToPlayer(normalizedPin, answer.PlayerID, "answer_result", result)
+		}
+
+		if game.ShowLeaderboard {
+			hub.BroadcastToGame(normalizedPin, "leaderboard_update", gin.H{
+				"question_index": questionIndex,
+				"leaderboard":    s.topPlayers(ctx, game.ID, leaderboardUpdateSize),
+			})
+		}
+	}
+
+	// Hold the results on screen before the host can move on
+	if reveal := revealSeconds(&game.Quiz); reveal > 0 {
+		if gameState := s.getGameState(ctx, normalizedPin); gameState != nil {
+			revealEndsAt := time.Now().Add(time.Duration(reveal) * time.Second)
+			gameState.RevealEndsAt = &revealEndsAt
+			s.storeGameState(ctx, normalizedPin, gameState)
+		}
+		if hub != nil {
+			go s.runRevealCountdown(context.WithoutCancel(ctx), normalizedPin, questionIndex, reveal, hub)
+		}
+	}
+
+	return nil
+}
+
+// revealSeconds returns how long a quiz shows each question's results
+func revealSeconds(quiz *models.Quiz) int {
+	if quiz.RevealSeconds != nil {
+		return *quiz.RevealSeconds
+	}
+	return defaultRevealSeconds
+}
+
+// questionCountdown returns how many seconds a quiz counts down before each question
+func questionCountdown(quiz *models.Quiz) int {
+	if quiz.QuestionCountdown != nil {
+		return *quiz.QuestionCountdown
+	}
+	return defaultQuestionCountdown
+}
+
+// runRevealCountdown broadcasts a reveal_countdown event each second while a
+// question's results are shown
+func (s *GameService) runRevealCountdown(ctx context.Context, normalizedPin string, questionIndex int, seconds int, hub *Hub) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for secondsLeft := seconds; secondsLeft > 0; secondsLeft-- {
+		hub.BroadcastToGame(normalizedPin, "reveal_countdown", gin.H{
+			"question_index": questionIndex,
+			"seconds_left":   secondsLeft,
 		})
+		<-ticker.C
+	}
+}
+
+// fastestCorrectAnswer returns the correct answer given in the least time, the
+// earlier submission winning a tie, or nil if no answer was correct
+func fastestCorrectAnswer(answers []models.GameAnswer) *models.GameAnswer {
+	var fastest *models.GameAnswer
+	for i := range answers {
+		answer := &answers[i]
+		if !answer.IsCorrect {
+			continue
+		}
+		if fastest == nil || answer.TimeSpent < fastest.TimeSpent ||
+			(answer.TimeSpent == fastest.TimeSpent && answer.CreatedAt.Before(fastest.CreatedAt)) {
+			fastest = answer
+		}
+	}
+	return fastest
+}
+
+// voteDistribution counts the answers for each of a question's options keyed by
+// option ID, including options nobody picked
+func voteDistribution(options []models.Option, answers []models.GameAnswer) map[uint]int {
+	votes := make(map[uint]int, len(options))
+	for _, option := range options {
+		votes[option.ID] = 0
+	}
+	for _, answer := range answers {
+		votes[answer.OptionID]++
+	}
+	return votes
+}
+
+// GetUserGames lists the games hosted by the user across all of their quizzes,
+// newest first. From and To filter on the creation date and are inclusive.
+func (s *GameService) GetUserGames(ctx context.Context, userID uint, query *ListGamesQuery) (*GameListResponse, error) {
+	page := query.Page
+	if page == 0 {
+		page = 1
+	}
+	pageSize := query.PageSize
+	if pageSize == 0 {
+		pageSize = 20
+	}
+
+	filtered := s.db.WithContext(ctx).Model(&models.Game{}).
+		Joins("JOIN quizzes ON quizzes.id = games.quiz_id AND quizzes.deleted_at IS NULL").
+		Where("quizzes.user_id = ?", userID)
+	if query.Status != "" {
+		filtered = filtered.Where("games.status = ?", query.Status)
+	}
+	if !query.From.IsZero() {
+		filtered = filtered.Where("games.created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		filtered = filtered.Where("games.created_at < ?", query.To.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := filtered.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	games := []GameSummary{}
+	err := filtered.
+		Select("games.id, games.pin, games.quiz_id, quizzes.title AS quiz_title, games.status, " +
+			"games.scheduled_at, games.started_at, games.ended_at, games.created_at, " +
+			"COUNT(players.id) AS player_count").
+		Joins("LEFT JOIN players ON players.game_id = games.id AND players.deleted_at IS NULL").
+		Group("games.id, quizzes.title").
+		Order("games.created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Scan(&games).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range games {
+		games[i].Pin = DisplayPin(games[i].Pin)
+	}
+
+	return &GameListResponse{
+		Games:    games,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	}, nil
+}
+
+// GetActiveGamesForUser lists the waiting and running games of the user's
+// quizzes, newest first, so a host can get back to any of them
+func (s *GameService) GetActiveGamesForUser(ctx context.Context, userID uint) ([]ActiveGame, error) {
+	var summaries []GameSummary
+	err := s.db.WithContext(ctx).Model(&models.Game{}).
+		Select("games.id, games.pin, games.quiz_id, quizzes.title AS quiz_title, games.status, "+
+			"games.scheduled_at, games.started_at, games.ended_at, games.created_at, "+
+			"COUNT(players.id) AS player_count").
+		Joins("JOIN quizzes ON quizzes.id = games.quiz_id AND quizzes.deleted_at IS NULL").
+		Joins("LEFT JOIN players ON players.game_id = games.id AND players.deleted_at IS NULL").
+		Where("quizzes.user_id = ? AND games.status IN ?", userID, []string{"waiting", "active"}).
+		Group("games.id, quizzes.title").
+		Order("games.created_at DESC").
+		Scan(&summaries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	games := make([]ActiveGame, 0, len(summaries))
+	for _, summary := range summaries {
+		game := ActiveGame{GameSummary: summary, CurrentQuestionIndex: -1}
+		if gameState := s.getGameState(ctx, summary.Pin); gameState != nil {
+			game.CurrentQuestionIndex = gameState.CurrentQuestionIndex
+			game.TotalQuestions = gameState.TotalQuestions
+		}
+		game.Pin = DisplayPin(summary.Pin)
+		games = append(games, game)
+	}
+	return games, nil
+}
+
+// GetGuestGames lists the games a guest has joined, most recent first
+func (s *GameService) GetGuestGames(ctx context.Context, guestID string) ([]GuestGame, error) {
+	games := []GuestGame{}
+	err := s.db.WithContext(ctx).Table("players").
+		Select("games.pin, quizzes.title AS quiz_title, games.status, players.name AS player_name, players.score, players.joined_at").
+		Joins("JOIN games ON games.id = players.game_id AND games.deleted_at IS NULL").
+		Joins("JOIN quizzes ON quizzes.id = games.quiz_id").
+		Where("players.guest_id = ? AND players.deleted_at IS NULL", guestID).
+		Order("players.joined_at DESC").
+		Scan(&games).Error
+	for i := range games {
+		games[i].Pin = DisplayPin(games[i].Pin)
+	}
+	return games, err
+}
+
+// IsJoinable reports whether a game with the PIN exists and can be joined,
+// reading only its status so the join screen can check a PIN cheaply
+func (s *GameService) IsJoinable(ctx context.Context, gamePin string) (*JoinableStatus, error) {
+	var statuses []string
+	if err := s.db.WithContext(ctx).Model(&models.Game{}).
+		Where("LOWER(pin) = ?", strings.ToLower(gamePin)).
+		Limit(1).
+		Pluck("status", &statuses).Error; err != nil {
+		return nil, err
+	}
+	if len(statuses) == 0 {
+		return &JoinableStatus{}, nil
+	}
+	return &JoinableStatus{
+		Exists:   true,
+		Joinable: statusJoinable(statuses[0]),
+		Status:   statuses[0],
+	}, nil
+}
+
+// statusJoinable reports whether players may join a game in this status
+func statusJoinable(status string) bool {
+	return status == "waiting" || status == "active"
+}
+
+// JoinGame adds a player to a game, reporting whether an existing player was
+// returned instead because their device had already joined
+func (s *GameService) JoinGame(ctx context.Context, req *JoinGameRequest) (*models.Player, bool, error) {
+	// Clean up the name before it is checked for uniqueness and stored
+	name, err := normalizePlayerName(req.Name)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Name = name
+
+	// Convert PIN to lowercase for case-insensitive search
+	pin := strings.ToLower(req.Pin)
+
+	// First, get the game by PIN
+	var game models.Game
+	if err := s.db.WithContext(ctx).Where("LOWER(pin) = ?", pin).First(&game).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrGameNotFound
+		}
+		return nil, false, err
+	}
+
+	// Check if the game status allows joining
+	if !statusJoinable(game.Status) {
+		return nil, false, ErrGameNotJoinable
+	}
+
+	// A device that already joined gets its player back rather than a second one
+	if game.OneJoinPerDevice {
+		if req.DeviceID == "" {
+			return nil, false, ErrDeviceIDRequired
+		}
+		var existing models.Player
+		err := s.db.WithContext(ctx).Scopes(activePlayers).
+			Where("game_id = ? AND device_id = ?", game.ID, req.DeviceID).
+			First(&existing).Error
+		if err == nil {
+			return &existing, true, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, err
+		}
+	}
+
+	// Check if player name is already taken by an active player in this game,
+	// ignoring case so the leaderboard can't show two look-alike names
+	var existingPlayer models.Player
+	if err := s.db.WithContext(ctx).Scopes(activePlayers).
+		Where("game_id = ? AND LOWER(name) = LOWER(?)", game.ID, req.Name).
+		First(&existingPlayer).Error; err == nil {
+		return nil, false, errors.New("player name already taken")
+	}
+
+	// Create player
+	player := models.Player{
+		GameID:   game.ID,
+		Name:     req.Name,
+		Score:    0,
+		JoinedAt: time.Now(),
+		DeviceID: req.DeviceID,
+		GuestID:  req.GuestID,
+	}
+
+	if err := s.db.WithContext(ctx).Create(&player).Error; err != nil {
+		return nil, false, err
+	}
+
+	// Update game state in Redis
+	normalizedPin := strings.ToLower(game.Pin)
+	gameState := s.getGameState(ctx, normalizedPin)
+	if gameState == nil {
+		// Create new game state if it doesn't exist
+		gameState = &GameState{
+			GameID:               game.ID,
+			QuizID:               game.QuizID,
+			Pin:                  normalizedPin,
+			Status:               game.Status,
+			CurrentQuestionIndex: -1,
+			Players:              []GamePlayer{},
+			ScheduledAt:          game.ScheduledAt,
+		}
+	}
+
+	// Add player to game state
+	gamePlayer := GamePlayer{
+		ID:    player.ID,
+		Name:  player.Name,
+		Score: player.Score,
+	}
+	gameState.Players = append(gameState.Players, gamePlayer)
+	s.storeGameState(ctx, normalizedPin, gameState)
+	s.refreshLeaderboard(ctx, normalizedPin, game.ID)
+
+	return &player, false, nil
+}
+
+func (s *GameService) GetGameByPin(ctx context.Context, pin string) (*models.Game, error) {
+	var game models.Game
+	err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Where("LOWER(pin) = ?", strings.ToLower(pin)).
+			Scopes(preloadOrderedQuestions).
+			Preload("Players").
+			First(&game).Error
+	})
+	s.applyQuestionOrder(ctx, &game)
+	return &game, err
+}
+
+// CancelGame deletes a game that hasn't started, along with the players who
+// joined it, tells connected clients it was cancelled and disconnects them
+func (s *GameService) CancelGame(ctx context.Context, gamePin string, userID uint, hub *Hub) error {
+	normalizedPin := strings.ToLower(gamePin)
+
+	game, err := s.GetGameByPin(ctx, normalizedPin)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrGameNotFound
+		}
+		return err
+	}
+	if game.Quiz.UserID != userID {
+		return ErrNotGameOwner
+	}
+	if game.Status != "waiting" {
+		return ErrGameNotWaiting
+	}
+	s.cancelAutoStart(normalizedPin)
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("game_id = ?", game.ID).Delete(&models.Player{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(game).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if hub != nil {
+		hub.BroadcastToGame(normalizedPin, "game_cancelled", gin.H{
+			"message": "The host cancelled this game.",
+		})
+		hub.DisconnectGame(normalizedPin)
+	}
+
+	// Purged last so the cancellation broadcast doesn't leave an event log behind
+	if err := s.ClearGameState(ctx, normalizedPin); err != nil {
+		s.logger.Error("Failed to purge Redis state for cancelled game", "game_pin", normalizedPin, "error", err)
+	}
+
+	s.logger.Info("Game cancelled by its host", "game_pin", normalizedPin)
+	return nil
+}
+
+// GetGameResults returns each player's final score, ranked, with the share of
+// the maximum possible score and the grade it earns under the quiz's rubric
+func (s *GameService) GetGameResults(ctx context.Context, gamePin string, userID uint) (*GameResults, error) {
+	game, err := s.GetGameByPin(ctx, gamePin)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGameNotFound
+		}
+		return nil, err
+	}
+	if game.Quiz.UserID != userID {
+		return nil, ErrNotGameOwner
+	}
+
+	// Poll questions score nothing, so they don't count towards the maximum
+	scoredQuestions := 0
+	for _, question := range game.Quiz.Questions {
+		if question.Type != models.QuestionTypePoll {
+			scoredQuestions++
+		}
+	}
+	scoring := quizScoring(&game.Quiz)
+	maxScore := scoredQuestions * scoring.maxPoints()
+	if game.Quiz.ConfidenceScoring {
+		maxScore = int(float64(maxScore) * confidenceMultipliers[3])
+	}
+	maxScore += scoredQuestions * scoring.fastestBonus
+
+	// Ranked as in the final leaderboard, so ties break the same way
+	players := s.rankedLeaderboard(ctx, game.ID)
+
+	results := &GameResults{
+		Pin:       DisplayPin(game.Pin),
+		QuizTitle: game.Quiz.Title,
+		Status:    game.Status,
+		MaxScore:  maxScore,
+		Players:   make([]PlayerResult, len(players)),
+	}
+	for i, player := range players {
+		percent := 0.0
+		if maxScore > 0 {
+			percent = math.Max(0, math.Min(100, float64(player.Score)*100/float64(maxScore)))
+			percent = math.Round(percent*10) / 10
+		}
+		results.Players[i] = PlayerResult{
+			ID:      player.ID,
+			Name:    player.Name,
+			Score:   player.Score,
+			Rank:    player.Rank,
+			Percent: percent,
+			Grade:   gradeFor(game.Quiz.GradeRubric, percent),
+		}
+	}
+
+	return results, nil
+}
+
+// GetPlayerAnswers returns the answers a player gave in the game, in the order
+// they were submitted, with the text of the option they picked and of the
+// correct one. Questions edited or deleted since are shown as they were.
+func (s *GameService) GetPlayerAnswers(ctx context.Context, gamePin string, playerID uint, userID uint) (*PlayerAnswers, error) {
+	game, err := s.GetGameByPin(ctx, gamePin)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGameNotFound
+		}
+		return nil, err
+	}
+	if game.Quiz.UserID != userID {
+		return nil, ErrNotGameOwner
+	}
+
+	var player *models.Player
+	for i := range game.Players {
+		if game.Players[i].ID == playerID {
+			player = &game.Players[i]
+			break
+		}
+	}
+	if player == nil {
+		return nil, ErrPlayerNotInGame
+	}
+
+	unscoped := func(db *gorm.DB) *gorm.DB { return db.Unscoped() }
+	var answers []models.GameAnswer
+	if err := s.db.WithContext(ctx).Where("game_id = ? AND player_id = ?", game.ID, playerID).
+		Preload("Question", unscoped).
+		Preload("Question.Options", unscoped).
+		Preload("Option", unscoped).
+		Order("created_at").
+		Find(&answers).Error; err != nil {
+		return nil, err
+	}
+
+	result := &PlayerAnswers{
+		PlayerID:   player.ID,
+		PlayerName: player.Name,
+		Score:      player.Score,
+		Answers:    make([]PlayerAnswer, len(answers)),
+	}
+	for i, answer := range answers {
+		correctText := ""
+		for _, option := range answer.Question.Options {
+			if option.IsCorrect {
+				correctText = option.Text
+				break
+			}
+		}
+		result.Answers[i] = PlayerAnswer{
+			QuestionID:        answer.QuestionID,
+			QuestionText:      answer.Question.Text,
+			OptionID:          answer.OptionID,
+			OptionText:        answer.Option.Text,
+			CorrectOptionText: correctText,
+			IsCorrect:         answer.IsCorrect,
+			Points:            answer.Points,
+			TimeSpent:         answer.TimeSpent,
+		}
+	}
+
+	return result, nil
+}
+
+// gradeFor returns the label of the highest band the percentage reaches, or ""
+// when the quiz has no rubric
+func gradeFor(rubric []models.GradeBand, percent float64) string {
+	grade := ""
+	for _, band := range rubric {
+		if percent >= band.MinPercent {
+			grade = band.Label
+		}
+	}
+	return grade
+}
+
+// GetGameWithState returns the game record along with its live question index,
+// status and time left. The record is still returned if the live state fails.
+func (s *GameService) GetGameWithState(ctx context.Context, gamePin string) (*GameWithState, error) {
+	normalizedPin := strings.ToLower(gamePin)
+
+	game, err := s.GetGameByPin(ctx, normalizedPin)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGameNotFound
+		}
+		return nil, err
+	}
+
+	result := &GameWithState{Game: *game}
+	result.Game.Pin = DisplayPin(game.Pin)
+	gameState, err := s.GetCurrentGameState(ctx, normalizedPin)
+	if err != nil {
+		s.logger.Warn("Live game state unavailable", "game_pin", normalizedPin, "error", err)
+		return result, nil
+	}
+
+	result.State = &LiveGameState{
+		Status:               gameState.Status,
+		CurrentQuestionIndex: gameState.CurrentQuestionIndex,
+		TotalQuestions:       gameState.TotalQuestions,
+	}
+	if gameState.Status == "active" && gameState.CurrentQuestion != nil && gameState.QuestionEndsAt != nil &&
+		!s.isQuestionEnded(ctx, normalizedPin, gameState.CurrentQuestionIndex) {
+		result.State.TimeLeft = secondsUntil(*gameState.QuestionEndsAt)
+		result.State.QuestionEndsAt = gameState.QuestionEndsAt
+	}
+	return result, nil
+}
+
+// IssueReconnectToken signs a token that lets a player whose connection dropped
+// re-attach to the same player identity over WebSocket
+func (s *GameService) IssueReconnectToken(gamePin string, playerID uint) (string, error) {
+	return s.issuePlayerToken("reconnect", gamePin, playerID)
+}
+
+// ValidateReconnectToken returns the player ID a reconnect token was issued for
+func (s *GameService) ValidateReconnectToken(gamePin string, tokenString string) (uint, error) {
+	return s.validatePlayerToken("reconnect", gamePin, tokenString)
+}
+
+// IssueSocketToken signs the token a player presents to open their game
+// WebSocket, so knowing a pin and player ID isn't enough to take over a socket
+func (s *GameService) IssueSocketToken(gamePin string, playerID uint) (string, error) {
+	return s.issuePlayerToken("socket", gamePin, playerID)
+}
+
+// ValidateSocketToken returns the player ID a socket token was issued for
+func (s *GameService) ValidateSocketToken(gamePin string, tokenString string) (uint, error) {
+	return s.validatePlayerToken("socket", gamePin, tokenString)
+}
+
+// issuePlayerToken signs a token of the given type for a player in a game
+func (s *GameService) issuePlayerToken(tokenType string, gamePin string, playerID uint) (string, error) {
+	claims := jwt.MapClaims{
+		"type":      tokenType,
+		"player_id": playerID,
+		"game_pin":  strings.ToLower(gamePin),
+		"exp":       time.Now().Add(12 * time.Hour).Unix(),
+		"iat":       time.Now().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.jwtSecret))
+}
+
+// validatePlayerToken checks a player token's type and game and returns the
+// player ID it was issued for
+func (s *GameService) validatePlayerToken(tokenType string, gamePin string, tokenString string) (uint, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, fmt.Errorf("invalid %s token", tokenType)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["type"] != tokenType {
+		return 0, fmt.Errorf("invalid %s token", tokenType)
+	}
+
+	if pin, _ := claims["game_pin"].(string); pin != strings.ToLower(gamePin) {
+		return 0, fmt.Errorf("%s token is for a different game", tokenType)
+	}
+
+	playerID, ok := claims["player_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("invalid player ID in %s token", tokenType)
+	}
+
+	return uint(playerID), nil
+}
+
+// GetPlayerByID retrieves a player by their ID
+func (s *GameService) GetPlayerByID(ctx context.Context, playerID uint) (*models.Player, error) {
+	var player models.Player
+	err := s.db.WithContext(ctx).First(&player, playerID).Error
+	return &player, err
+}
+
+func (s *GameService) SubmitAnswer(ctx context.Context, gamePin string, playerID uint, req *SubmitAnswerRequest, hub *Hub) error {
+	normalizedPin := strings.ToLower(gamePin)
+
+	// Get game
+	game, err := s.GetGameByPin(ctx, normalizedPin)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrGameNotFound
+		}
+		return err
+	}
+
+	if game.Status != "active" {
+		return ErrGameNotActive
+	}
+
+	inGame := false
+	for _, player := range game.Players {
+		if player.ID == playerID {
+			inGame = true
+			break
+		}
+	}
+	if !inGame {
+		return ErrPlayerNotInGame
+	}
+
+	// Only the current question accepts answers, and only until its time is up
+	// or its results have been processed. A state rebuilt from the database
+	// still says which question is current, just not when it started
+	gameState, err := s.gameStateOrRebuild(ctx, game)
+	if err != nil {
+		return err
+	}
+	if err := checkAnswerWindow(game, gameState, req.QuestionID, time.Now()); err != nil ||
+		s.isQuestionEnded(ctx, normalizedPin, gameState.CurrentQuestionIndex) {
+		return ErrQuestionNotActive
+	}
+	if gameState.CurrentQuestion == nil {
+		s.logger.Warn("Question start time unavailable, accepting answer without timing check", "game_pin", normalizedPin, "player_id", playerID)
+	}
+
+	// Check if answer already submitted; this is only a fast path, the unique
+	// index on game_answers settles concurrent submissions below
+	var existingAnswer models.GameAnswer
+	if err := s.db.WithContext(ctx).Where("game_id = ? AND player_id = ? AND question_id = ?",
+		game.ID, playerID, req.QuestionID).First(&existingAnswer).Error; err == nil {
+		return ErrAnswerAlreadySubmitted
+	}
+
+	// Get question and option to check if correct
+	var question models.Question
+	if err := s.db.WithContext(ctx).First(&question, req.QuestionID).Error; err != nil {
+		return errors.New("question not found")
+	}
+
+	var option models.Option
+	if err := s.db.WithContext(ctx).First(&option, req.OptionID).Error; err != nil {
+		return errors.New("option not found")
+	}
+	// Correctness comes from the option, so it must be one of this question's
+	if option.QuestionID != question.ID {
+		return ErrOptionNotInQuestion
+	}
+
+	timeSpent := answerTimeSpent(gameState, req.TimeSpent, question.TimeLimit)
+
+	// Store answer without calculating points or updating score yet
+	// Points will be calculated and scores updated when the timer ends
+	gameAnswer := models.GameAnswer{
+		GameID:     game.ID,
+		PlayerID:   playerID,
+		QuestionID: req.QuestionID,
+		OptionID:   req.OptionID,
+		IsCorrect:  option.IsCorrect,
+		TimeSpent:  timeSpent,
+		Confidence: req.Confidence,
+		Points:     0, // Will be calculated when timer ends
+	}
+
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Create(&gameAnswer).Error
+	}); err != nil {
+		if isUniqueViolation(err) {
+			return ErrAnswerAlreadySubmitted
+		}
+		return err
+	}
+	metrics.AnswersSubmitted.Inc()
+
+	// Answer times break score ties, so the standings can change before any
+	// points are awarded
+	s.refreshLeaderboard(ctx, normalizedPin, game.ID)
+
+	// Broadcast that answer was submitted (but don't reveal if correct or show points yet)
+	// Training mode reveals correctness and the correct option to everyone right away
+	if hub != nil {
+		// Confirm receipt to the player who answered; correctness follows in
+		// answer_result once the question ends
+		hub.SendToPlayer(normalizedPin, playerID, "answer_received", gin.H{
+			"question_id": req.QuestionID,
+			"option_id":   req.OptionID,
+		})
+
+		payload := gin.H{
+			"player_id":        playerID,
+			"answer_submitted": true,
+		}
+		if game.TrainingMode && question.Type != models.QuestionTypePoll {
+			payload["is_correct"] = option.IsCorrect
+			payload["correct_option_id"] = correctOptionID(game, req.QuestionID)
+		}
+		hub.BroadcastToGame(normalizedPin, "answer_submitted", payload)
+		s.scheduleAnswerProgress(ctx, normalizedPin, game, req.QuestionID, gameState.CurrentQuestionIndex, hub)
+
+		// Tell the host once everyone has answered, so they needn't wait out the timer
+		if s.allConnectedPlayersAnswered(ctx, game, req.QuestionID, hub) &&
+			s.markAllAnswered(ctx, normalizedPin, gameState.CurrentQuestionIndex) {
+			questionIndex := gameState.CurrentQuestionIndex
+			hub.BroadcastToGame(normalizedPin, "all_answered", gin.H{
+				"question_index": questionIndex,
+				"question_id":    req.QuestionID,
+			})
+
+			// Training and auto-advancing games end the question straight away
+			switch {
+			case game.AutoAdvance:
+				s.logger.Info("All connected players answered, advancing", "game_pin", normalizedPin, "question_index", questionIndex)
+				go s.autoAdvance(context.WithoutCancel(ctx), normalizedPin, questionIndex, hub)
+			case game.TrainingMode:
+				s.logger.Info("All connected players answered, ending question early", "game_pin", normalizedPin, "question_index", questionIndex)
+				go s.EndQuestion(context.WithoutCancel(ctx), normalizedPin, hub, questionIndex)
+			}
+		}
 	}
 
 	return nil
 }
 
-func (s *GameService) JoinGame(req *JoinGameRequest) (*models.Player, error) {
-	// Convert PIN to lowercase for case-insensitive search
-	pin := strings.ToLower(req.Pin)
-
-	// First, get the game by PIN
-	var game models.Game
-	if err := s.db.Where("LOWER(pin) = ?", pin).First(&game).Error; err != nil {
-		return nil, errors.New("game not found")
+// checkAnswerWindow returns ErrQuestionNotActive unless the question is the
+// one on screen and its time isn't up. A state rebuilt after Redis lost it has
+// the current question's index but no question or timing, so only the question
+// is checked then; during the countdown before a question nothing is accepted.
+func checkAnswerWindow(game *models.Game, gameState *GameState, questionID uint, now time.Time) error {
+	if gameState.CurrentQuestion != nil {
+		if gameState.CurrentQuestion.ID != questionID ||
+			(gameState.QuestionEndsAt != nil && now.After(*gameState.QuestionEndsAt)) {
+			return ErrQuestionNotActive
+		}
+		return nil
 	}
 
-	// Check if the game status allows joining
-	if game.Status != "waiting" && game.Status != "active" {
-		return nil, fmt.Errorf("game has status '%s' - cannot join", game.Status)
+	index := gameState.CurrentQuestionIndex
+	if gameState.QuestionStartsAt != nil || index < 0 || index >= len(game.Quiz.Questions) ||
+		game.Quiz.Questions[index].ID != questionID {
+		return ErrQuestionNotActive
 	}
+	return nil
+}
 
-	// Check if player name is already taken in this game
-	var existingPlayer models.Player
-	if err := s.db.Where("game_id = ? AND name = ?", game.ID, req.Name).First(&existingPlayer).Error; err == nil {
-		return nil, errors.New("player name already taken")
+// answerTimeSpent is how many seconds a player took to answer, measured from
+// when the server started the question so a client can't claim a faster
+// answer to earn a bigger time bonus. The client's own figure is only used
+// when the start time isn't known, and is then kept within the time limit; a
+// missing or negative figure counts as the whole limit, so it earns no bonus.
+func answerTimeSpent(gameState *GameState, clientTimeSpent int, timeLimit int) int {
+	if gameState != nil && gameState.QuestionStartedAt != nil {
+		return max(0, min(int(time.Since(*gameState.QuestionStartedAt).Seconds()), timeLimit))
 	}
-
-	// Create player
-	player := models.Player{
-		GameID:   game.ID,
-		Name:     req.Name,
-		Score:    0,
-		JoinedAt: time.Now(),
+	if clientTimeSpent <= 0 {
+		return timeLimit
 	}
+	return min(clientTimeSpent, timeLimit)
+}
 
-	if err := s.db.Create(&player).Error; err != nil {
-		return nil, err
+// autoAdvance ends a question everyone has answered and, after giving players
+// time to see the results, moves the game on unless the host already has
+func (s *GameService) autoAdvance(ctx context.Context, normalizedPin string, questionIndex int, hub *Hub) {
+	if err := s.EndQuestion(ctx, normalizedPin, hub, questionIndex); err != nil {
+		s.logger.Error("Error ending question for auto-advance", "game_pin", normalizedPin, "question_index", questionIndex, "error", err)
+		return
 	}
 
-	// Update game state in Redis
-	normalizedPin := strings.ToLower(game.Pin)
-	gameState := s.getGameState(normalizedPin)
-	if gameState == nil {
-		// Create new game state if it doesn't exist
-		gameState = &GameState{
-			GameID:               game.ID,
-			QuizID:               game.QuizID,
-			Pin:                  normalizedPin,
-			Status:               game.Status,
-			CurrentQuestionIndex: -1,
-			Players:              []GamePlayer{},
-		}
+	gameState := s.getGameState(ctx, normalizedPin)
+	if gameState != nil && gameState.RevealEndsAt != nil {
+		time.Sleep(time.Until(*gameState.RevealEndsAt))
+		gameState = s.getGameState(ctx, normalizedPin)
 	}
-
-	// Add player to game state
-	gamePlayer := GamePlayer{
-		ID:    player.ID,
-		Name:  player.Name,
-		Score: player.Score,
+	if gameState == nil || gameState.Status != "active" || gameState.CurrentQuestionIndex != questionIndex {
+		return
 	}
-	gameState.Players = append(gameState.Players, gamePlayer)
-	s.storeGameState(normalizedPin, gameState)
+	if err := s.NextQuestion(ctx, normalizedPin, hub); err != nil {
+		s.logger.Error("Error auto-advancing to next question", "game_pin", normalizedPin, "question_index", questionIndex, "error", err)
+	}
+}
 
-	return &player, nil
+// correctOptionID returns the ID of the correct option for a question in the game's quiz
+func correctOptionID(game *models.Game, questionID uint) uint {
+	for _, question := range game.Quiz.Questions {
+		if question.ID != questionID {
+			continue
+		}
+		for _, option := range question.Options {
+			if option.IsCorrect {
+				return option.ID
+			}
+		}
+	}
+	return 0
 }
 
-func (s *GameService) GetGameByPin(pin string) (*models.Game, error) {
-	var game models.Game
-	err := s.db.Where("LOWER(pin) = ?", strings.ToLower(pin)).
-		Preload("Quiz").
-		Preload("Quiz.Questions").
-		Preload("Quiz.Questions.Options").
-		Preload("Players").
-		First(&game).Error
-	return &game, err
+// scheduleAnswerProgress sends the host and spectators how many players have
+// answered the question, at most once per answerProgressInterval per game.
+// The count is taken when the message goes out, so a burst of answers is
+// reported once with its total.
+func (s *GameService) scheduleAnswerProgress(ctx context.Context, normalizedPin string, game *models.Game, questionID uint, questionIndex int, hub *Hub) {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+	if s.progressPending[normalizedPin] {
+		return
+	}
+	s.progressPending[normalizedPin] = true
+
+	time.AfterFunc(answerProgressInterval, func() {
+		s.progressMu.Lock()
+		delete(s.progressPending, normalizedPin)
+		s.progressMu.Unlock()
+
+		progress, err := s.answerProgress(context.WithoutCancel(ctx), game, questionID, hub)
+		if err != nil {
+			s.logger.Error("Error counting answers", "game_pin", normalizedPin, "question_id", questionID, "error", err)
+			return
+		}
+
+		// Only counts are sent; which option anyone chose stays hidden
+		payload := gin.H{
+			"question_index": questionIndex,
+			"question_id":    questionID,
+			"answered":       progress.answered,
+			"expected":       progress.expected,
+		}
+		hub.BroadcastToRole(normalizedPin, RoleHost, "answer_progress", payload)
+		hub.BroadcastToRole(normalizedPin, RoleSpectator, "answer_progress", payload)
+	})
 }
 
-// GetPlayerByID retrieves a player by their ID
-func (s *GameService) GetPlayerByID(playerID uint) (*models.Player, error) {
-	var player models.Player
-	err := s.db.First(&player, playerID).Error
-	return &player, err
+// answerCount is how far the players in a game are through answering a question
+type answerCount struct {
+	answered     int  // players who have answered
+	expected     int  // players connected now, plus any who answered and then left
+	allConnected bool // at least one player is connected and all of them have answered
 }
 
-func (s *GameService) UpdateGameStatus(gamePin string, status string) error {
-	normalizedPin := strings.ToLower(gamePin)
+// answerProgress counts the game's answers to the question against the
+// players currently connected to it over WebSocket
+func (s *GameService) answerProgress(ctx context.Context, game *models.Game, questionID uint, hub *Hub) (answerCount, error) {
+	inGame := make(map[uint]bool)
+	for _, player := range game.Players {
+		inGame[player.ID] = true
+	}
 
-	// Update game status in database
-	if err := s.db.Model(&models.Game{}).Where("LOWER(pin) = ?", normalizedPin).
-		Update("status", status).Error; err != nil {
-		return err
+	var answered []uint
+	if err := s.db.WithContext(ctx).Model(&models.GameAnswer{}).
+		Where("game_id = ? AND question_id = ?", game.ID, questionID).
+		Pluck("player_id", &answered).Error; err != nil {
+		return answerCount{}, err
 	}
 
-	// Update game state in Redis
-	gameState := s.getGameState(normalizedPin)
-	if gameState != nil {
-		gameState.Status = status
-		s.storeGameState(normalizedPin, gameState)
+	var count answerCount
+	answeredSet := make(map[uint]bool)
+	for _, id := range answered {
+		if inGame[id] { // removed players' answers don't count
+			answeredSet[id] = true
+			count.answered++
+		}
 	}
+	count.expected = count.answered
 
-	return nil
+	connected, waiting := 0, 0
+	for _, id := range hub.GetConnectedPlayers(game.Pin) {
+		if !inGame[id] {
+			continue // removed from the game
+		}
+		connected++
+		if !answeredSet[id] {
+			waiting++
+		}
+	}
+	count.expected += waiting
+	count.allConnected = connected > 0 && waiting == 0
+	return count, nil
 }
 
-func (s *GameService) SubmitAnswer(gamePin string, playerID uint, req *SubmitAnswerRequest, hub *Hub) error {
-	normalizedPin := strings.ToLower(gamePin)
-
-	// Get game
-	game, err := s.GetGameByPin(normalizedPin)
+// allConnectedPlayersAnswered reports whether every player currently connected
+// to the game over WebSocket has answered the question
+func (s *GameService) allConnectedPlayersAnswered(ctx context.Context, game *models.Game, questionID uint, hub *Hub) bool {
+	progress, err := s.answerProgress(ctx, game, questionID, hub)
 	if err != nil {
-		return errors.New("game not found")
+		s.logger.Error("Error fetching answered players", "game_id", game.ID, "question_id", questionID, "error", err)
+		return false
 	}
+	return progress.allConnected
+}
 
-	if game.Status != "active" {
-		return errors.New("game is not active")
+// maxPlayerNameLength is the longest player name in characters, short enough
+// to fit a projected leaderboard
+const maxPlayerNameLength = 20
+
+// normalizePlayerName strips control characters and surrounding whitespace
+// from a player name and checks what remains is non-empty and not too long
+func normalizePlayerName(name string) (string, error) {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return "", errors.New("player name must contain at least one visible character")
+	}
+	if utf8.RuneCountInString(name) > maxPlayerNameLength {
+		return "", fmt.Errorf("player name must be at most %d characters", maxPlayerNameLength)
 	}
+	return name, nil
+}
 
-	// Check if answer already submitted
-	var existingAnswer models.GameAnswer
-	if err := s.db.Where("game_id = ? AND player_id = ? AND question_id = ?",
-		game.ID, playerID, req.QuestionID).First(&existingAnswer).Error; err == nil {
-		return errors.New("answer already submitted")
+// activePlayers limits a players query to those who haven't been removed, so a
+// removed player's name is free to reuse. GORM's soft-delete scope already does
+// this; it's spelled out so the rule survives an Unscoped query
+func activePlayers(db *gorm.DB) *gorm.DB {
+	return db.Where("players.deleted_at IS NULL")
+}
+
+// applyQuestionOrder puts a shuffled game's questions into its play order, so
+// question indexes address the shuffled sequence everywhere. The order is the
+// one stored in the game state when the game started; only before then, or if
+// the state was lost, is it drawn afresh from the game's seed.
+func (s *GameService) applyQuestionOrder(ctx context.Context, game *models.Game) {
+	if !game.ShuffleQuestions || len(game.Quiz.Questions) < 2 {
+		return
 	}
 
-	// Get question and option to check if correct
-	var question models.Question
-	if err := s.db.First(&question, req.QuestionID).Error; err != nil {
-		return errors.New("question not found")
+	if gameState := s.getGameState(ctx, game.Pin); gameState != nil && len(gameState.QuestionOrder) > 0 {
+		orderQuestions(game, gameState.QuestionOrder)
+		return
 	}
+	shuffleQuestions(game)
+}
 
-	var option models.Option
-	if err := s.db.First(&option, req.OptionID).Error; err != nil {
-		return errors.New("option not found")
+// orderQuestions sorts a game's questions into a stored play order. Questions
+// added to the quiz since the order was stored go last, in authored order;
+// deleted ones simply drop out.
+func orderQuestions(game *models.Game, order []uint) {
+	position := make(map[uint]int, len(order))
+	for i, id := range order {
+		position[id] = i
+	}
+	rank := func(id uint) int {
+		if i, ok := position[id]; ok {
+			return i
+		}
+		return len(order)
 	}
 
-	// Provide default time spent if not provided
-	timeSpent := req.TimeSpent
-	if timeSpent == 0 {
-		timeSpent = question.TimeLimit
+	questions := game.Quiz.Questions
+	sort.SliceStable(questions, func(i, j int) bool {
+		return rank(questions[i].ID) < rank(questions[j].ID)
+	})
+}
+
+// shuffleQuestions shuffles a game's questions with a seed taken from the game
+// itself, so the order it draws is the same every time
+func shuffleQuestions(game *models.Game) {
+	rng := mathrand.New(mathrand.NewPCG(uint64(game.ID), uint64(game.CreatedAt.UnixNano())))
+	questions := game.Quiz.Questions
+	rng.Shuffle(len(questions), func(i, j int) {
+		questions[i], questions[j] = questions[j], questions[i]
+	})
+}
+
+// playerOptionOrder returns a question's options in the order a player sees
+// them when options are shuffled. The order is seeded by the player and the
+// question, so it stays the same across reconnects. Answers are submitted and
+// scored by option ID, so the order never affects correctness.
+func playerOptionOrder(playerID, questionID uint, options []GameOption) []GameOption {
+	ordered := make([]GameOption, len(options))
+	copy(ordered, options)
+
+	rng := mathrand.New(mathrand.NewPCG(uint64(playerID), uint64(questionID)))
+	rng.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+	return ordered
+}
+
+// questionOrder lists a game's question IDs in play order
+func questionOrder(game *models.Game) []uint {
+	ids := make([]uint, len(game.Quiz.Questions))
+	for i, question := range game.Quiz.Questions {
+		ids[i] = question.ID
 	}
+	return ids
+}
 
-	// Store answer without calculating points or updating score yet
-	// Points will be calculated and scores updated when the timer ends
-	gameAnswer := models.GameAnswer{
-		GameID:     game.ID,
-		PlayerID:   playerID,
-		QuestionID: req.QuestionID,
-		OptionID:   req.OptionID,
-		IsCorrect:  option.IsCorrect,
-		TimeSpent:  timeSpent,
-		Points:     0, // Will be calculated when timer ends
+// preloadOrderedQuestions loads a game's quiz with its questions and options
+// sorted by Order, so a question index always maps to the question whose Order
+// equals that index
+func preloadOrderedQuestions(db *gorm.DB) *gorm.DB {
+	return db.Preload("Quiz").
+		Preload("Quiz.Questions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("questions.order")
+		}).
+		Preload("Quiz.Questions.Options", func(db *gorm.DB) *gorm.DB {
+			return db.Order("options.order")
+		})
+}
+
+// NormalizePin lowercases a game PIN and checks it matches the generated
+// format, so malformed PINs can be rejected before any lookup. Numeric PINs
+// are a subset of the hex format, so either alphabet passes.
+func NormalizePin(pin string) (string, error) {
+	if len(pin) < minPinLength || len(pin) > maxPinLength {
+		return "", ErrInvalidPin
+	}
+	pin = strings.ToLower(pin)
+	for _, r := range pin {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return "", ErrInvalidPin
+		}
 	}
+	return pin, nil
+}
 
-	if err := s.db.Create(&gameAnswer).Error; err != nil {
-		return err
+// DisplayPin formats a stored PIN the way clients show it. PINs are stored and
+// compared lowercase, but uppercase reads better on a projector; NormalizePin
+// accepts either case back.
+func DisplayPin(pin string) string {
+	return strings.ToUpper(pin)
+}
+
+// generatePin returns a random PIN in the configured format that no game,
+// including deleted ones, is using
+func (s *GameService) generatePin(ctx context.Context) (string, error) {
+	for attempt := 0; attempt < pinAttempts; attempt++ {
+		pin, err := randomPin(s.pinChars, s.pinLength)
+		if err != nil {
+			return "", err
+		}
+
+		var count int64
+		if err := s.db.WithContext(ctx).Unscoped().Model(&models.Game{}).Where("pin = ?", pin).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return pin, nil
+		}
 	}
+	return "", errors.New("could not find an unused game PIN")
+}
 
-	// Broadcast that answer was submitted (but don't reveal if correct or show points yet)
-	if hub != nil {
-		hub.BroadcastToGame(normalizedPin, "answer_submitted", gin.H{
-			"player_id":        playerID,
-			"answer_submitted": true,
-		})
+func randomPin(chars string, length int) (string, error) {
+	pin := make([]byte, length)
+	for i := range pin {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		pin[i] = chars[n.Int64()]
 	}
+	return string(pin), nil
+}
 
-	return nil
+// scoringProfile is a quiz's point economics with unset fields defaulted
+type scoringProfile struct {
+	basePoints    int
+	maxTimeBonus  int // 0 when speed doesn't matter
+	penaltyPoints int // deducted for a wrong answer
+	fastestBonus  int // extra for the fastest correct answer to a question
+}
+
+func quizScoring(quiz *models.Quiz) scoringProfile {
+	profile := scoringProfile{basePoints: defaultBasePoints, maxTimeBonus: defaultMaxTimeBonus}
+	if quiz.BasePoints != nil {
+		profile.basePoints = *quiz.BasePoints
+	}
+	if quiz.MaxTimeBonus != nil {
+		profile.maxTimeBonus = *quiz.MaxTimeBonus
+	}
+	if quiz.SpeedBonus != nil && !*quiz.SpeedBonus {
+		profile.maxTimeBonus = 0
+	}
+	if quiz.PenaltyPoints != nil {
+		profile.penaltyPoints = *quiz.PenaltyPoints
+	}
+	if quiz.FastestBonus != nil {
+		profile.fastestBonus = *quiz.FastestBonus
+	}
+	return profile
 }
 
-func (s *GameService) generatePin() string {
-	bytes := make([]byte, 3)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)[:6]
+// maxPoints is the most a correct answer can earn before confidence weighting
+func (p scoringProfile) maxPoints() int {
+	return p.basePoints + p.maxTimeBonus
 }
 
-func (s *GameService) calculatePoints(timeSpent, timeLimit int, isCorrect bool) int {
+func (s *GameService) calculatePoints(scoring scoringProfile, timeSpent, timeLimit int, isCorrect bool) int {
 	if !isCorrect {
-		return 0
+		return -scoring.penaltyPoints
 	}
 
 	// Base points for correct answer
-	basePoints := 100
+	basePoints := scoring.basePoints
 
-	// Bonus points for quick answer (up to 50 bonus points)
-	timeBonus := int(math.Max(0, float64(50*(timeLimit-timeSpent)/timeLimit)))
+	// Bonus points for quick answer (up to the quiz's max time bonus)
+	timeBonus := int(math.Max(0, float64(scoring.maxTimeBonus*(timeLimit-timeSpent)/timeLimit)))
 
 	return basePoints + timeBonus
 }
 
-func (s *GameService) storeGameState(pin string, state *GameState) error {
+// Confidence-based marking: a confident correct answer earns a multiple of its
+// points, while a confident wrong answer costs a penalty. Indexed by confidence.
+var (
+	confidenceMultipliers = map[int]float64{1: 1.0, 2: 1.5, 3: 2.0}
+	confidencePenalties   = map[int]int{1: 0, 2: 25, 3: 50}
+)
+
+// applyConfidence weights an answer's points by the confidence the player gave.
+// Answers without a confidence rating are scored normally.
+func applyConfidence(points int, confidence int, isCorrect bool) int {
+	if confidence == 0 {
+		return points
+	}
+	if !isCorrect {
+		return points - confidencePenalties[confidence]
+	}
+	return int(float64(points) * confidenceMultipliers[confidence])
+}
+
+// gameKey builds the namespaced Redis key for a game, e.g. "<prefix>game:<pin>:events"
+func (s *GameService) gameKey(pin string, parts ...string) string {
+	return s.keyPrefix + strings.Join(append([]string{"game", pin}, parts...), ":")
+}
+
+// ClearGameState deletes everything stored in Redis for the game: its state,
+// event log and per-question markers
+func (s *GameService) ClearGameState(ctx context.Context, pin string) error {
+	normalizedPin := strings.ToLower(pin)
+
+	keys := []string{s.gameKey(normalizedPin)}
+	iter := s.redis.Scan(ctx, 0, s.gameKey(normalizedPin)+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	return s.redis.Del(ctx, keys...).Err()
+}
+
+func (s *GameService) storeGameState(ctx context.Context, pin string, state *GameState) error {
 	normalizedPin := strings.ToLower(pin)
 
 	// Convert to JSON for Redis storage
@@ -705,23 +2666,118 @@ func (s *GameService) storeGameState(pin string, state *GameState) error {
 		return fmt.Errorf("failed to marshal game state: %v", err)
 	}
 
-	// Store in Redis with expiration (2 hours)
-	err = s.redis.Set(context.Background(), "game:"+normalizedPin, data, 2*time.Hour).Err()
-	if err != nil {
+	// Every write restarts the TTL, and the event log is extended with it, so a
+	// game that keeps progressing never expires mid-session
+	expiration := s.stateExpiration(state)
+
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, s.gameKey(normalizedPin), data, expiration)
+	pipe.Expire(ctx, s.gameKey(normalizedPin, "events"), expiration)
+	pipe.Expire(ctx, s.gameKey(normalizedPin, "seq"), expiration)
+	pipe.Expire(ctx, s.gameKey(normalizedPin, "leaderboard"), expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("failed to store in Redis: %v", err)
 	}
 
-	log.Printf("Stored game state for %s: currentQuestionIndex=%d, status=%s", normalizedPin, state.CurrentQuestionIndex, state.Status)
+	s.logger.Debug("Stored game state", "game_pin", normalizedPin, "question_index", state.CurrentQuestionIndex, "status", state.Status)
 	return nil
 }
 
-func (s *GameService) getGameState(pin string) *GameState {
+// stateExpiration is how long a game's state is kept in Redis: the state TTL,
+// or that long past the scheduled start
+func (s *GameService) stateExpiration(state *GameState) time.Duration {
+	expiration := s.gameStateTTL
+	if state.ScheduledAt != nil && state.ScheduledAt.After(time.Now()) {
+		expiration += time.Until(*state.ScheduledAt)
+	}
+	return expiration
+}
+
+// eventLogExpiration returns how much longer the game's state is kept, so the
+// event log expires with it rather than before a scheduled game even starts
+func (s *GameService) eventLogExpiration(ctx context.Context, normalizedPin string) time.Duration {
+	ttl, err := s.redis.PTTL(ctx, s.gameKey(normalizedPin)).Result()
+	if err != nil || ttl <= 0 {
+		return s.gameStateTTL
+	}
+	return ttl
+}
+
+// eventLogSize is how many recent broadcast events are kept per game for
+// replaying to reconnecting clients
+const eventLogSize = 50
+
+// GameEvent is a broadcast event kept in a game's replay log
+type GameEvent struct {
+	Seq     int64           `json:"seq"`
+	Type    string          `json:"type"`
+	Role    string          `json:"role,omitempty"` // only clients with this role received it; empty for everyone
+	Payload json.RawMessage `json:"payload"`
+}
+
+// RecordEvent appends a broadcast event to the game's replay log and returns
+// its sequence number
+func (s *GameService) RecordEvent(ctx context.Context, pin string, eventType string, role string, payload interface{}) (int64, error) {
 	normalizedPin := strings.ToLower(pin)
 
-	data, err := s.redis.Get(context.Background(), "game:"+normalizedPin).Result()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event payload: %v", err)
+	}
+
+	seq, err := s.redis.Incr(ctx, s.gameKey(normalizedPin, "seq")).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate event sequence: %v", err)
+	}
+
+	event, err := json.Marshal(GameEvent{Seq: seq, Type: eventType, Role: role, Payload: data})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	eventsKey := s.gameKey(normalizedPin, "events")
+	expiration := s.eventLogExpiration(ctx, normalizedPin)
+	pipe := s.redis.TxPipeline()
+	pipe.RPush(ctx, eventsKey, event)
+	pipe.LTrim(ctx, eventsKey, -eventLogSize, -1)
+	pipe.Expire(ctx, eventsKey, expiration)
+	pipe.Expire(ctx, s.gameKey(normalizedPin, "seq"), expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to store event: %v", err)
+	}
+
+	return seq, nil
+}
+
+// GetRecentEvents returns the game's replay log, oldest first
+func (s *GameService) GetRecentEvents(ctx context.Context, pin string) ([]GameEvent, error) {
+	normalizedPin := strings.ToLower(pin)
+
+	entries, err := s.redis.LRange(ctx, s.gameKey(normalizedPin, "events"), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]GameEvent, 0, len(entries))
+	for _, entry := range entries {
+		var event GameEvent
+		if err := json.Unmarshal([]byte(entry), &event); err != nil {
+			s.logger.Error("Failed to unmarshal event", "game_pin", normalizedPin, "error", err)
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (s *GameService) getGameState(ctx context.Context, pin string) *GameState {
+	normalizedPin := strings.ToLower(pin)
+
+	data, err := s.redis.Get(ctx, s.gameKey(normalizedPin)).Result()
 	if err != nil {
 		if err != redis.Nil {
-			log.Printf("Redis error getting game state for %s: %v", normalizedPin, err)
+			s.logger.Error("Redis error getting game state", "game_pin", normalizedPin, "error", err)
 		}
 		return nil
 	}
@@ -729,41 +2785,139 @@ func (s *GameService) getGameState(pin string) *GameState {
 	var state GameState
 	err = json.Unmarshal([]byte(data), &state)
 	if err != nil {
-		log.Printf("Failed to unmarshal game state for %s: %v", normalizedPin, err)
+		s.logger.Error("Failed to unmarshal game state", "game_pin", normalizedPin, "error", err)
 		return nil
 	}
 
-	log.Printf("Retrieved game state for %s: currentQuestionIndex=%d, status=%s", normalizedPin, state.CurrentQuestionIndex, state.Status)
+	s.logger.Debug("Retrieved game state", "game_pin", normalizedPin, "question_index", state.CurrentQuestionIndex, "status", state.Status)
 	return &state
 }
 
+// markQuestionEnded records that a question's results were processed and reports
+// whether this call was the first to do so
+func (s *GameService) markQuestionEnded(ctx context.Context, pin string, questionIndex int) bool {
+	key := s.gameKey(strings.ToLower(pin), "ended", strconv.Itoa(questionIndex))
+	first, err := s.redis.SetNX(ctx, key, 1, s.gameStateTTL).Result()
+	if err != nil {
+		s.logger.Error("Redis error marking question ended", "game_pin", pin, "question_index", questionIndex, "error", err)
+		return true
+	}
+	return first
+}
+
+// markAllAnswered records that every player answered a question and reports
+// whether this call was the first to do so, so all_answered is sent only once
+func (s *GameService) markAllAnswered(ctx context.Context, pin string, questionIndex int) bool {
+	key := s.gameKey(strings.ToLower(pin), "all_answered", strconv.Itoa(questionIndex))
+	first, err := s.redis.SetNX(ctx, key, 1, s.gameStateTTL).Result()
+	if err != nil {
+		s.logger.Error("Redis error marking question answered", "game_pin", pin, "question_index", questionIndex, "error", err)
+		return true
+	}
+	return first
+}
+
+// markGameFinished records that the game has finished, returning false if it
+// already had
+func (s *GameService) markGameFinished(ctx context.Context, pin string) bool {
+	first, err := s.redis.SetNX(ctx, s.gameKey(strings.ToLower(pin), "finished"), 1, s.gameStateTTL).Result()
+	if err != nil {
+		s.logger.Error("Redis error marking game finished", "game_pin", pin, "error", err)
+		return true
+	}
+	return first
+}
+
+// isQuestionEnded reports whether a question's results were already processed
+func (s *GameService) isQuestionEnded(ctx context.Context, pin string, questionIndex int) bool {
+	key := s.gameKey(strings.ToLower(pin), "ended", strconv.Itoa(questionIndex))
+	n, err := s.redis.Exists(ctx, key).Result()
+	return err == nil && n > 0
+}
+
+// GetQuestionTimer returns the authoritative remaining time for the game's
+// current question, computed from when the question ends
+func (s *GameService) GetQuestionTimer(ctx context.Context, gamePin string) (*QuestionTimer, error) {
+	normalizedPin := strings.ToLower(gamePin)
+
+	gameState := s.getGameState(ctx, normalizedPin)
+	if gameState == nil {
+		if _, err := s.GetGameByPin(ctx, normalizedPin); err != nil {
+			return nil, ErrGameNotFound
+		}
+		return &QuestionTimer{QuestionIndex: -1}, nil
+	}
+
+	timer := &QuestionTimer{QuestionIndex: gameState.CurrentQuestionIndex}
+	if gameState.Status != "active" || gameState.CurrentQuestion == nil || gameState.QuestionEndsAt == nil ||
+		s.isQuestionEnded(ctx, normalizedPin, gameState.CurrentQuestionIndex) {
+		return timer, nil
+	}
+
+	timer.TimeLimit = gameState.CurrentQuestion.TimeLimit
+	timer.TimeLeft = secondsUntil(*gameState.QuestionEndsAt)
+	timer.EndsAt = gameState.QuestionEndsAt
+	timer.Active = timer.TimeLeft > 0
+	return timer, nil
+}
+
+// refreshTimeLeft sets the current question's time left from when it started
+// rather than the timer's last write, which can lag by up to a tick
+func (gs *GameState) refreshTimeLeft() {
+	if gs.CurrentQuestion == nil {
+		return
+	}
+	switch {
+	case gs.QuestionStartedAt != nil:
+		gs.CurrentQuestion.TimeLeft = secondsUntil(gs.QuestionStartedAt.Add(time.Duration(gs.CurrentQuestion.TimeLimit) * time.Second))
+	case gs.QuestionEndsAt != nil:
+		gs.CurrentQuestion.TimeLeft = secondsUntil(*gs.QuestionEndsAt)
+	}
+}
+
+// secondsUntil returns the whole seconds remaining until t, rounded up and never negative
+func secondsUntil(t time.Time) int {
+	remaining := time.Until(t)
+	if remaining <= 0 {
+		return 0
+	}
+	return int(math.Ceil(remaining.Seconds()))
+}
+
 // CheckGameOwnership checks if a user owns a specific game
-func (s *GameService) CheckGameOwnership(gamePin string, userID uint) error {
+func (s *GameService) CheckGameOwnership(ctx context.Context, gamePin string, userID uint) error {
 	normalizedPin := strings.ToLower(gamePin)
 	var game models.Game
-	if err := s.db.Where("LOWER(pin) = ?", normalizedPin).First(&game).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("LOWER(pin) = ?", normalizedPin).First(&game).Error; err != nil {
 		return errors.New("game not found")
 	}
 
 	var quiz models.Quiz
-	if err := s.db.Where("id = ? AND user_id = ?", game.QuizID, userID).First(&quiz).Error; err != nil {
-		return errors.New("unauthorized to control this game")
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", game.QuizID, userID).First(&quiz).Error; err != nil {
+		return ErrNotGameOwner
 	}
 
 	return nil
 }
 
 // GetCurrentGameState returns the current game state for WebSocket synchronization
-func (s *GameService) GetCurrentGameState(gamePin string) (*GameState, error) {
+func (s *GameService) GetCurrentGameState(ctx context.Context, gamePin string) (*GameState, error) {
 	normalizedPin := strings.ToLower(gamePin)
 
 	// Try to get from Redis first
-	gameState := s.getGameState(normalizedPin)
+	gameState := s.getGameState(ctx, normalizedPin)
 	if gameState != nil {
-		// Update with fresh player data from database
+		gameState.refreshTimeLeft()
+		gameState.Leaderboard = s.storedLeaderboard(ctx, normalizedPin, gameState.GameID)
+
+		// Update with fresh player data from database; if the database is
+		// unreachable the cached players are still good enough to sync with
 		var players []models.Player
 		if gameState.GameID > 0 {
-			s.db.Where("game_id = ?", gameState.GameID).Find(&players)
+			if err := s.db.WithContext(ctx).Where("game_id = ?", gameState.GameID).Order("id").Find(&players).Error; err != nil {
+				s.logger.Warn("Using cached players", "game_pin", normalizedPin, "error", err)
+				return gameState, nil
+			}
 			gameState.Players = []GamePlayer{}
 			for _, player := range players {
 				gameState.Players = append(gameState.Players, GamePlayer{
@@ -777,14 +2931,57 @@ func (s *GameService) GetCurrentGameState(gamePin string) (*GameState, error) {
 	}
 
 	// Fallback: get from database and create Redis state
-	game, err := s.GetGameByPin(normalizedPin)
+	game, err := s.GetGameByPin(ctx, normalizedPin)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGameNotFound
+		}
 		return nil, err
 	}
 
+	return s.rebuildGameState(ctx, game)
+}
+
+// gameStateOrRebuild returns the game's Redis state, rebuilding it from the
+// database when the key is missing during an active game so a Redis blip
+// doesn't strand a live game
+func (s *GameService) gameStateOrRebuild(ctx context.Context, game *models.Game) (*GameState, error) {
+	normalizedPin := strings.ToLower(game.Pin)
+	if gameState := s.getGameState(ctx, normalizedPin); gameState != nil {
+		return gameState, nil
+	}
+
+	if game.Status != "active" {
+		s.logger.Warn("Game state not found", "game_pin", normalizedPin, "status", game.Status)
+		return nil, errors.New("game state not found")
+	}
+
+	s.logger.Warn("Game state missing from Redis, rebuilding from database", "game_pin", normalizedPin)
+	return s.rebuildGameState(ctx, game)
+}
+
+// rebuildGameState reconstructs a game's state from the database and stores it
+// in Redis unless the game is finished. The database doesn't record which
+// question is on screen, so an active game resumes at the latest question
+// anyone has answered
+func (s *GameService) rebuildGameState(ctx context.Context, game *models.Game) (*GameState, error) {
+	normalizedPin := strings.ToLower(game.Pin)
+
+	players := game.Players
+	if players == nil {
+		if err := withDBRetry(func() error {
+			return s.db.WithContext(ctx).Where("game_id = ?", game.ID).Order("id").Find(&players).Error
+		}); err != nil {
+			return nil, fmt.Errorf("failed to load players: %v", err)
+		}
+	}
+	sort.SliceStable(players, func(i, j int) bool {
+		return players[i].ID < players[j].ID
+	})
+
 	// Convert players to GamePlayer format
-	gamePlayers := make([]GamePlayer, len(game.Players))
-	for i, player := range game.Players {
+	gamePlayers := make([]GamePlayer, len(players))
+	for i, player := range players {
 		gamePlayers[i] = GamePlayer{
 			ID:    player.ID,
 			Name:  player.Name,
@@ -792,8 +2989,7 @@ func (s *GameService) GetCurrentGameState(gamePin string) (*GameState, error) {
 		}
 	}
 
-	// Create and store new game state
-	newGameState := &GameState{
+	gameState := &GameState{
 		GameID:               game.ID,
 		QuizID:               game.QuizID,
 		Pin:                  normalizedPin,
@@ -801,8 +2997,63 @@ func (s *GameService) GetCurrentGameState(gamePin string) (*GameState, error) {
 		CurrentQuestionIndex: -1, // No active question
 		Players:              gamePlayers,
 		TotalQuestions:       len(game.Quiz.Questions),
+		ScheduledAt:          game.ScheduledAt,
+		ShuffleOptions:       game.ShuffleOptions,
+	}
+	if game.ShuffleQuestions {
+		gameState.QuestionOrder = questionOrder(game)
+	}
+
+	if game.Status == "active" {
+		index, err := s.latestAnsweredQuestionIndex(ctx, game)
+		if err != nil {
+			return nil, err
+		}
+		gameState.CurrentQuestionIndex = index
+
+		if duration := s.gameDuration(game); duration > 0 && game.StartedAt != nil {
+			gameEndsAt := game.StartedAt.Add(duration)
+			gameState.GameEndsAt = &gameEndsAt
+		}
+	}
+
+	// A finished game's state is cleared on purpose once its grace period is
+	// over, so late callers get it rebuilt without it being kept again
+	if game.Status == "finished" {
+		gameState.Leaderboard = s.rankedLeaderboard(ctx, game.ID)
+		return gameState, nil
+	}
+
+	if err := s.storeGameState(ctx, normalizedPin, gameState); err != nil {
+		s.logger.Error("Failed to store rebuilt game state", "game_pin", normalizedPin, "error", err)
+	}
+	gameState.Leaderboard = s.refreshLeaderboard(ctx, normalizedPin, game.ID)
+	return gameState, nil
+}
+
+// latestAnsweredQuestionIndex is the index of the furthest question with a
+// recorded answer, or 0 since starting a game always starts its first question
+func (s *GameService) latestAnsweredQuestionIndex(ctx context.Context, game *models.Game) (int, error) {
+	var questionIDs []uint
+	if err := withDBRetry(func() error {
+		return s.db.WithContext(ctx).Model(&models.GameAnswer{}).
+			Where("game_id = ?", game.ID).
+			Distinct().
+			Pluck("question_id", &questionIDs).Error
+	}); err != nil {
+		return 0, fmt.Errorf("failed to load answers: %v", err)
 	}
 
-	s.storeGameState(normalizedPin, newGameState)
-	return newGameState, nil
+	answered := make(map[uint]bool, len(questionIDs))
+	for _, id := range questionIDs {
+		answered[id] = true
+	}
+
+	latest := 0
+	for i, question := range game.Quiz.Questions {
+		if answered[question.ID] {
+			latest = i
+		}
+	}
+	return latest, nil
 }
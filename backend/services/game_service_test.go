@@ -0,0 +1,2475 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"openquiz/models"
+)
+
+func TestStateExpirationExtendsPastScheduledStart(t *testing.T) {
+	s := &GameService{gameStateTTL: time.Hour}
+
+	if got := s.stateExpiration(&GameState{}); got != time.Hour {
+		t.Errorf("unscheduled game: got %v, want %v", got, time.Hour)
+	}
+
+	scheduledAt := time.Now().Add(3 * time.Hour)
+	got := s.stateExpiration(&GameState{ScheduledAt: &scheduledAt})
+	if got < 3*time.Hour+59*time.Minute || got > 4*time.Hour {
+		t.Errorf("scheduled game: got %v, want about 4h", got)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	if got := s.stateExpiration(&GameState{ScheduledAt: &past}); got != time.Hour {
+		t.Errorf("game scheduled in the past: got %v, want %v", got, time.Hour)
+	}
+}
+
+func TestRecordEventExpiresWithGameState(t *testing.T) {
+	client := testRedis(t)
+	s := &GameService{redis: client, logger: testLogger, keyPrefix: testKeyPrefix(t, client), gameStateTTL: time.Hour}
+	ctx := context.Background()
+
+	scheduledAt := time.Now().Add(5 * time.Hour)
+	if err := s.storeGameState(ctx, "abc123", &GameState{Pin: "abc123", Status: "waiting", ScheduledAt: &scheduledAt}); err != nil {
+		t.Fatalf("storeGameState: %v", err)
+	}
+	if _, err := s.RecordEvent(ctx, "abc123", "player_joined", "", map[string]string{"name": "Ada"}); err != nil {
+		t.Fatalf("RecordEvent: %v", err)
+	}
+
+	stateTTL := client.PTTL(ctx, s.gameKey("abc123")).Val()
+	for _, key := range []string{s.gameKey("abc123", "events"), s.gameKey("abc123", "seq")} {
+		ttl := client.PTTL(ctx, key).Val()
+		if ttl < stateTTL-time.Minute || ttl > stateTTL {
+			t.Errorf("%s expires in %v, want about %v like the state", key, ttl, stateTTL)
+		}
+	}
+}
+
+func TestStateRewriteExtendsTTL(t *testing.T) {
+	client := testRedis(t)
+	s := &GameService{redis: client, logger: testLogger, keyPrefix: testKeyPrefix(t, client), gameStateTTL: time.Hour}
+	ctx := context.Background()
+
+	state := &GameState{Pin: "abc123", Status: "active"}
+	if err := s.storeGameState(ctx, "abc123", state); err != nil {
+		t.Fatalf("storeGameState: %v", err)
+	}
+	if _, err := s.RecordEvent(ctx, "abc123", "question_start", "", nil); err != nil {
+		t.Fatalf("RecordEvent: %v", err)
+	}
+
+	// Stand in for a long session by letting the keys run nearly out
+	for _, key := range []string{s.gameKey("abc123"), s.gameKey("abc123", "events"), s.gameKey("abc123", "seq")} {
+		client.PExpire(ctx, key, time.Minute)
+	}
+
+	state.CurrentQuestionIndex = 1
+	if err := s.storeGameState(ctx, "abc123", state); err != nil {
+		t.Fatalf("storeGameState: %v", err)
+	}
+	for _, key := range []string{s.gameKey("abc123"), s.gameKey("abc123", "events"), s.gameKey("abc123", "seq")} {
+		if ttl := client.PTTL(ctx, key).Val(); ttl < 59*time.Minute || ttl > time.Hour {
+			t.Errorf("%s expires in %v after the rewrite, want about %v", key, ttl, time.Hour)
+		}
+	}
+}
+
+func TestAnswerTimeSpent(t *testing.T) {
+	startedAt := time.Now().Add(-4 * time.Second)
+	started := &GameState{QuestionStartedAt: &startedAt}
+
+	tests := []struct {
+		name       string
+		gameState  *GameState
+		clientTime int
+		want       int
+	}{
+		{"server start time wins over the client", started, 1, 4},
+		{"server time is capped at the limit", &GameState{QuestionStartedAt: timePtr(time.Now().Add(-time.Minute))}, 1, 20},
+		{"client time without a start time", nil, 7, 7},
+		{"client time without a start time in the state", &GameState{}, 7, 7},
+		{"missing client time earns no bonus", nil, 0, 20},
+		{"negative client time earns no bonus", nil, -5, 20},
+		{"huge client time is capped at the limit", nil, 100000, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := answerTimeSpent(tt.gameState, tt.clientTime, 20); got != tt.want {
+				t.Errorf("answerTimeSpent() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestCheckAnswerWindow(t *testing.T) {
+	game := &models.Game{Quiz: models.Quiz{Questions: []models.Question{{ID: 11}, {ID: 12}, {ID: 13}}}}
+	now := time.Now()
+	endsAt := now.Add(10 * time.Second)
+	endedAt := now.Add(-time.Second)
+	startsAt := now.Add(2 * time.Second)
+
+	tests := []struct {
+		name       string
+		gameState  *GameState
+		questionID uint
+		wantErr    bool
+	}{
+		{"current question in time", &GameState{CurrentQuestionIndex: 1, CurrentQuestion: &GameQuestion{ID: 12}, QuestionEndsAt: &endsAt}, 12, false},
+		{"current question after its time", &GameState{CurrentQuestionIndex: 1, CurrentQuestion: &GameQuestion{ID: 12}, QuestionEndsAt: &endedAt}, 12, true},
+		{"past question", &GameState{CurrentQuestionIndex: 1, CurrentQuestion: &GameQuestion{ID: 12}, QuestionEndsAt: &endsAt}, 11, true},
+		{"future question", &GameState{CurrentQuestionIndex: 1, CurrentQuestion: &GameQuestion{ID: 12}, QuestionEndsAt: &endsAt}, 13, true},
+		{"before the first question", &GameState{CurrentQuestionIndex: -1}, 11, true},
+		{"during the countdown", &GameState{CurrentQuestionIndex: 1, QuestionStartsAt: &startsAt}, 12, true},
+		{"rebuilt state, current question", &GameState{CurrentQuestionIndex: 1}, 12, false},
+		{"rebuilt state, past question", &GameState{CurrentQuestionIndex: 1}, 11, true},
+		{"rebuilt state, future question", &GameState{CurrentQuestionIndex: 1}, 13, true},
+		{"rebuilt state, index out of range", &GameState{CurrentQuestionIndex: 3}, 13, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAnswerWindow(game, tt.gameState, tt.questionID, now)
+			if tt.wantErr && !errors.Is(err, ErrQuestionNotActive) {
+				t.Errorf("checkAnswerWindow() = %v, want ErrQuestionNotActive", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("checkAnswerWindow() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestSubmitAnswerRejectsAnswersOutsideTheCurrentQuestion(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, players := startTestGame(t, s, 3, "Ada", "Grace")
+	first, second := game.Quiz.Questions[0], game.Quiz.Questions[1]
+
+	answer := func(player *models.Player, question models.Question) error {
+		return s.SubmitAnswer(ctx, game.Pin, player.ID, &SubmitAnswerRequest{
+			PlayerID:   player.ID,
+			QuestionID: question.ID,
+			OptionID:   question.Options[0].ID,
+		}, nil)
+	}
+
+	if err := answer(players[0], second); !errors.Is(err, ErrQuestionNotActive) {
+		t.Errorf("answer to a future question: got %v, want ErrQuestionNotActive", err)
+	}
+
+	// Once the question's time is up, late answers are turned away
+	gameState := s.getGameState(ctx, game.Pin)
+	endedAt := time.Now().Add(-time.Second)
+	gameState.QuestionEndsAt = &endedAt
+	if err := s.storeGameState(ctx, game.Pin, gameState); err != nil {
+		t.Fatalf("storeGameState: %v", err)
+	}
+	if err := answer(players[0], first); !errors.Is(err, ErrQuestionNotActive) {
+		t.Errorf("late answer: got %v, want ErrQuestionNotActive", err)
+	}
+
+	// With the state lost, the rebuilt one still pins answers to the current question
+	if err := s.ClearGameState(ctx, game.Pin); err != nil {
+		t.Fatalf("ClearGameState: %v", err)
+	}
+	if err := answer(players[1], second); !errors.Is(err, ErrQuestionNotActive) {
+		t.Errorf("answer to a future question without state: got %v, want ErrQuestionNotActive", err)
+	}
+	if err := answer(players[1], first); err != nil {
+		t.Errorf("answer to the current question without state: %v", err)
+	}
+}
+
+func TestNextQuestionRebuildsMissingState(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, players := startTestGame(t, s, 3, "Ada", "Grace")
+	first := game.Quiz.Questions[0]
+
+	if err := s.SubmitAnswer(ctx, game.Pin, players[0].ID, &SubmitAnswerRequest{
+		PlayerID:   players[0].ID,
+		QuestionID: first.ID,
+		OptionID:   first.Options[0].ID,
+	}, nil); err != nil {
+		t.Fatalf("SubmitAnswer: %v", err)
+	}
+
+	// Redis loses the key mid-game
+	if err := s.ClearGameState(ctx, game.Pin); err != nil {
+		t.Fatalf("ClearGameState: %v", err)
+	}
+
+	if err := s.NextQuestion(ctx, game.Pin, nil); err != nil {
+		t.Fatalf("NextQuestion without state: %v", err)
+	}
+	gameState := s.getGameState(ctx, game.Pin)
+	if gameState == nil {
+		t.Fatal("no game state was stored after advancing")
+	}
+	if gameState.Status != "active" || gameState.CurrentQuestionIndex != 1 || len(gameState.Players) != 2 {
+		t.Errorf("state after advancing = %+v, want question 1 of an active game with 2 players", gameState)
+	}
+}
+
+func TestConcurrentAnswersFromOnePlayerScoreOnce(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, players := startTestGame(t, s, 2, "Ada")
+	player, question := players[0], game.Quiz.Questions[0]
+
+	const submissions = 8
+	errs := make([]error, submissions)
+	var ready, done sync.WaitGroup
+	start := make(chan struct{})
+	for i := range errs {
+		ready.Add(1)
+		done.Add(1)
+		go func(i int) {
+			defer done.Done()
+			ready.Done()
+			<-start
+			errs[i] = s.SubmitAnswer(ctx, game.Pin, player.ID, &SubmitAnswerRequest{
+				PlayerID:   player.ID,
+				QuestionID: question.ID,
+				OptionID:   question.Options[0].ID,
+			}, nil)
+		}(i)
+	}
+	ready.Wait()
+	close(start)
+	done.Wait()
+
+	accepted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			accepted++
+		case !errors.Is(err, ErrAnswerAlreadySubmitted):
+			t.Errorf("duplicate submission: got %v, want ErrAnswerAlreadySubmitted", err)
+		}
+	}
+	if accepted != 1 {
+		t.Fatalf("%d submissions accepted, want 1", accepted)
+	}
+
+	if err := s.EndQuestion(ctx, game.Pin, nil, 0); err != nil {
+		t.Fatalf("EndQuestion: %v", err)
+	}
+	var answers []models.GameAnswer
+	if err := s.db.Where("game_id = ? AND player_id = ?", game.ID, player.ID).Find(&answers).Error; err != nil {
+		t.Fatalf("load answers: %v", err)
+	}
+	if len(answers) != 1 {
+		t.Fatalf("%d answers stored, want 1", len(answers))
+	}
+	var scored models.Player
+	if err := s.db.First(&scored, player.ID).Error; err != nil {
+		t.Fatalf("load player: %v", err)
+	}
+	if scored.Score != answers[0].Points || scored.Score == 0 {
+		t.Errorf("score = %d, want the one correct answer's %d points", scored.Score, answers[0].Points)
+	}
+}
+
+func TestFinishedGameStateStaysClearedAfterSync(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user, game, _ := startTestGame(t, s, 2, "Ada")
+
+	if err := s.EndGame(ctx, game.Pin, user.ID, nil); err != nil {
+		t.Fatalf("EndGame: %v", err)
+	}
+	if err := s.ClearGameState(ctx, game.Pin); err != nil {
+		t.Fatalf("ClearGameState: %v", err)
+	}
+
+	gameState, err := s.GetCurrentGameState(ctx, game.Pin)
+	if err != nil {
+		t.Fatalf("GetCurrentGameState: %v", err)
+	}
+	if gameState.Status != "finished" {
+		t.Errorf("status = %q, want finished", gameState.Status)
+	}
+
+	if n := s.redis.Exists(ctx, s.gameKey(game.Pin)).Val(); n != 0 {
+		t.Error("syncing a finished game stored its state again")
+	}
+}
+
+func TestFinishedGameStateIsRemovedAfterTheGracePeriod(t *testing.T) {
+	s := newTestGameService(t)
+	s.finishedGrace = 300 * time.Millisecond
+	ctx := context.Background()
+	user, game, _ := startTestGame(t, s, 2, "Ada")
+
+	if err := s.EndGame(ctx, game.Pin, user.ID, nil); err != nil {
+		t.Fatalf("EndGame: %v", err)
+	}
+
+	// Late syncs still find the final state during the grace period
+	if gameState := s.getGameState(ctx, game.Pin); gameState == nil || gameState.Status != "finished" {
+		t.Fatalf("state right after finishing = %+v, want the finished state", gameState)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		keys := s.redis.Keys(ctx, s.gameKey(game.Pin)+"*").Val()
+		if len(keys) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("keys still stored after the grace period: %v", keys)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestEndGameForDepartedCreatorFinishesLikeAnyEarlyEnd(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, _ := startTestGame(t, s, 2, "Ada")
+
+	if err := s.EndGameForDepartedCreator(ctx, game.Pin, nil); err != nil {
+		t.Fatalf("EndGameForDepartedCreator: %v", err)
+	}
+
+	finished, err := s.GetGameByPin(ctx, game.Pin)
+	if err != nil {
+		t.Fatalf("GetGameByPin: %v", err)
+	}
+	if finished.Status != "finished" || finished.EndedAt == nil {
+		t.Errorf("game status = %q, ended_at = %v; want finished with an end time", finished.Status, finished.EndedAt)
+	}
+
+	gameState := s.getGameState(ctx, game.Pin)
+	if gameState == nil || gameState.Status != "finished" || gameState.CurrentQuestion != nil {
+		t.Errorf("game state = %+v, want finished with no current question", gameState)
+	}
+	// The running question's timer stops without scoring it
+	if !s.isQuestionEnded(ctx, game.Pin, 0) {
+		t.Error("the current question was not marked ended")
+	}
+
+	// Leaving again after the game ended is a no-op
+	if err := s.EndGameForDepartedCreator(ctx, game.Pin, nil); err != nil {
+		t.Errorf("second EndGameForDepartedCreator: %v", err)
+	}
+}
+
+func TestDisplayPinRoundTrips(t *testing.T) {
+	if got := DisplayPin("0a1b2c"); got != "0A1B2C" {
+		t.Errorf("DisplayPin() = %q, want 0A1B2C", got)
+	}
+	if got, err := NormalizePin(DisplayPin("0a1b2c")); err != nil || got != "0a1b2c" {
+		t.Errorf("NormalizePin(DisplayPin()) = %q, %v; want 0a1b2c", got, err)
+	}
+	if got := JoinURL("https://quiz.example.com/", "0a1b2c"); got != "https://quiz.example.com/join?pin=0A1B2C" {
+		t.Errorf("JoinURL() = %q", got)
+	}
+}
+
+func TestJoinURLIsBuiltFromTheConfiguredBase(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"https://quiz.example.com", "https://quiz.example.com/join?pin=0A1B2C"},
+		{"https://quiz.example.com/", "https://quiz.example.com/join?pin=0A1B2C"},
+		{"https://example.com/openquiz/", "https://example.com/openquiz/join?pin=0A1B2C"},
+		{"http://localhost:3000", "http://localhost:3000/join?pin=0A1B2C"},
+	}
+	for _, tt := range tests {
+		if got := JoinURL(tt.base, "0a1b2c"); got != tt.want {
+			t.Errorf("JoinURL(%q) = %q, want %q", tt.base, got, tt.want)
+		}
+	}
+
+	// The join details sit alongside the game's own fields
+	data, err := json.Marshal(StartGameResponse{
+		Game:     models.Game{Pin: "0A1B2C"},
+		JoinURL:  JoinURL("https://quiz.example.com", "0a1b2c"),
+		JoinCode: "0A1B2C",
+	})
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body["pin"] != "0A1B2C" || body["join_code"] != "0A1B2C" || body["join_url"] != "https://quiz.example.com/join?pin=0A1B2C" {
+		t.Errorf("response = %s, want the pin, join_code and join_url at the top level", data)
+	}
+}
+
+func TestNumericPinsAreDigitsOfTheConfiguredLength(t *testing.T) {
+	tests := []struct {
+		alphabet   string
+		length     int
+		wantChars  string
+		wantLength int
+	}{
+		{pinAlphabetNumeric, 8, "0123456789", 8},
+		{pinAlphabetNumeric, 4, "0123456789", 4},
+		{pinAlphabetNumeric, 2, "0123456789", defaultPinLength}, // too short for the join screen
+		{"hex", 6, "0123456789abcdef", 6},
+	}
+	for _, tt := range tests {
+		s := NewGameService(nil, nil, testLogger, "test-secret", "", 0, 0, tt.alphabet, tt.length, 0)
+		for i := 0; i < 200; i++ {
+			pin, err := randomPin(s.pinChars, s.pinLength)
+			if err != nil {
+				t.Fatalf("randomPin: %v", err)
+			}
+			if len(pin) != tt.wantLength || strings.Trim(pin, tt.wantChars) != "" {
+				t.Fatalf("%s PIN of length %d = %q, want %d of %q", tt.alphabet, tt.length, pin, tt.wantLength, tt.wantChars)
+			}
+			if _, err := NormalizePin(pin); err != nil {
+				t.Fatalf("generated PIN %q doesn't normalize: %v", pin, err)
+			}
+		}
+	}
+}
+
+func TestGeneratePinSkipsTakenPins(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(1))
+
+	// A deleted game still holds its PIN
+	const taken = "7777777777"
+	var existing models.Game
+	if err := s.db.Unscoped().Where(models.Game{Pin: taken}).
+		Attrs(models.Game{QuizID: quiz.ID, Status: "finished"}).
+		FirstOrCreate(&existing).Error; err != nil {
+		t.Fatalf("create game holding %s: %v", taken, err)
+	}
+	if err := s.db.Delete(&existing).Error; err != nil {
+		t.Fatalf("delete game: %v", err)
+	}
+
+	// With the only possible PIN taken it gives up after its attempts
+	s.pinChars, s.pinLength = "7", len(taken)
+	if pin, err := s.generatePin(ctx); err == nil {
+		t.Fatalf("generatePin() = %q with every PIN taken, want an error", pin)
+	}
+	// Otherwise it passes over the taken PIN
+	s.pinChars = "78"
+	for i := 0; i < 20; i++ {
+		pin, err := s.generatePin(ctx)
+		if err != nil {
+			t.Fatalf("generatePin: %v", err)
+		}
+		if pin == taken {
+			t.Fatalf("generatePin() returned the taken PIN %s", taken)
+		}
+	}
+}
+
+func TestResumeGameDuringCountdown(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, _ := startTestGame(t, s, 3, "Ada")
+	second := game.Quiz.Questions[1]
+
+	// countingDown stores the state the countdown to the second question
+	// leaves behind, as if the server stopped part way through it
+	countingDown := func(startsAt time.Time) {
+		t.Helper()
+		gameState := s.getGameState(ctx, game.Pin)
+		gameState.CurrentQuestionIndex = 1
+		gameState.CurrentQuestion = nil
+		gameState.HostQuestion = nil
+		gameState.QuestionStartedAt = nil
+		gameState.QuestionEndsAt = nil
+		gameState.QuestionStartsAt = &startsAt
+		if err := s.storeGameState(ctx, game.Pin, gameState); err != nil {
+			t.Fatalf("storeGameState: %v", err)
+		}
+	}
+	assertStarted := func(within time.Duration) {
+		t.Helper()
+		deadline := time.Now().Add(within)
+		for {
+			gameState := s.getGameState(ctx, game.Pin)
+			if gameState != nil && gameState.QuestionStartsAt == nil && gameState.CurrentQuestion != nil {
+				if gameState.CurrentQuestion.ID != second.ID || gameState.QuestionEndsAt == nil {
+					t.Errorf("resumed question = %+v, want question %d with an end time", gameState.CurrentQuestion, second.ID)
+				}
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("question still counting down after %v: %+v", within, gameState)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	// A countdown that ran out while the server was down starts the question
+	countingDown(time.Now().Add(-time.Second))
+	if !s.resumeGame(ctx, game, nil) {
+		t.Fatal("resumeGame did not resume the game")
+	}
+	assertStarted(0)
+
+	// One still running carries on and starts the question when it runs out
+	countingDown(time.Now().Add(time.Second))
+	if !s.resumeGame(ctx, game, NewHub(s, testLogger, 0, 0, 0)) {
+		t.Fatal("resumeGame did not resume the game")
+	}
+	if err := s.NextQuestion(ctx, game.Pin, nil); !errors.Is(err, ErrQuestionStarting) {
+		t.Errorf("NextQuestion during the resumed countdown: got %v, want ErrQuestionStarting", err)
+	}
+	assertStarted(3 * time.Second)
+}
+
+// interruptQuestion leaves the running question due to end at endsAt, as if
+// the server stopped mid-question, and reloads the game the way startup does
+func interruptQuestion(t *testing.T, s *GameService, started *models.Game, endsAt time.Time) *models.Game {
+	t.Helper()
+	ctx := context.Background()
+
+	gameState := s.getGameState(ctx, started.Pin)
+	gameState.QuestionEndsAt = &endsAt
+	if err := s.storeGameState(ctx, started.Pin, gameState); err != nil {
+		t.Fatalf("storeGameState: %v", err)
+	}
+	var game models.Game
+	if err := s.db.Scopes(preloadOrderedQuestions).First(&game, started.ID).Error; err != nil {
+		t.Fatalf("load game: %v", err)
+	}
+	return &game
+}
+
+// waitForQuestionEnd fails unless the question is marked ended within the wait
+func waitForQuestionEnd(t *testing.T, s *GameService, pin string, questionIndex int, within time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(within)
+	for !s.isQuestionEnded(context.Background(), pin, questionIndex) {
+		if time.Now().After(deadline) {
+			t.Fatalf("question %d still running after %v", questionIndex, within)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestResumeGameRearmsTheQuestionTimer(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, started, _ := startTestGame(t, s, 2, "Ada")
+
+	// The question ends when the time it had left runs out, not before
+	if !s.resumeGame(ctx, interruptQuestion(t, s, started, time.Now().Add(2*time.Second)), nil) {
+		t.Fatal("resumeGame did not resume the game")
+	}
+	if s.isQuestionEnded(ctx, started.Pin, 0) {
+		t.Fatal("the question ended as soon as the game resumed")
+	}
+	waitForQuestionEnd(t, s, started.Pin, 0, 4*time.Second)
+}
+
+func TestResumeGameEndsAQuestionThatRanOutWhileDown(t *testing.T) {
+	s := newTestGameService(t)
+	_, started, _ := startTestGame(t, s, 2, "Ada")
+
+	if !s.resumeGame(context.Background(), interruptQuestion(t, s, started, time.Now().Add(-time.Second)), nil) {
+		t.Fatal("resumeGame did not resume the game")
+	}
+	waitForQuestionEnd(t, s, started.Pin, 0, 2*time.Second)
+}
+
+func TestPrepareGameNeedsAFutureStart(t *testing.T) {
+	s := &GameService{logger: testLogger}
+	_, err := s.PrepareGame(context.Background(), 1, &PrepareGameRequest{QuizID: 1, ScheduledAt: time.Now().Add(-time.Minute)})
+	if err == nil {
+		t.Error("preparing a game scheduled in the past succeeded")
+	}
+}
+
+func TestPlayersJoinPreparedGameBeforeItStarts(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(2))
+
+	scheduledAt := time.Now().Add(3 * time.Hour)
+	game, err := s.PrepareGame(ctx, user.ID, &PrepareGameRequest{QuizID: quiz.ID, ScheduledAt: scheduledAt})
+	if err != nil {
+		t.Fatalf("PrepareGame: %v", err)
+	}
+	if game.Pin == "" || game.Status != "waiting" || game.ScheduledAt == nil || game.ScheduledAt.Sub(scheduledAt).Abs() > time.Second {
+		t.Errorf("prepared game = %+v, want a waiting game with a pin scheduled at %v", game, scheduledAt)
+	}
+
+	// The state is warm before anyone joins, and outlives the wait
+	gameState := s.getGameState(ctx, game.Pin)
+	if gameState == nil || gameState.Status != "waiting" || gameState.TotalQuestions != 2 || gameState.ScheduledAt == nil {
+		t.Fatalf("prepared game state = %+v, want waiting with 2 questions and a scheduled start", gameState)
+	}
+	if ttl := s.redis.PTTL(ctx, s.gameKey(game.Pin)).Val(); ttl < 3*time.Hour {
+		t.Errorf("game state expires in %v, before the scheduled start", ttl)
+	}
+
+	for _, name := range []string{"Ada", "Grace"} {
+		if _, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: name}); err != nil {
+			t.Fatalf("JoinGame(%s) before the start: %v", name, err)
+		}
+	}
+	gameState = s.getGameState(ctx, game.Pin)
+	if len(gameState.Players) != 2 || gameState.Status != "waiting" {
+		t.Errorf("state after joining = %+v, want 2 players still waiting", gameState)
+	}
+}
+
+func TestReconnectTokenIdentifiesThePlayer(t *testing.T) {
+	s := &GameService{jwtSecret: "test-secret"}
+
+	token, err := s.IssueReconnectToken("ABC123", 5)
+	if err != nil {
+		t.Fatalf("IssueReconnectToken: %v", err)
+	}
+	if playerID, err := s.ValidateReconnectToken("abc123", token); err != nil || playerID != 5 {
+		t.Errorf("ValidateReconnectToken() = %d, %v; want player 5", playerID, err)
+	}
+	if _, err := s.ValidateReconnectToken("def456", token); err == nil {
+		t.Error("a reconnect token was accepted for another game")
+	}
+
+	socketToken, err := s.IssueSocketToken("abc123", 5)
+	if err != nil {
+		t.Fatalf("IssueSocketToken: %v", err)
+	}
+	if _, err := s.ValidateReconnectToken("abc123", socketToken); err == nil {
+		t.Error("a socket token was accepted as a reconnect token")
+	}
+	if _, err := (&GameService{jwtSecret: "other-secret"}).ValidateReconnectToken("abc123", token); err == nil {
+		t.Error("a reconnect token signed with another secret was accepted")
+	}
+}
+
+func TestReconnectMidQuestionSyncsTimeLeft(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, players := startTestGame(t, s, 2, "Ada")
+
+	// The question started 8 of its 20 seconds ago
+	gameState := s.getGameState(ctx, game.Pin)
+	startedAt := time.Now().Add(-8 * time.Second)
+	endsAt := startedAt.Add(20 * time.Second)
+	gameState.QuestionStartedAt = &startedAt
+	gameState.QuestionEndsAt = &endsAt
+	if err := s.storeGameState(ctx, game.Pin, gameState); err != nil {
+		t.Fatalf("storeGameState: %v", err)
+	}
+
+	h := NewHub(s, testLogger, 0, 0, 0)
+	client := connectTestClient(h, game.Pin, RolePlayer, players[0].ID)
+	if err := h.SendGameStateSync(client, "", 0, nil); err != nil {
+		t.Fatalf("SendGameStateSync: %v", err)
+	}
+
+	message := readMessage(t, client)
+	payload, _ := message.Payload.(map[string]interface{})
+	question, _ := payload["current_question"].(map[string]interface{})
+	timeLeft, _ := question["time_left"].(float64)
+	if message.Type != "game_state_sync" || timeLeft < 11 || timeLeft > 12 {
+		t.Errorf("sync = %+v, want about 12 seconds left", message)
+	}
+}
+
+func TestTrainingModeRevealsCorrectnessLive(t *testing.T) {
+	for _, trainingMode := range []bool{false, true} {
+		t.Run(fmt.Sprintf("training mode %v", trainingMode), func(t *testing.T) {
+			s := newTestGameService(t)
+			ctx := context.Background()
+			_, game, players := startTestGameWith(t, s, 2, StartGameRequest{TrainingMode: trainingMode}, "Ada", "Grace")
+			question := game.Quiz.Questions[0]
+
+			h := NewHub(s, testLogger, 0, 0, 0)
+			host := connectTestClient(h, game.Pin, RoleHost, game.Quiz.UserID)
+			connectTestClient(h, game.Pin, RolePlayer, players[0].ID)
+			connectTestClient(h, game.Pin, RolePlayer, players[1].ID)
+
+			if err := s.SubmitAnswer(ctx, game.Pin, players[0].ID, &SubmitAnswerRequest{
+				PlayerID:   players[0].ID,
+				QuestionID: question.ID,
+				OptionID:   question.Options[1].ID,
+			}, h); err != nil {
+				t.Fatalf("SubmitAnswer: %v", err)
+			}
+
+			payload, _ := waitForMessage(t, host, "answer_submitted").Payload.(map[string]interface{})
+			isCorrect, revealed := payload["is_correct"]
+			if revealed != trainingMode {
+				t.Fatalf("answer_submitted = %v, want is_correct shown only in training mode", payload)
+			}
+			if trainingMode && (isCorrect != false || payload["correct_option_id"] != float64(question.Options[0].ID)) {
+				t.Errorf("answer_submitted = %v, want a wrong answer and the correct option", payload)
+			}
+			if _, shown := payload["correct_option_id"]; shown && !trainingMode {
+				t.Errorf("answer_submitted = %v revealed the correct option", payload)
+			}
+
+			// The last connected player answering ends a training question early
+			if err := s.SubmitAnswer(ctx, game.Pin, players[1].ID, &SubmitAnswerRequest{
+				PlayerID:   players[1].ID,
+				QuestionID: question.ID,
+				OptionID:   question.Options[0].ID,
+			}, h); err != nil {
+				t.Fatalf("SubmitAnswer: %v", err)
+			}
+			waitForMessage(t, host, "all_answered")
+			if trainingMode {
+				waitForMessage(t, host, "question_end")
+			}
+		})
+	}
+}
+
+func TestGetUserGamesAcrossQuizzes(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+
+	quizReq := func(title string) *CreateQuizRequest {
+		req := testQuizRequest(1)
+		req.Title = title
+		return req
+	}
+	history := createTestQuiz(t, s.db, user.ID, quizReq("History"))
+	science := createTestQuiz(t, s.db, user.ID, quizReq("Science"))
+
+	start := func(quizID uint) *models.Game {
+		game, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quizID})
+		if err != nil {
+			t.Fatalf("StartGame: %v", err)
+		}
+		return game
+	}
+	waiting := start(history.ID)
+	for _, name := range []string{"Ada", "Grace"} {
+		if _, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: waiting.Pin, Name: name}); err != nil {
+			t.Fatalf("JoinGame: %v", err)
+		}
+	}
+	active := start(science.ID)
+	if _, err := s.StartQuiz(ctx, active.Pin, user.ID, nil); err != nil {
+		t.Fatalf("StartQuiz: %v", err)
+	}
+	finished := start(history.ID)
+	if _, err := s.StartQuiz(ctx, finished.Pin, user.ID, nil); err != nil {
+		t.Fatalf("StartQuiz: %v", err)
+	}
+	if err := s.EndGame(ctx, finished.Pin, user.ID, nil); err != nil {
+		t.Fatalf("EndGame: %v", err)
+	}
+
+	// Someone else's game never shows up
+	startTestGame(t, s, 1)
+
+	all, err := s.GetUserGames(ctx, user.ID, &ListGamesQuery{})
+	if err != nil {
+		t.Fatalf("GetUserGames: %v", err)
+	}
+	if all.Total != 3 || len(all.Games) != 3 || all.Page != 1 || all.PageSize != 20 {
+		t.Fatalf("all games = %+v, want 3 on the first page of 20", all)
+	}
+	byID := make(map[uint]GameSummary)
+	for _, game := range all.Games {
+		byID[game.ID] = game
+	}
+	if got := byID[waiting.ID]; got.QuizTitle != "History" || got.Status != "waiting" || got.PlayerCount != 2 || got.Pin != DisplayPin(waiting.Pin) {
+		t.Errorf("waiting game = %+v, want History, waiting, 2 players and its display pin", got)
+	}
+	if got := byID[active.ID]; got.QuizTitle != "Science" || got.Status != "active" || got.StartedAt == nil {
+		t.Errorf("active game = %+v, want a started Science game", got)
+	}
+	if got := byID[finished.ID]; got.Status != "finished" || got.EndedAt == nil {
+		t.Errorf("finished game = %+v, want an ended game", got)
+	}
+
+	filtered, err := s.GetUserGames(ctx, user.ID, &ListGamesQuery{Status: "active"})
+	if err != nil {
+		t.Fatalf("GetUserGames(active): %v", err)
+	}
+	if filtered.Total != 1 || len(filtered.Games) != 1 || filtered.Games[0].ID != active.ID {
+		t.Errorf("active games = %+v, want just the Science game", filtered)
+	}
+
+	paged, err := s.GetUserGames(ctx, user.ID, &ListGamesQuery{Page: 2, PageSize: 2})
+	if err != nil {
+		t.Fatalf("GetUserGames(page 2): %v", err)
+	}
+	if paged.Total != 3 || len(paged.Games) != 1 {
+		t.Errorf("second page of 2 = %+v, want the one remaining game of 3", paged)
+	}
+
+	later, err := s.GetUserGames(ctx, user.ID, &ListGamesQuery{From: time.Now().AddDate(0, 0, 2)})
+	if err != nil {
+		t.Fatalf("GetUserGames(from): %v", err)
+	}
+	if later.Total != 0 {
+		t.Errorf("games from the day after tomorrow = %+v, want none", later)
+	}
+}
+
+func TestApplyConfidence(t *testing.T) {
+	tests := []struct {
+		name       string
+		points     int
+		confidence int
+		isCorrect  bool
+		want       int
+	}{
+		{"unrated correct answer", 150, 0, true, 150},
+		{"unrated wrong answer", 0, 0, false, 0},
+		{"guessed correct answer", 150, 1, true, 150},
+		{"fairly sure correct answer", 150, 2, true, 225},
+		{"certain correct answer", 150, 3, true, 300},
+		{"guessed wrong answer", 0, 1, false, 0},
+		{"fairly sure wrong answer", 0, 2, false, -25},
+		{"certain wrong answer", 0, 3, false, -50},
+		{"certain wrong answer on top of negative marking", -10, 3, false, -60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyConfidence(tt.points, tt.confidence, tt.isCorrect); got != tt.want {
+				t.Errorf("applyConfidence(%d, %d, %v) = %d, want %d", tt.points, tt.confidence, tt.isCorrect, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGameKeysUseThePrefix(t *testing.T) {
+	s := &GameService{keyPrefix: "staging:"}
+	if got := s.gameKey("abc123"); got != "staging:game:abc123" {
+		t.Errorf("gameKey() = %q", got)
+	}
+	if got := s.gameKey("abc123", "events"); got != "staging:game:abc123:events" {
+		t.Errorf("gameKey(events) = %q", got)
+	}
+	if got := (&GameService{}).gameKey("abc123", "question", "2", "ended"); got != "game:abc123:question:2:ended" {
+		t.Errorf("unprefixed gameKey() = %q", got)
+	}
+}
+
+func TestGameStateIsStoredUnderThePrefix(t *testing.T) {
+	client := testRedis(t)
+	prefix := testKeyPrefix(t, client)
+	staging := &GameService{redis: client, logger: testLogger, keyPrefix: prefix + "staging:", gameStateTTL: time.Hour}
+	production := &GameService{redis: client, logger: testLogger, keyPrefix: prefix + "production:", gameStateTTL: time.Hour}
+	ctx := context.Background()
+
+	if err := staging.storeGameState(ctx, "abc123", &GameState{Pin: "abc123", Status: "waiting"}); err != nil {
+		t.Fatalf("storeGameState: %v", err)
+	}
+	if n := client.Exists(ctx, prefix+"staging:game:abc123").Val(); n != 1 {
+		t.Error("the state wasn't written under the configured prefix")
+	}
+	if got := staging.getGameState(ctx, "abc123"); got == nil || got.Status != "waiting" {
+		t.Errorf("read back %+v, want the waiting state", got)
+	}
+	if got := production.getGameState(ctx, "abc123"); got != nil {
+		t.Errorf("another prefix read %+v, want nothing", got)
+	}
+}
+
+func TestGetQuestionTimer(t *testing.T) {
+	client := testRedis(t)
+	s := &GameService{redis: client, logger: testLogger, keyPrefix: testKeyPrefix(t, client), gameStateTTL: time.Hour}
+	ctx := context.Background()
+
+	store := func(gameState *GameState) {
+		t.Helper()
+		gameState.Pin = "abc123"
+		if err := s.storeGameState(ctx, "abc123", gameState); err != nil {
+			t.Fatalf("storeGameState: %v", err)
+		}
+	}
+	timer := func() *QuestionTimer {
+		t.Helper()
+		timer, err := s.GetQuestionTimer(ctx, "ABC123")
+		if err != nil {
+			t.Fatalf("GetQuestionTimer: %v", err)
+		}
+		return timer
+	}
+
+	store(&GameState{Status: "waiting", CurrentQuestionIndex: -1})
+	if got := timer(); got.Active || got.QuestionIndex != -1 || got.TimeLeft != 0 {
+		t.Errorf("timer before the first question = %+v, want inactive", got)
+	}
+
+	endsAt := time.Now().Add(12 * time.Second)
+	store(&GameState{
+		Status:               "active",
+		CurrentQuestionIndex: 2,
+		CurrentQuestion:      &GameQuestion{ID: 13, TimeLimit: 20},
+		QuestionEndsAt:       &endsAt,
+	})
+	got := timer()
+	if !got.Active || got.QuestionIndex != 2 || got.TimeLimit != 20 || got.TimeLeft < 11 || got.TimeLeft > 12 || got.EndsAt == nil {
+		t.Errorf("running timer = %+v, want question 2 with about 12 of 20 seconds left", got)
+	}
+
+	// The question is over once it's marked ended, even with time on the clock
+	s.markQuestionEnded(ctx, "abc123", 2)
+	if got := timer(); got.Active || got.TimeLeft != 0 {
+		t.Errorf("timer of an ended question = %+v, want inactive", got)
+	}
+
+	endedAt := time.Now().Add(-time.Second)
+	store(&GameState{
+		Status:               "active",
+		CurrentQuestionIndex: 3,
+		CurrentQuestion:      &GameQuestion{ID: 14, TimeLimit: 20},
+		QuestionEndsAt:       &endedAt,
+	})
+	if got := timer(); got.Active || got.TimeLeft != 0 {
+		t.Errorf("timer after time ran out = %+v, want inactive", got)
+	}
+}
+
+func TestGameDurationIsCappedByTheServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		ceiling     time.Duration
+		maxDuration int
+		want        time.Duration
+	}{
+		{"no limits", 0, 0, 0},
+		{"game limit without a ceiling", 0, 30, 30 * time.Minute},
+		{"ceiling without a game limit", 2 * time.Hour, 0, 2 * time.Hour},
+		{"game limit under the ceiling", 2 * time.Hour, 30, 30 * time.Minute},
+		{"game limit over the ceiling", 2 * time.Hour, 600, 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &GameService{maxGameDuration: tt.ceiling}
+			if got := s.gameDuration(&models.Game{MaxDuration: tt.maxDuration}); got != tt.want {
+				t.Errorf("gameDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGameFinishesAtItsDeadline(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, _ := startTestGameWith(t, s, 3, StartGameRequest{MaxDuration: 5}, "Ada")
+
+	gameState := s.getGameState(ctx, game.Pin)
+	if gameState.GameEndsAt == nil || time.Until(*gameState.GameEndsAt) < 4*time.Minute || time.Until(*gameState.GameEndsAt) > 5*time.Minute {
+		t.Fatalf("game ends at %v, want about 5 minutes from now", gameState.GameEndsAt)
+	}
+
+	// Run the deadline as if its time had come, mid-question
+	h := NewHub(s, testLogger, 0, 0, 0)
+	host := connectTestClient(h, game.Pin, RoleHost, game.Quiz.UserID)
+	s.runGameDeadline(ctx, strings.ToLower(game.Pin), time.Now(), h)
+
+	payload, _ := waitForMessage(t, host, "game_end").Payload.(map[string]interface{})
+	if payload["reason"] != "time_limit_reached" {
+		t.Errorf("game_end = %v, want reason time_limit_reached", payload)
+	}
+	finished, err := s.GetGameByPin(ctx, game.Pin)
+	if err != nil {
+		t.Fatalf("GetGameByPin: %v", err)
+	}
+	if finished.Status != "finished" {
+		t.Errorf("status = %q after the deadline, want finished", finished.Status)
+	}
+}
+
+func TestGradeFor(t *testing.T) {
+	rubric := []models.GradeBand{{MinPercent: 0, Label: "F"}, {MinPercent: 50, Label: "C"}, {MinPercent: 75, Label: "B"}, {MinPercent: 90, Label: "A"}}
+
+	tests := []struct {
+		name    string
+		rubric  []models.GradeBand
+		percent float64
+		want    string
+	}{
+		{"no rubric", nil, 80, ""},
+		{"zero score", rubric, 0, "F"},
+		{"just below a threshold", rubric, 49.9, "F"},
+		{"exactly on a threshold", rubric, 50, "C"},
+		{"between thresholds", rubric, 80, "B"},
+		{"perfect score", rubric, 100, "A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gradeFor(tt.rubric, tt.percent); got != tt.want {
+				t.Errorf("gradeFor(%v) = %q, want %q", tt.percent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCancelWaitingGame(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(2))
+
+	game, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	player, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: "Ada"})
+	if err != nil {
+		t.Fatalf("JoinGame: %v", err)
+	}
+
+	hub := newTestHub()
+	client := connectTestClient(hub, game.Pin, RolePlayer, player.ID)
+
+	if err := s.CancelGame(ctx, game.Pin, user.ID+1, hub); !errors.Is(err, ErrNotGameOwner) {
+		t.Fatalf("CancelGame() by another user = %v, want ErrNotGameOwner", err)
+	}
+	if err := s.CancelGame(ctx, game.Pin, user.ID, hub); err != nil {
+		t.Fatalf("CancelGame: %v", err)
+	}
+
+	if message := readMessage(t, client); message.Type != "game_cancelled" {
+		t.Errorf("player was sent %q, want game_cancelled", message.Type)
+	}
+	if _, open := <-client.send; open {
+		t.Error("the player's connection was left open")
+	}
+	if _, err := s.GetGameByPin(ctx, game.Pin); err == nil {
+		t.Error("the cancelled game is still stored")
+	}
+	var players int64
+	s.db.Model(&models.Player{}).Where("game_id = ?", game.ID).Count(&players)
+	if players != 0 {
+		t.Errorf("%d players of the cancelled game are still stored", players)
+	}
+	if n := s.redis.Exists(ctx, s.gameKey(game.Pin)).Val(); n != 0 {
+		t.Error("the cancelled game's state was left in Redis")
+	}
+	if err := s.CancelGame(ctx, game.Pin, user.ID, hub); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("cancelling twice = %v, want ErrGameNotFound", err)
+	}
+}
+
+func TestCancelRefusesStartedGame(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user, game, _ := startTestGame(t, s, 2, "Ada")
+
+	if err := s.CancelGame(ctx, game.Pin, user.ID, nil); !errors.Is(err, ErrGameNotWaiting) {
+		t.Fatalf("CancelGame() on an active game = %v, want ErrGameNotWaiting", err)
+	}
+	if stored, err := s.GetGameByPin(ctx, game.Pin); err != nil || stored.Status != "active" {
+		t.Errorf("after a refused cancel the game is %+v, %v; want it still active", stored, err)
+	}
+}
+
+func TestNormalizePin(t *testing.T) {
+	tests := []struct {
+		pin     string
+		want    string
+		wantErr bool
+	}{
+		{"0a1b2c", "0a1b2c", false},
+		{"0A1B2C", "0a1b2c", false},
+		{"123456", "123456", false},
+		{"abcd", "abcd", false},
+		{"abcdef0123", "abcdef0123", false},
+		{"", "", true},
+		{"   ", "", true},
+		{"abc", "", true},
+		{"abcdef01234", "", true},
+		{"0a1b2g", "", true},
+		{"0a 1b2", "", true},
+		{"0a%2F1b", "", true},
+		{"0a1b2c\n", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizePin(tt.pin)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidPin) {
+				t.Errorf("NormalizePin(%q) = %q, %v; want ErrInvalidPin", tt.pin, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizePin(%q) = %q, %v; want %q", tt.pin, got, err, tt.want)
+		}
+	}
+}
+
+func TestSoftDeletedPlayerNameCanBeReused(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(1))
+
+	game, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	player, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: "Ada"})
+	if err != nil {
+		t.Fatalf("JoinGame: %v", err)
+	}
+	if _, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: "ada"}); err == nil {
+		t.Fatal("a second active player took the name")
+	}
+
+	if err := s.db.Delete(player).Error; err != nil {
+		t.Fatalf("soft-delete player: %v", err)
+	}
+	rejoined, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: "Ada"})
+	if err != nil {
+		t.Fatalf("JoinGame after the first Ada was removed: %v", err)
+	}
+	if rejoined.ID == player.ID {
+		t.Error("the removed player was brought back instead of a new one joining")
+	}
+}
+
+func TestNormalizePlayerName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "Ada", "Ada", false},
+		{"surrounding whitespace", "  Ada Lovelace \t", "Ada Lovelace", false},
+		{"control characters", "A\x00d\x1ba\u0085", "Ada", false},
+		{"at the length cap", strings.Repeat("a", maxPlayerNameLength), strings.Repeat("a", maxPlayerNameLength), false},
+		{"cap counts characters not bytes", strings.Repeat("é", maxPlayerNameLength), strings.Repeat("é", maxPlayerNameLength), false},
+		{"over the length cap", strings.Repeat("a", maxPlayerNameLength+1), "", true},
+		{"at the cap once trimmed", "  " + strings.Repeat("a", maxPlayerNameLength) + "  ", strings.Repeat("a", maxPlayerNameLength), false},
+		{"empty", "", "", true},
+		{"whitespace only", " \t\n ", "", true},
+		{"control characters only", "\x00\x07", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizePlayerName(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("normalizePlayerName(%q) = %q, want an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("normalizePlayerName(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinAndAnswerErrorsAreDistinct(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+
+	if _, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: "fedcba", Name: "Ada"}); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("joining an unknown game = %v, want ErrGameNotFound", err)
+	}
+	answer := &SubmitAnswerRequest{PlayerID: 1, QuestionID: 1, OptionID: 1}
+	if err := s.SubmitAnswer(ctx, "fedcba", 1, answer, nil); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("answering in an unknown game = %v, want ErrGameNotFound", err)
+	}
+
+	// A game still in its lobby takes players but not answers
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(1))
+	waiting, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	player, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: waiting.Pin, Name: "Ada"})
+	if err != nil {
+		t.Fatalf("JoinGame: %v", err)
+	}
+	answer.PlayerID = player.ID
+	if err := s.SubmitAnswer(ctx, waiting.Pin, player.ID, answer, nil); !errors.Is(err, ErrGameNotActive) {
+		t.Errorf("answering before the game starts = %v, want ErrGameNotActive", err)
+	}
+
+	// A finished game takes neither
+	user, finished, _ := startTestGame(t, s, 1, "Grace")
+	if err := s.EndGame(ctx, finished.Pin, user.ID, nil); err != nil {
+		t.Fatalf("EndGame: %v", err)
+	}
+	if _, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: finished.Pin, Name: "Ada"}); !errors.Is(err, ErrGameNotJoinable) {
+		t.Errorf("joining a finished game = %v, want ErrGameNotJoinable", err)
+	}
+}
+
+// autoStartRunning reports whether the game has an auto-start countdown going
+func autoStartRunning(s *GameService, pin string) bool {
+	s.autoStartMu.Lock()
+	defer s.autoStartMu.Unlock()
+	_, running := s.autoStarts[strings.ToLower(pin)]
+	return running
+}
+
+func TestReachingAutoStartThresholdStartsTheGame(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(2))
+	game, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID, AutoStartPlayers: 2})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	hub := newTestHub()
+	host := connectTestClient(hub, game.Pin, RoleHost, user.ID)
+
+	join := func(name string) {
+		t.Helper()
+		if _, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: name}); err != nil {
+			t.Fatalf("JoinGame(%s): %v", name, err)
+		}
+		s.CheckAutoStart(ctx, game.Pin, hub)
+	}
+
+	join("Ada")
+	if autoStartRunning(s, game.Pin) {
+		t.Fatal("the countdown began below the threshold")
+	}
+
+	join("Grace")
+	if !autoStartRunning(s, game.Pin) {
+		t.Fatal("reaching the threshold didn't begin the countdown")
+	}
+	countdown := waitForMessage(t, host, "countdown")
+	if payload, ok := countdown.Payload.(map[string]interface{}); !ok || payload["seconds_left"] != float64(autoStartCountdown) {
+		t.Errorf("first countdown payload = %v, want seconds_left %d", countdown.Payload, autoStartCountdown)
+	}
+
+	// A late joiner doesn't begin a second countdown
+	join("Linus")
+
+	deadline := time.Now().Add(time.Duration(autoStartCountdown+3) * time.Second)
+	for {
+		started, err := s.GetGameByPin(ctx, game.Pin)
+		if err != nil {
+			t.Fatalf("GetGameByPin: %v", err)
+		}
+		if started.Status == "active" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("game is still %q after the countdown", started.Status)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if gameState := s.getGameState(ctx, game.Pin); gameState == nil || gameState.CurrentQuestionIndex != 0 {
+		t.Errorf("state after auto-start = %+v, want the first question running", gameState)
+	}
+
+	countdowns := 1
+	for _, message := range drainMessages(t, host) {
+		if message.Type == "countdown" {
+			countdowns++
+		}
+	}
+	if countdowns != autoStartCountdown {
+		t.Errorf("host saw %d countdown events, want %d", countdowns, autoStartCountdown)
+	}
+}
+
+func TestManualStartCancelsAutoStart(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(2))
+	game, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID, AutoStartPlayers: 1})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	hub := newTestHub()
+	if _, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: "Ada"}); err != nil {
+		t.Fatalf("JoinGame: %v", err)
+	}
+	s.CheckAutoStart(ctx, game.Pin, hub)
+	if !autoStartRunning(s, game.Pin) {
+		t.Fatal("reaching the threshold didn't begin the countdown")
+	}
+
+	if _, err := s.StartQuiz(ctx, game.Pin, user.ID, hub); err != nil {
+		t.Fatalf("StartQuiz: %v", err)
+	}
+	if autoStartRunning(s, game.Pin) {
+		t.Error("the countdown kept running after the host started the game")
+	}
+}
+
+func TestGetGameWithStateIncludesLiveState(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, _ := startTestGame(t, s, 3, "Ada")
+
+	result, err := s.GetGameWithState(ctx, game.Pin)
+	if err != nil {
+		t.Fatalf("GetGameWithState: %v", err)
+	}
+	if result.ID != game.ID || result.Pin != DisplayPin(game.Pin) {
+		t.Errorf("game record = %d %q, want %d %q", result.ID, result.Pin, game.ID, DisplayPin(game.Pin))
+	}
+	if result.State == nil {
+		t.Fatal("live state is missing")
+	}
+	limit := game.Quiz.Questions[0].TimeLimit
+	if result.State.Status != "active" || result.State.CurrentQuestionIndex != 0 || result.State.TotalQuestions != 3 {
+		t.Errorf("live state = %+v, want question 0 of 3 active", result.State)
+	}
+	if result.State.TimeLeft <= 0 || result.State.TimeLeft > limit || result.State.QuestionEndsAt == nil {
+		t.Errorf("time left = %d (ends at %v), want up to %d seconds", result.State.TimeLeft, result.State.QuestionEndsAt, limit)
+	}
+
+	if _, err := s.GetGameWithState(ctx, "fedcba"); !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("unknown game = %v, want ErrGameNotFound", err)
+	}
+}
+
+// setStanding gives a player a score and a total answer time for ranking
+func setStanding(t *testing.T, s *GameService, game *models.Game, player *models.Player, score, timeSpent int) {
+	t.Helper()
+
+	question := game.Quiz.Questions[0]
+	if err := s.db.Model(player).Update("score", score).Error; err != nil {
+		t.Fatalf("set score: %v", err)
+	}
+	if err := s.db.Create(&models.GameAnswer{
+		GameID:     game.ID,
+		PlayerID:   player.ID,
+		QuestionID: question.ID,
+		OptionID:   question.Options[0].ID,
+		IsCorrect:  true,
+		TimeSpent:  timeSpent,
+		Points:     score,
+	}).Error; err != nil {
+		t.Fatalf("create answer: %v", err)
+	}
+}
+
+// standings lists a leaderboard as "name:rank" entries
+func standings(leaderboard []GamePlayer) []string {
+	entries := make([]string, len(leaderboard))
+	for i, player := range leaderboard {
+		entries[i] = fmt.Sprintf("%s:%d", player.Name, player.Rank)
+	}
+	return entries
+}
+
+func TestRankedLeaderboard(t *testing.T) {
+	tests := []struct {
+		name      string
+		standings map[string][2]int // score and total answer time by player
+		want      []string
+	}{
+		{
+			name:      "clean ranking",
+			standings: map[string][2]int{"Ada": {300, 10}, "Grace": {200, 5}, "Linus": {100, 1}, "Ken": {50, 1}},
+			want:      []string{"Ada:1", "Grace:2", "Linus:3", "Ken:4"},
+		},
+		{
+			name:      "equal scores broken by answer time",
+			standings: map[string][2]int{"Ada": {200, 9}, "Grace": {200, 4}, "Linus": {100, 1}},
+			want:      []string{"Grace:1", "Ada:2", "Linus:3"},
+		},
+		{
+			name:      "two-way tie shares a rank",
+			standings: map[string][2]int{"Ada": {200, 4}, "Grace": {200, 4}, "Linus": {100, 1}},
+			want:      []string{"Ada:1", "Grace:1", "Linus:3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestGameService(t)
+			names := make([]string, 0, len(tt.standings))
+			for _, entry := range tt.want {
+				names = append(names, strings.SplitN(entry, ":", 2)[0])
+			}
+			// Joined in alphabetical order so a tie keeps the earlier joiner first
+			sort.Strings(names)
+			_, game, players := startTestGame(t, s, 1, names...)
+			for _, player := range players {
+				standing := tt.standings[player.Name]
+				setStanding(t, s, game, player, standing[0], standing[1])
+			}
+
+			got := standings(s.rankedLeaderboard(context.Background(), game.ID))
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("rankedLeaderboard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodium(t *testing.T) {
+	tests := []struct {
+		name        string
+		leaderboard []GamePlayer
+		want        []string
+	}{
+		{"empty game", nil, []string{}},
+		{"fewer than three players", []GamePlayer{{Name: "Ada", Rank: 1}, {Name: "Grace", Rank: 2}}, []string{"Ada:1", "Grace:2"}},
+		{
+			"clean top three",
+			[]GamePlayer{{Name: "Ada", Rank: 1}, {Name: "Grace", Rank: 2}, {Name: "Linus", Rank: 3}, {Name: "Ken", Rank: 4}},
+			[]string{"Ada:1", "Grace:2", "Linus:3"},
+		},
+		{
+			"tie for third",
+			[]GamePlayer{{Name: "Ada", Rank: 1}, {Name: "Grace", Rank: 2}, {Name: "Linus", Rank: 3}, {Name: "Ken", Rank: 3}, {Name: "Rob", Rank: 5}},
+			[]string{"Ada:1", "Grace:2", "Linus:3", "Ken:3"},
+		},
+		{
+			"tie for first",
+			[]GamePlayer{{Name: "Ada", Rank: 1}, {Name: "Grace", Rank: 1}, {Name: "Linus", Rank: 3}, {Name: "Ken", Rank: 4}},
+			[]string{"Ada:1", "Grace:1", "Linus:3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := standings(podium(tt.leaderboard))
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("podium() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoringProfiles(t *testing.T) {
+	s := &GameService{}
+
+	tests := []struct {
+		name      string
+		quiz      models.Quiz
+		timeSpent int
+		want      int
+	}{
+		{"default instant answer", models.Quiz{}, 0, 150},
+		{"default half time", models.Quiz{}, 10, 125},
+		{"default last second", models.Quiz{}, 20, 100},
+		{"no speed bonus instant answer", models.Quiz{SpeedBonus: boolPtr(false)}, 0, 100},
+		{"no speed bonus last second", models.Quiz{SpeedBonus: boolPtr(false)}, 20, 100},
+		{"no speed bonus overrides a time bonus", models.Quiz{SpeedBonus: boolPtr(false), MaxTimeBonus: intPtr(200)}, 0, 100},
+		{"high base instant answer", models.Quiz{BasePoints: intPtr(1000), MaxTimeBonus: intPtr(500)}, 0, 1500},
+		{"high base half time", models.Quiz{BasePoints: intPtr(1000), MaxTimeBonus: intPtr(500)}, 10, 1250},
+		{"high base keeps the default bonus", models.Quiz{BasePoints: intPtr(1000)}, 0, 1050},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scoring := quizScoring(&tt.quiz)
+			if got := s.calculatePoints(scoring, tt.timeSpent, 20, true); got != tt.want {
+				t.Errorf("calculatePoints() = %d, want %d", got, tt.want)
+			}
+			if got := s.calculatePoints(scoring, tt.timeSpent, 20, false); got != 0 {
+				t.Errorf("wrong answer scored %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestNegativeMarking(t *testing.T) {
+	for _, floor := range []bool{false, true} {
+		t.Run(fmt.Sprintf("floor at zero %v", floor), func(t *testing.T) {
+			s := newTestGameService(t)
+			ctx := context.Background()
+			quizReq := testQuizRequest(2)
+			quizReq.PenaltyPoints = intPtr(30)
+			quizReq.FloorScoreAtZero = floor
+			_, game, players := startTestQuizGame(t, s, quizReq, StartGameRequest{}, "Ada", "Grace", "Linus")
+			question := game.Quiz.Questions[0]
+
+			// Ada guesses wrong, Grace is right and Linus doesn't answer
+			for i, option := range []models.Option{question.Options[1], question.Options[0]} {
+				if err := s.SubmitAnswer(ctx, game.Pin, players[i].ID, &SubmitAnswerRequest{
+					PlayerID:   players[i].ID,
+					QuestionID: question.ID,
+					OptionID:   option.ID,
+				}, nil); err != nil {
+					t.Fatalf("SubmitAnswer(%s): %v", players[i].Name, err)
+				}
+			}
+			if err := s.EndQuestion(ctx, game.Pin, nil, 0); err != nil {
+				t.Fatalf("EndQuestion: %v", err)
+			}
+
+			wantAda := -30
+			if floor {
+				wantAda = 0
+			}
+			var ada models.Player
+			if err := s.db.First(&ada, players[0].ID).Error; err != nil {
+				t.Fatalf("load player: %v", err)
+			}
+			if ada.Score != wantAda {
+				t.Errorf("score after a wrong answer = %d, want %d", ada.Score, wantAda)
+			}
+			var answer models.GameAnswer
+			if err := s.db.Where("player_id = ?", ada.ID).First(&answer).Error; err != nil {
+				t.Fatalf("load answer: %v", err)
+			}
+			if answer.Points != -30 {
+				t.Errorf("wrong answer earned %d points, want -30", answer.Points)
+			}
+
+			// A negative score sorts below a player who didn't answer at all
+			leaderboard := s.rankedLeaderboard(ctx, game.ID)
+			if len(leaderboard) != 3 || leaderboard[0].Name != "Grace" {
+				t.Fatalf("leaderboard = %v, want Grace first", standings(leaderboard))
+			}
+			if !floor && leaderboard[2].Name != "Ada" {
+				t.Errorf("leaderboard = %v, want Ada last", standings(leaderboard))
+			}
+		})
+	}
+}
+
+// shuffleTestGame is a game of n questions with IDs 1..n in authored order
+func shuffleTestGame(id uint, n int, shuffle bool) *models.Game {
+	game := &models.Game{ID: id, CreatedAt: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC), ShuffleQuestions: shuffle}
+	for i := 1; i <= n; i++ {
+		game.Quiz.Questions = append(game.Quiz.Questions, models.Question{ID: uint(i), Order: i - 1})
+	}
+	return game
+}
+
+func TestShuffleQuestionsIsStablePerGame(t *testing.T) {
+	first := shuffleTestGame(7, 10, true)
+	shuffleQuestions(first)
+	order := questionOrder(first)
+
+	if fmt.Sprint(order) == fmt.Sprint(questionOrder(shuffleTestGame(7, 10, false))) {
+		t.Errorf("shuffled order %v is the authored order", order)
+	}
+	sorted := append([]uint(nil), order...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if fmt.Sprint(sorted) != "[1 2 3 4 5 6 7 8 9 10]" {
+		t.Errorf("shuffled order %v isn't a permutation of the questions", order)
+	}
+
+	// Every load of the same game, e.g. after a reconnect, gets the same order
+	for i := 0; i < 5; i++ {
+		again := shuffleTestGame(7, 10, true)
+		shuffleQuestions(again)
+		if got := questionOrder(again); fmt.Sprint(got) != fmt.Sprint(order) {
+			t.Fatalf("load %d got order %v, want %v", i, got, order)
+		}
+	}
+
+	other := shuffleTestGame(8, 10, true)
+	shuffleQuestions(other)
+	if fmt.Sprint(questionOrder(other)) == fmt.Sprint(order) {
+		t.Error("another game got the same shuffled order")
+	}
+
+}
+
+func TestOrderQuestionsFollowsStoredOrder(t *testing.T) {
+	game := shuffleTestGame(7, 5, true)
+	// Question 6 was added after the game started and question 4 deleted
+	game.Quiz.Questions = append(game.Quiz.Questions[:3], game.Quiz.Questions[4], models.Question{ID: 6, Order: 5})
+
+	orderQuestions(game, []uint{5, 3, 1, 4, 2})
+	if got := questionOrder(game); fmt.Sprint(got) != "[5 3 1 2 6]" {
+		t.Errorf("order = %v, want [5 3 1 2 6]", got)
+	}
+}
+
+func TestShuffledGameKeepsItsOrderAcrossCalls(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, _ := startTestGameWith(t, s, 6, StartGameRequest{ShuffleQuestions: true}, "Ada")
+	order := questionOrder(game)
+
+	gameState := s.getGameState(ctx, game.Pin)
+	if gameState == nil || fmt.Sprint(gameState.QuestionOrder) != fmt.Sprint(order) {
+		t.Fatalf("stored order = %+v, want %v", gameState, order)
+	}
+	if gameState.CurrentQuestion == nil || gameState.CurrentQuestion.ID != order[0] {
+		t.Errorf("first question = %+v, want question %d", gameState.CurrentQuestion, order[0])
+	}
+
+	for i := 0; i < 3; i++ {
+		again, err := s.GetGameByPin(ctx, game.Pin)
+		if err != nil {
+			t.Fatalf("GetGameByPin: %v", err)
+		}
+		if got := questionOrder(again); fmt.Sprint(got) != fmt.Sprint(order) {
+			t.Fatalf("load %d got order %v, want %v", i, got, order)
+		}
+	}
+
+	// Question indexes follow the shuffled order, not the authored one
+	if err := s.StartQuestion(ctx, game.Pin, 1, nil); err != nil {
+		t.Fatalf("StartQuestion: %v", err)
+	}
+	if gameState := s.getGameState(ctx, game.Pin); gameState.CurrentQuestion == nil || gameState.CurrentQuestion.ID != order[1] {
+		t.Errorf("second question = %+v, want question %d", gameState.CurrentQuestion, order[1])
+	}
+
+	// Loads take the stored order rather than drawing it again from the seed
+	stored := s.getGameState(ctx, game.Pin)
+	for i, j := 0, len(stored.QuestionOrder)-1; i < j; i, j = i+1, j-1 {
+		stored.QuestionOrder[i], stored.QuestionOrder[j] = stored.QuestionOrder[j], stored.QuestionOrder[i]
+	}
+	if err := s.storeGameState(ctx, game.Pin, stored); err != nil {
+		t.Fatalf("storeGameState: %v", err)
+	}
+	again, err := s.GetGameByPin(ctx, game.Pin)
+	if err != nil {
+		t.Fatalf("GetGameByPin: %v", err)
+	}
+	if got := questionOrder(again); fmt.Sprint(got) != fmt.Sprint(stored.QuestionOrder) {
+		t.Errorf("order after storing %v = %v", stored.QuestionOrder, got)
+	}
+
+	unshuffled := shuffleTestGame(7, 10, false)
+	s.applyQuestionOrder(ctx, unshuffled)
+	if got := questionOrder(unshuffled); fmt.Sprint(got) != "[1 2 3 4 5 6 7 8 9 10]" {
+		t.Errorf("a game without shuffling was reordered to %v", got)
+	}
+}
+
+func TestVoteDistribution(t *testing.T) {
+	options := []models.Option{{ID: 1}, {ID: 2}, {ID: 3}}
+	answers := []models.GameAnswer{{OptionID: 2}, {OptionID: 1}, {OptionID: 2}}
+
+	got := voteDistribution(options, answers)
+	want := map[uint]int{1: 1, 2: 2, 3: 0}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("voteDistribution() = %v, want %v", got, want)
+	}
+}
+
+func TestPollShowsVotesAndScoresNothing(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	quizReq := &CreateQuizRequest{Title: "Lunch poll", Questions: []CreateQuestionRequest{{
+		Text:      "Tea or coffee?",
+		Type:      models.QuestionTypePoll,
+		TimeLimit: 20,
+		Options:   []CreateOptionRequest{{Text: "Tea"}, {Text: "Coffee"}, {Text: "Neither"}},
+	}}}
+	user, game, players := startTestQuizGame(t, s, quizReq, StartGameRequest{}, "Ada", "Grace", "Linus")
+	question := game.Quiz.Questions[0]
+	tea, coffee := question.Options[0], question.Options[1]
+
+	for i, option := range []models.Option{coffee, tea, coffee} {
+		if err := s.SubmitAnswer(ctx, game.Pin, players[i].ID, &SubmitAnswerRequest{
+			PlayerID:   players[i].ID,
+			QuestionID: question.ID,
+			OptionID:   option.ID,
+		}, nil); err != nil {
+			t.Fatalf("SubmitAnswer(%s): %v", players[i].Name, err)
+		}
+	}
+
+	hub := newTestHub()
+	host := connectTestClient(hub, game.Pin, RoleHost, user.ID)
+	if err := s.EndQuestion(ctx, game.Pin, hub, 0); err != nil {
+		t.Fatalf("EndQuestion: %v", err)
+	}
+
+	message := waitForMessage(t, host, "question_end")
+	payload, _ := message.Payload.(map[string]interface{})
+	want := map[string]interface{}{
+		fmt.Sprint(tea.ID):                 float64(1),
+		fmt.Sprint(coffee.ID):              float64(2),
+		fmt.Sprint(question.Options[2].ID): float64(0),
+	}
+	if fmt.Sprint(payload["vote_distribution"]) != fmt.Sprint(want) {
+		t.Errorf("vote_distribution = %v, want %v", payload["vote_distribution"], want)
+	}
+	if _, ok := payload["fastest_answer"]; ok {
+		t.Error("a poll named a fastest correct answer")
+	}
+
+	var scored int64
+	s.db.Model(&models.Player{}).Where("game_id = ? AND score <> 0", game.ID).Count(&scored)
+	if scored != 0 {
+		t.Errorf("%d players scored points for a poll vote", scored)
+	}
+}
+
+func TestStartQuizNeedsMinimumPlayers(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(1))
+	game, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID, MinPlayers: 2})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+
+	if _, err := s.StartQuiz(ctx, game.Pin, user.ID, nil); !errors.Is(err, ErrNotEnoughPlayers) {
+		t.Fatalf("starting an empty game = %v, want ErrNotEnoughPlayers", err)
+	}
+	if _, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: "Ada"}); err != nil {
+		t.Fatalf("JoinGame: %v", err)
+	}
+	if _, err := s.StartQuiz(ctx, game.Pin, user.ID, nil); !errors.Is(err, ErrNotEnoughPlayers) {
+		t.Fatalf("starting with one of two players = %v, want ErrNotEnoughPlayers", err)
+	}
+
+	if _, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: "Grace"}); err != nil {
+		t.Fatalf("JoinGame: %v", err)
+	}
+	if _, err := s.StartQuiz(ctx, game.Pin, user.ID, nil); err != nil {
+		t.Fatalf("starting with enough players: %v", err)
+	}
+}
+
+func TestStartQuizWithoutMinimumAllowsAnEmptyGame(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(1))
+	game, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	if _, err := s.StartQuiz(ctx, game.Pin, user.ID, nil); err != nil {
+		t.Fatalf("starting a demo game with no players: %v", err)
+	}
+}
+
+// leaderboardNames lists the player names of a leaderboard_update message
+func leaderboardNames(t *testing.T, message Message) []string {
+	t.Helper()
+
+	data, err := json.Marshal(message.Payload)
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	var payload struct {
+		Leaderboard []GamePlayer `json:"leaderboard"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("decode leaderboard_update payload %s: %v", data, err)
+	}
+	names := make([]string, len(payload.Leaderboard))
+	for i, player := range payload.Leaderboard {
+		names[i] = fmt.Sprintf("%s:%d", player.Name, player.Rank)
+	}
+	return names
+}
+
+func TestLeaderboardUpdateFollowsEachQuestion(t *testing.T) {
+	for _, show := range []bool{true, false} {
+		t.Run(fmt.Sprintf("show leaderboard %v", show), func(t *testing.T) {
+			s := newTestGameService(t)
+			ctx := context.Background()
+			user, game, players := startTestGameWith(t, s, 2, StartGameRequest{ShowLeaderboard: show}, "Ada", "Grace", "Linus")
+			question := game.Quiz.Questions[0]
+
+			// Grace is right, Ada wrong and Linus doesn't answer
+			for i, option := range []models.Option{question.Options[1], question.Options[0]} {
+				if err := s.SubmitAnswer(ctx, game.Pin, players[i].ID, &SubmitAnswerRequest{
+					PlayerID:   players[i].ID,
+					QuestionID: question.ID,
+					OptionID:   option.ID,
+				}, nil); err != nil {
+					t.Fatalf("SubmitAnswer(%s): %v", players[i].Name, err)
+				}
+			}
+			// Ada took a while over the wrong answer
+			s.db.Model(&models.GameAnswer{}).Where("player_id = ?", players[0].ID).Update("time_spent", 5)
+
+			hub := newTestHub()
+			host := connectTestClient(hub, game.Pin, RoleHost, user.ID)
+			if err := s.EndQuestion(ctx, game.Pin, hub, 0); err != nil {
+				t.Fatalf("EndQuestion: %v", err)
+			}
+
+			var update *Message
+			for _, message := range drainMessages(t, host) {
+				if message.Type == "leaderboard_update" {
+					update = &message
+				}
+			}
+			if !show {
+				if update != nil {
+					t.Error("leaderboard_update was sent for a game that hides it")
+				}
+				return
+			}
+			if update == nil {
+				t.Fatal("no leaderboard_update followed the question")
+			}
+			// Ada and Linus both have nothing, and Linus spent no time answering
+			want := "[Grace:1 Linus:2 Ada:3]"
+			if got := leaderboardNames(t, *update); fmt.Sprint(got) != want {
+				t.Errorf("leaderboard = %v, want %s", got, want)
+			}
+
+			top, err := s.GetLeaderboard(ctx, game.Pin, 1)
+			if err != nil {
+				t.Fatalf("GetLeaderboard: %v", err)
+			}
+			if len(top) != 1 || top[0].Name != "Grace" {
+				t.Errorf("GetLeaderboard(1) = %v, want Grace alone", standings(top))
+			}
+		})
+	}
+}
+
+func TestSecondsUntil(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset time.Duration
+		want   int
+	}{
+		{"past", -3 * time.Second, 0},
+		{"part of a second rounds up", 200 * time.Millisecond, 1},
+		{"just under whole seconds", 11*time.Second + 900*time.Millisecond, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := secondsUntil(time.Now().Add(tt.offset)); got != tt.want {
+				t.Errorf("secondsUntil(now%+v) = %d, want %d", tt.offset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshTimeLeftIgnoresStaleCounter(t *testing.T) {
+	startedAt := time.Now().Add(-8 * time.Second)
+	endsAt := startedAt.Add(20 * time.Second)
+
+	// The timer last wrote 15 seconds left, but 8 of 20 have gone by
+	gameState := &GameState{
+		CurrentQuestion:   &GameQuestion{TimeLimit: 20, TimeLeft: 15},
+		QuestionStartedAt: &startedAt,
+		QuestionEndsAt:    &endsAt,
+	}
+	gameState.refreshTimeLeft()
+	if got := gameState.CurrentQuestion.TimeLeft; got < 11 || got > 12 {
+		t.Errorf("time left = %d, want within a second of 12", got)
+	}
+
+	// A state without the start time falls back to the end time
+	gameState.QuestionStartedAt = nil
+	gameState.CurrentQuestion.TimeLeft = 15
+	gameState.refreshTimeLeft()
+	if got := gameState.CurrentQuestion.TimeLeft; got < 11 || got > 12 {
+		t.Errorf("time left from the end time = %d, want within a second of 12", got)
+	}
+
+	// Between questions there is nothing to refresh
+	(&GameState{}).refreshTimeLeft()
+}
+
+func TestAnswerWithAnotherQuestionsOptionIsRejected(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, players := startTestGame(t, s, 2, "Ada")
+	current, other := game.Quiz.Questions[0], game.Quiz.Questions[1]
+
+	// The other question's correct option, submitted against the current one
+	err := s.SubmitAnswer(ctx, game.Pin, players[0].ID, &SubmitAnswerRequest{
+		PlayerID:   players[0].ID,
+		QuestionID: current.ID,
+		OptionID:   other.Options[0].ID,
+	}, nil)
+	if !errors.Is(err, ErrOptionNotInQuestion) {
+		t.Fatalf("SubmitAnswer() = %v, want ErrOptionNotInQuestion", err)
+	}
+
+	var answers int64
+	s.db.Model(&models.GameAnswer{}).Where("player_id = ?", players[0].ID).Count(&answers)
+	if answers != 0 {
+		t.Errorf("%d answers were recorded for the rejected submission", answers)
+	}
+
+	// The player can still answer properly
+	if err := s.SubmitAnswer(ctx, game.Pin, players[0].ID, &SubmitAnswerRequest{
+		PlayerID:   players[0].ID,
+		QuestionID: current.ID,
+		OptionID:   current.Options[0].ID,
+	}, nil); err != nil {
+		t.Errorf("SubmitAnswer() with the question's own option: %v", err)
+	}
+}
+
+func TestAnswerResultGoesOnlyToTheAnsweringPlayer(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, players := startTestGame(t, s, 2, "Ada", "Grace")
+	question := game.Quiz.Questions[0]
+
+	hub := newTestHub()
+	ada := connectTestClient(hub, game.Pin, RolePlayer, players[0].ID)
+	grace := connectTestClient(hub, game.Pin, RolePlayer, players[1].ID)
+
+	if err := s.SubmitAnswer(ctx, game.Pin, players[0].ID, &SubmitAnswerRequest{
+		PlayerID:   players[0].ID,
+		QuestionID: question.ID,
+		OptionID:   question.Options[0].ID,
+	}, hub); err != nil {
+		t.Fatalf("SubmitAnswer: %v", err)
+	}
+
+	received := waitForMessage(t, ada, "answer_received")
+	if payload, _ := received.Payload.(map[string]interface{}); payload["option_id"] != float64(question.Options[0].ID) || payload["is_correct"] != nil {
+		t.Errorf("answer_received = %v, want the option back without its correctness", received.Payload)
+	}
+	for _, message := range drainMessages(t, grace) {
+		if message.Type == "answer_received" || message.Type == "answer_result" {
+			t.Errorf("another player was sent %s", message.Type)
+		}
+		if payload, _ := message.Payload.(map[string]interface{}); message.Type == "answer_submitted" && payload["is_correct"] != nil {
+			t.Errorf("answer_submitted revealed correctness to everyone: %v", payload)
+		}
+	}
+
+	if err := s.EndQuestion(ctx, game.Pin, hub, 0); err != nil {
+		t.Fatalf("EndQuestion: %v", err)
+	}
+	result := waitForMessage(t, ada, "answer_result")
+	if payload, _ := result.Payload.(map[string]interface{}); payload["is_correct"] != true {
+		t.Errorf("answer_result = %v, want it marked correct", result.Payload)
+	}
+	for _, message := range drainMessages(t, grace) {
+		if message.Type == "answer_result" {
+			t.Error("a player who didn't answer was sent an answer_result")
+		}
+	}
+}
+
+func TestNextQuestionWaitsOutTheReveal(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user, game, _ := startTestGame(t, s, 3, "Ada")
+
+	hub := newTestHub()
+	host := connectTestClient(hub, game.Pin, RoleHost, user.ID)
+	if err := s.EndQuestion(ctx, game.Pin, hub, 0); err != nil {
+		t.Fatalf("EndQuestion: %v", err)
+	}
+	countdown := waitForMessage(t, host, "reveal_countdown")
+	if payload, _ := countdown.Payload.(map[string]interface{}); payload["seconds_left"] != float64(defaultRevealSeconds) {
+		t.Errorf("first reveal_countdown = %v, want %d seconds left", countdown.Payload, defaultRevealSeconds)
+	}
+
+	if err := s.NextQuestion(ctx, game.Pin, nil); !errors.Is(err, ErrRevealInProgress) {
+		t.Fatalf("NextQuestion() during the reveal = %v, want ErrRevealInProgress", err)
+	}
+
+	// Once the reveal is over the host can move on
+	gameState := s.getGameState(ctx, game.Pin)
+	revealEnded := time.Now().Add(-time.Millisecond)
+	gameState.RevealEndsAt = &revealEnded
+	if err := s.storeGameState(ctx, game.Pin, gameState); err != nil {
+		t.Fatalf("storeGameState: %v", err)
+	}
+	if err := s.NextQuestion(ctx, game.Pin, nil); err != nil {
+		t.Fatalf("NextQuestion() after the reveal: %v", err)
+	}
+	if gameState := s.getGameState(ctx, game.Pin); gameState.CurrentQuestionIndex != 1 || gameState.RevealEndsAt != nil {
+		t.Errorf("state after advancing = %+v, want question 1 with no reveal pending", gameState)
+	}
+}
+
+func TestNoRevealDelayLetsTheHostMoveOnAtOnce(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	quizReq := testQuizRequest(2)
+	quizReq.RevealSeconds = intPtr(0)
+	_, game, _ := startTestQuizGame(t, s, quizReq, StartGameRequest{}, "Ada")
+
+	if err := s.EndQuestion(ctx, game.Pin, nil, 0); err != nil {
+		t.Fatalf("EndQuestion: %v", err)
+	}
+	if err := s.NextQuestion(ctx, game.Pin, nil); err != nil {
+		t.Errorf("NextQuestion() without a reveal delay: %v", err)
+	}
+}
+
+func TestGetPlayerAnswersMarksRightAndWrong(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user, game, players := startTestGame(t, s, 2, "Ada")
+	ada := players[0]
+
+	// Ada gets the first question right and the second wrong
+	for i, pick := range []int{0, 1} {
+		if i > 0 {
+			if err := s.StartQuestion(ctx, game.Pin, i, nil); err != nil {
+				t.Fatalf("StartQuestion(%d): %v", i, err)
+			}
+		}
+		question := game.Quiz.Questions[i]
+		if err := s.SubmitAnswer(ctx, game.Pin, ada.ID, &SubmitAnswerRequest{
+			PlayerID:   ada.ID,
+			QuestionID: question.ID,
+			OptionID:   question.Options[pick].ID,
+		}, nil); err != nil {
+			t.Fatalf("SubmitAnswer(%d): %v", i, err)
+		}
+		if err := s.EndQuestion(ctx, game.Pin, nil, i); err != nil {
+			t.Fatalf("EndQuestion(%d): %v", i, err)
+		}
+	}
+
+	review, err := s.GetPlayerAnswers(ctx, game.Pin, ada.ID, user.ID)
+	if err != nil {
+		t.Fatalf("GetPlayerAnswers: %v", err)
+	}
+	if review.PlayerName != "Ada" || len(review.Answers) != 2 {
+		t.Fatalf("review = %+v, want Ada with 2 answers", review)
+	}
+
+	right, wrong := review.Answers[0], review.Answers[1]
+	if !right.IsCorrect || right.Points <= 0 || right.OptionText != "Right" {
+		t.Errorf("first answer = %+v, want a scored correct answer", right)
+	}
+	if wrong.IsCorrect || wrong.Points != 0 || wrong.OptionText != "Wrong" {
+		t.Errorf("second answer = %+v, want an unscored wrong answer", wrong)
+	}
+	for i, answer := range review.Answers {
+		if answer.QuestionText != game.Quiz.Questions[i].Text || answer.CorrectOptionText != "Right" {
+			t.Errorf("answer %d = %+v, want question %q with correct option %q", i, answer, game.Quiz.Questions[i].Text, "Right")
+		}
+	}
+	if review.Score != right.Points {
+		t.Errorf("score = %d, want %d", review.Score, right.Points)
+	}
+
+	if _, err := s.GetPlayerAnswers(ctx, game.Pin, ada.ID, user.ID+1); !errors.Is(err, ErrNotGameOwner) {
+		t.Errorf("GetPlayerAnswers() by another user = %v, want ErrNotGameOwner", err)
+	}
+	if _, err := s.GetPlayerAnswers(ctx, game.Pin, ada.ID+1000, user.ID); !errors.Is(err, ErrPlayerNotInGame) {
+		t.Errorf("GetPlayerAnswers() for a stranger = %v, want ErrPlayerNotInGame", err)
+	}
+}
+
+func TestSameDeviceJoiningTwiceGetsItsPlayerBack(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(1))
+
+	game, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID, OneJoinPerDevice: true})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	if _, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: "Ada"}); !errors.Is(err, ErrDeviceIDRequired) {
+		t.Fatalf("JoinGame() without a device = %v, want ErrDeviceIDRequired", err)
+	}
+
+	first, rejoined, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: "Ada", DeviceID: "device-1"})
+	if err != nil {
+		t.Fatalf("JoinGame: %v", err)
+	}
+	if rejoined {
+		t.Error("first join reported as a rejoin")
+	}
+
+	// A second name from the same device still gets Ada back
+	second, rejoined, err := s.JoinGame(ctx, &JoinGameRequest{Pin: game.Pin, Name: "Ada again", DeviceID: "device-1"})
+	if err != nil {
+		t.Fatalf("second JoinGame: %v", err)
+	}
+	if !rejoined || second.ID != first.ID || second.Name != "Ada" {
+		t.Errorf("second join = %+v (rejoined %v), want player %d back", second, rejoined, first.ID)
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Player{}).Where("game_id = ?", game.ID).Count(&count).Error; err != nil {
+		t.Fatalf("count players: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("players in game = %d, want 1", count)
+	}
+}
+
+func TestIsJoinable(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(1))
+
+	waiting, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	finished, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	if _, err := s.StartQuiz(ctx, finished.Pin, user.ID, nil); err != nil {
+		t.Fatalf("StartQuiz: %v", err)
+	}
+	if err := s.EndGame(ctx, finished.Pin, user.ID, nil); err != nil {
+		t.Fatalf("EndGame: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		pin  string
+		want JoinableStatus
+	}{
+		{"unknown pin", "zzzzzz", JoinableStatus{}},
+		{"waiting game", strings.ToUpper(waiting.Pin), JoinableStatus{Exists: true, Joinable: true, Status: "waiting"}},
+		{"finished game", finished.Pin, JoinableStatus{Exists: true, Status: "finished"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.IsJoinable(ctx, tt.pin)
+			if err != nil {
+				t.Fatalf("IsJoinable: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("IsJoinable(%q) = %+v, want %+v", tt.pin, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLiveLeaderboardTieBreakIsStable(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	_, game, players := startTestGame(t, s, 1, "Ada", "Grace", "Linus")
+
+	// Ada and Grace are level on points; Grace answered faster
+	for player, standing := range map[*models.Player][2]int{
+		players[0]: {200, 9},
+		players[1]: {200, 4},
+		players[2]: {100, 1},
+	} {
+		setStanding(t, s, game, player, standing[0], standing[1])
+	}
+	s.refreshLeaderboard(ctx, game.Pin, game.ID)
+	want := "Grace:1 Ada:2 Linus:3"
+
+	// The leaderboard lives under its own key, not in the shared game state
+	raw, err := s.redis.Get(ctx, s.gameKey(game.Pin)).Result()
+	if err != nil {
+		t.Fatalf("reading game state: %v", err)
+	}
+	if strings.Contains(raw, `"leaderboard"`) {
+		t.Errorf("game state still carries the leaderboard: %s", raw)
+	}
+
+	for i := 0; i < 5; i++ {
+		state, err := s.GetCurrentGameState(ctx, game.Pin)
+		if err != nil {
+			t.Fatalf("GetCurrentGameState: %v", err)
+		}
+		if got := strings.Join(standings(state.Leaderboard), " "); got != want {
+			t.Fatalf("fetch %d: leaderboard = %s, want %s", i, got, want)
+		}
+	}
+
+	// The final leaderboard ranks the tie the same way
+	if got := strings.Join(standings(s.rankedLeaderboard(ctx, game.ID)), " "); got != want {
+		t.Errorf("final leaderboard = %s, want %s", got, want)
+	}
+}
+
+func TestGameResultsRankLikeLeaderboard(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user, game, players := startTestGame(t, s, 1, "Ada", "Grace", "Linus", "Ken")
+
+	// Ada and Grace are level on points, Grace faster; Linus and Ken are level on both
+	for player, standing := range map[*models.Player][2]int{
+		players[0]: {200, 9},
+		players[1]: {200, 4},
+		players[2]: {100, 1},
+		players[3]: {100, 1},
+	} {
+		setStanding(t, s, game, player, standing[0], standing[1])
+	}
+
+	results, err := s.GetGameResults(ctx, game.Pin, user.ID)
+	if err != nil {
+		t.Fatalf("GetGameResults: %v", err)
+	}
+	got := make([]string, len(results.Players))
+	for i, player := range results.Players {
+		got[i] = fmt.Sprintf("%s:%d", player.Name, player.Rank)
+	}
+	want := strings.Join(standings(s.rankedLeaderboard(ctx, game.ID)), " ")
+	if strings.Join(got, " ") != want {
+		t.Errorf("results = %s, want %s", strings.Join(got, " "), want)
+	}
+	if want != "Grace:1 Ada:2 Linus:3 Ken:3" {
+		t.Errorf("leaderboard = %s, want Grace:1 Ada:2 Linus:3 Ken:3", want)
+	}
+}
+
+func TestFastestCorrectAnswer(t *testing.T) {
+	at := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		answers []models.GameAnswer
+		want    uint // answer ID, 0 for none
+	}{
+		{
+			name: "least time among correct answers",
+			answers: []models.GameAnswer{
+				{ID: 1, IsCorrect: true, TimeSpent: 8, CreatedAt: at},
+				{ID: 2, IsCorrect: false, TimeSpent: 1, CreatedAt: at},
+				{ID: 3, IsCorrect: true, TimeSpent: 3, CreatedAt: at},
+				{ID: 4, IsCorrect: true, TimeSpent: 5, CreatedAt: at},
+			},
+			want: 3,
+		},
+		{
+			name: "equal times go to the earlier submission",
+			answers: []models.GameAnswer{
+				{ID: 1, IsCorrect: true, TimeSpent: 4, CreatedAt: at.Add(time.Second)},
+				{ID: 2, IsCorrect: true, TimeSpent: 4, CreatedAt: at},
+			},
+			want: 2,
+		},
+		{
+			name: "nobody correct",
+			answers: []models.GameAnswer{
+				{ID: 1, IsCorrect: false, TimeSpent: 2, CreatedAt: at},
+			},
+		},
+		{name: "no answers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got uint
+			if fastest := fastestCorrectAnswer(tt.answers); fastest != nil {
+				got = fastest.ID
+			}
+			if got != tt.want {
+				t.Errorf("fastestCorrectAnswer() = answer %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndQuestionNamesAndRewardsTheFastestCorrectAnswer(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	quizReq := testQuizRequest(1)
+	quizReq.FastestBonus = intPtr(50)
+	user, game, players := startTestQuizGame(t, s, quizReq, StartGameRequest{}, "Ada", "Grace", "Linus", "Ken")
+	question := game.Quiz.Questions[0]
+
+	// Grace is the fastest of the right answers; Ken was quicker but wrong
+	times := []int{8, 3, 5, 1}
+	for i, player := range players {
+		option := question.Options[0]
+		if player.Name == "Ken" {
+			option = question.Options[1]
+		}
+		if err := s.SubmitAnswer(ctx, game.Pin, player.ID, &SubmitAnswerRequest{
+			PlayerID:   player.ID,
+			QuestionID: question.ID,
+			OptionID:   option.ID,
+		}, nil); err != nil {
+			t.Fatalf("SubmitAnswer(%s): %v", player.Name, err)
+		}
+		if err := s.db.Model(&models.GameAnswer{}).Where("player_id = ?", player.ID).Update("time_spent", times[i]).Error; err != nil {
+			t.Fatalf("set answer time: %v", err)
+		}
+	}
+
+	hub := newTestHub()
+	host := connectTestClient(hub, game.Pin, RoleHost, user.ID)
+	if err := s.EndQuestion(ctx, game.Pin, hub, 0); err != nil {
+		t.Fatalf("EndQuestion: %v", err)
+	}
+
+	payload, _ := waitForMessage(t, host, "question_end").Payload.(map[string]interface{})
+	fastest, _ := payload["fastest_answer"].(map[string]interface{})
+	if fastest["player_name"] != "Grace" || fastest["time_spent"] != float64(3) || fastest["bonus_points"] != float64(50) {
+		t.Errorf("fastest_answer = %v, want Grace in 3s with 50 bonus points", payload["fastest_answer"])
+	}
+
+	scoring := quizScoring(&game.Quiz)
+	for i, player := range players[:3] {
+		want := s.calculatePoints(scoring, times[i], question.TimeLimit, true)
+		if player.Name == "Grace" {
+			want += 50
+		}
+		var answer models.GameAnswer
+		if err := s.db.Where("player_id = ?", player.ID).First(&answer).Error; err != nil {
+			t.Fatalf("load answer: %v", err)
+		}
+		if answer.Points != want {
+			t.Errorf("%s earned %d points, want %d", player.Name, answer.Points, want)
+		}
+	}
+}
+
+func TestActiveGamesLeaveOutFinishedAndOtherUsersGames(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user, running, _ := startTestGame(t, s, 2, "Ada", "Grace")
+
+	waiting, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: running.QuizID})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	finished, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: running.QuizID})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	if _, err := s.StartQuiz(ctx, finished.Pin, user.ID, nil); err != nil {
+		t.Fatalf("StartQuiz: %v", err)
+	}
+	if err := s.EndGame(ctx, finished.Pin, user.ID, nil); err != nil {
+		t.Fatalf("EndGame: %v", err)
+	}
+	startTestGame(t, s, 1, "Linus") // another host's game
+
+	games, err := s.GetActiveGamesForUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetActiveGamesForUser: %v", err)
+	}
+	got := make([]string, len(games))
+	for i, game := range games {
+		got[i] = fmt.Sprintf("%s %s players=%d question=%d", game.Pin, game.Status, game.PlayerCount, game.CurrentQuestionIndex)
+	}
+	want := []string{
+		fmt.Sprintf("%s waiting players=0 question=-1", DisplayPin(waiting.Pin)),
+		fmt.Sprintf("%s active players=2 question=0", DisplayPin(running.Pin)),
+	}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("active games = %v, want %v", got, want)
+	}
+}
+
+func TestCancelledContextAbortsGameStateWrites(t *testing.T) {
+	s := newTestGameService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.storeGameState(ctx, "abc123", &GameState{Pin: "abc123", Status: "waiting"}); err == nil {
+		t.Fatal("storeGameState() with a cancelled context succeeded")
+	}
+	if n := s.redis.Exists(context.Background(), s.gameKey("abc123")).Val(); n != 0 {
+		t.Error("the state was written despite the cancelled context")
+	}
+	if s.getGameState(ctx, "abc123") != nil {
+		t.Error("getGameState() with a cancelled context returned a state")
+	}
+}
+
+func TestQuestionCountdownPrecedesQuestionStart(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	quizReq := testQuizRequest(2)
+	quizReq.QuestionCountdown = intPtr(2)
+	user, game, _ := startTestQuizGame(t, s, quizReq, StartGameRequest{}, "Ada")
+
+	hub := newTestHub()
+	host := connectTestClient(hub, game.Pin, RoleHost, user.ID)
+	if err := s.StartQuestion(ctx, game.Pin, 1, hub); err != nil {
+		t.Fatalf("StartQuestion: %v", err)
+	}
+
+	// Nothing of the question is shown while counting down
+	if gameState := s.getGameState(ctx, game.Pin); gameState.CurrentQuestion != nil || gameState.QuestionStartsAt == nil {
+		t.Errorf("state during the countdown = %+v, want no current question and a start time", gameState)
+	}
+
+	var events []string
+	timeout := time.After(5 * time.Second)
+	for len(events) == 0 || events[len(events)-1] != "question_start" {
+		select {
+		case data := <-host.send:
+			var message Message
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("decode message %s: %v", data, err)
+			}
+			payload, _ := message.Payload.(map[string]interface{})
+			switch message.Type {
+			case "question_countdown":
+				events = append(events, fmt.Sprintf("countdown %v", payload["seconds_left"]))
+			case "question_start":
+				events = append(events, message.Type)
+			}
+		case <-timeout:
+			t.Fatalf("no question_start after the countdown; got %v", events)
+		}
+	}
+
+	want := "countdown 2, countdown 1, question_start"
+	if got := strings.Join(events, ", "); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+}
+
+func TestJoinGameAcceptsThePinInAnyCase(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user := createTestUser(t, s.db)
+	quiz := createTestQuiz(t, s.db, user.ID, testQuizRequest(1))
+
+	game, err := s.StartGame(ctx, user.ID, &StartGameRequest{QuizID: quiz.ID})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	if game.Pin != strings.ToLower(game.Pin) {
+		t.Fatalf("stored pin %q, want it lowercase", game.Pin)
+	}
+
+	for i, pin := range []string{game.Pin, DisplayPin(game.Pin)} {
+		player, _, err := s.JoinGame(ctx, &JoinGameRequest{Pin: pin, Name: fmt.Sprintf("Player %d", i+1)})
+		if err != nil {
+			t.Fatalf("JoinGame(%q): %v", pin, err)
+		}
+		if player.GameID != game.ID {
+			t.Errorf("JoinGame(%q) joined game %d, want %d", pin, player.GameID, game.ID)
+		}
+	}
+}
+
+func TestAnswerProgressCountsUpAsPlayersAnswer(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user, game, players := startTestGame(t, s, 1, "Ada", "Grace", "Linus")
+	question := game.Quiz.Questions[0]
+
+	hub := newTestHub()
+	host := connectTestClient(hub, game.Pin, RoleHost, user.ID)
+	for _, player := range players {
+		connectTestClient(hub, game.Pin, RolePlayer, player.ID)
+	}
+
+	for i, player := range players {
+		if err := s.SubmitAnswer(ctx, game.Pin, player.ID, &SubmitAnswerRequest{
+			PlayerID:   player.ID,
+			QuestionID: question.ID,
+			OptionID:   question.Options[i%2].ID,
+		}, hub); err != nil {
+			t.Fatalf("SubmitAnswer(%s): %v", player.Name, err)
+		}
+
+		payload, _ := waitForMessage(t, host, "answer_progress").Payload.(map[string]interface{})
+		if payload["answered"] != float64(i+1) || payload["expected"] != float64(len(players)) {
+			t.Errorf("after %d answers: progress = %v, want %d of %d", i+1, payload, i+1, len(players))
+		}
+		for key := range payload {
+			if strings.Contains(key, "option") {
+				t.Errorf("progress reveals %s", key)
+			}
+		}
+	}
+}
@@ -1,24 +1,50 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
+	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"openquiz/metrics"
 	"openquiz/models"
 
 	"github.com/gorilla/websocket"
 )
 
+// Client roles within a game, used to target broadcasts
+const (
+	RoleHost      = "host"
+	RolePlayer    = "player"
+	RoleSpectator = "spectator"
+)
+
 type Hub struct {
 	clients     map[*Client]bool
+	games       map[string]map[string]map[*Client]bool // pin -> role -> clients; guarded by mutex
 	broadcast   chan []byte
 	register    chan *Client
 	unregister  chan *Client
+	quit        chan struct{}
+	closing     bool
 	mutex       sync.RWMutex
 	gameService *GameService // Add reference to game service
+	logger      *slog.Logger
+
+	// Inbound messages allowed per client per second (0 disables)
+	messageRateLimit int
+	// Largest inbound message in bytes (0 disables)
+	maxMessageSize int64
+
+	// How long a disconnected creator has to come back before their game is
+	// ended (0 ends it at once), and the pending timers by pin; guarded by mutex
+	hostGracePeriod time.Duration
+	hostGraceTimers map[string]*time.Timer
 }
 
 type Client struct {
@@ -29,20 +55,45 @@ type Client struct {
 	gamePin    string
 	playerID   uint
 	playerName string
+	role       string
+	closed     bool // send has been closed; guarded by hub.mutex
+
+	// Token bucket for inbound message rate limiting, only touched by readPump
+	tokens     float64
+	lastRefill time.Time
+	throttled  int
 }
 
+// isHost reports whether the client is the game's creator. The role is set
+// from how the connection was authorized, never inferred from the ID.
+func (c *Client) isHost() bool {
+	return c.role == RoleHost
+}
+
+// maxThrottledMessages is how many messages a client may have dropped by the
+// rate limiter before it is disconnected
+const maxThrottledMessages = 50
+
 type Message struct {
 	Type    string      `json:"type"`
 	Payload interface{} `json:"payload"`
+	Seq     int64       `json:"seq,omitempty"` // position in the game's event log, for deduplicating replays
 }
 
-func NewHub(gameService *GameService) *Hub {
+func NewHub(gameService *GameService, logger *slog.Logger, messageRateLimit int, maxMessageSize int64, hostGracePeriod time.Duration) *Hub {
 	return &Hub{
-		clients:     make(map[*Client]bool),
-		broadcast:   make(chan []byte),
-		register:    make(chan *Client),
-		unregister:  make(chan *Client),
-		gameService: gameService,
+		clients:          make(map[*Client]bool),
+		games:            make(map[string]map[string]map[*Client]bool),
+		broadcast:        make(chan []byte),
+		register:         make(chan *Client),
+		unregister:       make(chan *Client),
+		quit:             make(chan struct{}),
+		gameService:      gameService,
+		logger:           logger,
+		messageRateLimit: messageRateLimit,
+		maxMessageSize:   maxMessageSize,
+		hostGracePeriod:  hostGracePeriod,
+		hostGraceTimers:  make(map[string]*time.Timer),
 	}
 }
 
@@ -51,128 +102,468 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.register:
 			h.mutex.Lock()
-			h.clients[client] = true
+			wasOnline := h.playerConnectedLocked(client.gamePin, client.playerID)
+			h.addClient(client)
 			h.mutex.Unlock()
-			log.Printf("Client registered: %s for game %s (player %d: %s) - Total clients: %d", client.id, client.gamePin, client.playerID, client.playerName, len(h.clients))
+			h.logger.Debug("Client registered", "client_id", client.id, "game_pin", client.gamePin, "player_id", client.playerID, "player_name", client.playerName, "role", client.role)
+
+			// A creator coming back within the grace period keeps the game going
+			if client.isHost() && h.cancelHostGrace(client.gamePin) {
+				h.logger.Info("Creator reconnected", "game_pin", client.gamePin)
+				h.BroadcastToGame(client.gamePin, "host_reconnected", nil)
+			}
+
+			// The roster shows a player online from their first open connection
+			if client.role == RolePlayer && !wasOnline {
+				h.broadcastConnectionUpdate(client, "connected")
+			}
 
 		case client := <-h.unregister:
 			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				log.Printf("Client unregistered: %s for game %s (player %d: %s) - Total clients: %d", client.id, client.gamePin, client.playerID, client.playerName, len(h.clients))
-
-				// Check if creator disconnected and update game status
-				if client.playerID == 0 {
-					log.Printf("Creator disconnected from game %s", client.gamePin)
-					// Update game status to finished if creator left
-					if h.gameService != nil {
-						if err := h.gameService.UpdateGameStatus(client.gamePin, "finished"); err != nil {
-							log.Printf("Error updating game status after creator disconnect: %v", err)
-						} else {
-							// Broadcast game end to remaining players
-							h.BroadcastToGame(client.gamePin, "game_end", map[string]interface{}{
-								"message": "Quiz creator has left the game. The quiz has ended.",
-								"reason":  "creator_disconnected",
-							})
-						}
-					}
-				}
+			_, ok := h.clients[client]
+			if ok {
+				h.removeClient(client)
+				h.logger.Debug("Client unregistered", "client_id", client.id, "game_pin", client.gamePin, "player_id", client.playerID, "player_name", client.playerName, "role", client.role)
 			}
+			closing := h.closing
+			stillOnline := h.playerConnectedLocked(client.gamePin, client.playerID)
 			h.mutex.Unlock()
 
+			// and offline once their last connection drops
+			if ok && client.role == RolePlayer && !stillOnline && !closing {
+				h.broadcastConnectionUpdate(client, "disconnected")
+			}
+
+			// Check if creator disconnected and update game status
+			// (a server shutdown is not the creator leaving)
+			if ok && client.isHost() && !closing {
+				h.logger.Info("Creator disconnected", "game_pin", client.gamePin, "grace_period", h.hostGracePeriod.String())
+				if h.hostGracePeriod <= 0 {
+					h.endGameForDepartedCreator(client.gamePin)
+				} else {
+					h.startHostGrace(client.gamePin)
+				}
+			}
+
 		case message := <-h.broadcast:
-			h.mutex.RLock()
+			h.mutex.Lock()
 			for client := range h.clients {
 				select {
 				case client.send <- message:
 				default:
-					close(client.send)
-					delete(h.clients, client)
+					h.removeClient(client)
 				}
 			}
+			h.mutex.Unlock()
+
+		case <-h.quit:
+			h.logger.Info("Hub stopped")
+			return
+		}
+	}
+}
+
+// Shutdown stops accepting new clients, sends a close frame to every connected
+// client and waits for them to disconnect. Clients still connected when ctx
+// expires are closed forcibly. The Run loop exits once Shutdown returns.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mutex.Lock()
+	if h.closing {
+		h.mutex.Unlock()
+		return errors.New("hub already shut down")
+	}
+	h.closing = true
+	for pin, timer := range h.hostGraceTimers {
+		timer.Stop()
+		delete(h.hostGraceTimers, pin)
+	}
+
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	deadline := time.Now().Add(time.Second)
+	for client := range h.clients {
+		if err := client.socket.WriteControl(websocket.CloseMessage, closeMessage, deadline); err != nil {
+			h.logger.Warn("Error sending close frame", "client_id", client.id, "error", err)
+		}
+	}
+	h.logger.Info("Hub shutting down, waiting for clients to disconnect", "clients", len(h.clients))
+	h.mutex.Unlock()
+
+	defer close(h.quit)
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		h.mutex.RLock()
+		remaining := len(h.clients)
+		h.mutex.RUnlock()
+
+		if remaining == 0 {
+			h.logger.Info("Hub drained all clients")
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			h.mutex.RLock()
+			h.logger.Warn("Hub shutdown timed out, closing remaining clients", "clients", len(h.clients))
+			for client := range h.clients {
+				client.socket.Close()
+			}
 			h.mutex.RUnlock()
+			return ctx.Err()
 		}
 	}
 }
 
+// startHostGrace gives a disconnected creator the grace period to reconnect
+// before their game is ended, telling the remaining clients how long they may
+// have to wait
+func (h *Hub) startHostGrace(gamePin string) {
+	pin := strings.ToLower(gamePin)
+
+	h.mutex.Lock()
+	if _, pending := h.hostGraceTimers[pin]; pending {
+		h.mutex.Unlock()
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(h.hostGracePeriod, func() {
+		h.mutex.Lock()
+		current := h.hostGraceTimers[pin] == timer
+		if current {
+			delete(h.hostGraceTimers, pin)
+		}
+		closing := h.closing
+		h.mutex.Unlock()
+
+		if !current || closing || h.IsCreatorConnected(pin) {
+			return
+		}
+		h.logger.Info("Creator did not reconnect in time", "game_pin", pin)
+		h.endGameForDepartedCreator(pin)
+	})
+	h.hostGraceTimers[pin] = timer
+	h.mutex.Unlock()
+
+	h.BroadcastToGame(pin, "host_disconnected", map[string]interface{}{
+		"grace_seconds": int(h.hostGracePeriod.Seconds()),
+	})
+}
+
+// cancelHostGrace stops a game's pending creator grace timer and reports
+// whether one was running
+func (h *Hub) cancelHostGrace(gamePin string) bool {
+	pin := strings.ToLower(gamePin)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	timer, pending := h.hostGraceTimers[pin]
+	if !pending {
+		return false
+	}
+	timer.Stop()
+	delete(h.hostGraceTimers, pin)
+	return true
+}
+
+// endGameForDepartedCreator finishes a game whose creator has left and tells
+// the remaining players
+func (h *Hub) endGameForDepartedCreator(gamePin string) {
+	if h.gameService == nil {
+		return
+	}
+	if err := h.gameService.EndGameForDepartedCreator(context.Background(), gamePin, h); err != nil {
+		h.logger.Error("Error ending game after creator disconnect", "game_pin", gamePin, "error", err)
+	}
+}
+
+// addClient adds a client to the hub and its game's role bucket; the caller
+// must hold the mutex
+func (h *Hub) addClient(client *Client) {
+	h.clients[client] = true
+
+	pin := strings.ToLower(client.gamePin)
+	roles, ok := h.games[pin]
+	if !ok {
+		roles = make(map[string]map[*Client]bool)
+		h.games[pin] = roles
+	}
+	if roles[client.role] == nil {
+		roles[client.role] = make(map[*Client]bool)
+	}
+	roles[client.role][client] = true
+
+	metrics.ConnectedClients.Set(float64(len(h.clients)))
+}
+
+// removeClient removes a client from the hub and its role bucket and closes
+// its send channel; the caller must hold the mutex
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	delete(h.clients, client)
+	close(client.send)
+	client.closed = true
+
+	pin := strings.ToLower(client.gamePin)
+	if roles, ok := h.games[pin]; ok {
+		delete(roles[client.role], client)
+		if len(roles[client.role]) == 0 {
+			delete(roles, client.role)
+		}
+		if len(roles) == 0 {
+			delete(h.games, pin)
+		}
+	}
+
+	metrics.ConnectedClients.Set(float64(len(h.clients)))
+}
+
+// gameClients returns a game's connected clients with the given role, or all
+// of them when role is empty; the caller must hold the mutex
+func (h *Hub) gameClients(gamePin string, role string) []*Client {
+	roles := h.games[strings.ToLower(gamePin)]
+
+	var clients []*Client
+	for clientRole, bucket := range roles {
+		if role != "" && clientRole != role {
+			continue
+		}
+		for client := range bucket {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
 func (h *Hub) BroadcastToGame(gamePin string, messageType string, payload interface{}) {
+	h.broadcastToGame(gamePin, "", messageType, payload)
+}
+
+// BroadcastToRole sends a message only to the game's clients with the given
+// role, e.g. answer details for the host alone. Reconnecting clients only have
+// it replayed if they have that role.
+func (h *Hub) BroadcastToRole(gamePin string, role string, messageType string, payload interface{}) {
+	h.broadcastToGame(gamePin, role, messageType, payload)
+}
+
+// broadcastToGame records a message in the game's event log and sends it to
+// the game's clients with the given role, or all of them when role is empty
+func (h *Hub) broadcastToGame(gamePin string, role string, messageType string, payload interface{}) {
 	message := Message{
 		Type:    messageType,
 		Payload: payload,
 	}
 
+	// Keep the event for replay to reconnecting clients; timer updates are
+	// not logged since the current time left is resent on replay anyway
+	if h.gameService != nil && messageType != "timer_update" {
+		if seq, err := h.gameService.RecordEvent(context.Background(), gamePin, messageType, role, payload); err != nil {
+			h.logger.Error("Error recording event", "game_pin", gamePin, "event", messageType, "error", err)
+		} else {
+			message.Seq = seq
+		}
+	}
+
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
+		h.logger.Error("Error marshaling message", "error", err)
 		return
 	}
 
-	log.Printf("Broadcasting %s to game %s", messageType, gamePin)
+	h.logger.Debug("Broadcasting", "game_pin", gamePin, "event", messageType, "role", role)
 
-	h.mutex.RLock()
+	h.mutex.Lock()
+	clientCount := h.deliverLocked(h.gameClients(gamePin, role), data)
+	h.mutex.Unlock()
+
+	h.logger.Debug("Broadcast delivered", "game_pin", gamePin, "event", messageType, "role", role, "recipients", clientCount)
+}
+
+// BroadcastQuestionStart sends question_start to the game's clients. With
+// shuffleOptions each player gets the options in their own order, while the
+// event is logged once with the canonical order for hosts and spectators.
+// A non-nil hostQuestion is sent to hosts instead of question, so they can be
+// shown what players aren't; it is never logged, and reconnecting hosts get it
+// from the game state instead.
+func (h *Hub) BroadcastQuestionStart(gamePin string, questionIndex int, question *GameQuestion, hostQuestion *GameQuestion, totalQuestions int, shuffleOptions bool) {
+	if !shuffleOptions && hostQuestion == nil {
+		h.BroadcastToGame(gamePin, "question_start", questionStartPayload(questionIndex, question, totalQuestions))
+		return
+	}
+
+	var seq int64
+	if h.gameService != nil {
+		var err error
+		if seq, err = h.gameService.RecordEvent(context.Background(), gamePin, "question_start", "", questionStartPayload(questionIndex, question, totalQuestions)); err != nil {
+			h.logger.Error("Error recording event", "game_pin", gamePin, "event", "question_start", "error", err)
+		}
+	}
+
+	h.mutex.Lock()
 	clientCount := 0
-	totalClients := 0
-	for client := range h.clients {
-		totalClients++
-		// Use case-insensitive comparison for game pins
-		if strings.EqualFold(client.gamePin, gamePin) {
-			log.Printf("Found client %s for game %s (player %d: %s)", client.id, gamePin, client.playerID, client.playerName)
-			select {
-			case client.send <- data:
-				clientCount++
-				log.Printf("Successfully sent message to client %s (player %d)", client.id, client.playerID)
-			default:
-				log.Printf("Client %s (player %d) send buffer full, closing connection", client.id, client.playerID)
-				close(client.send)
-				delete(h.clients, client)
-			}
+	for _, client := range h.gameClients(gamePin, "") {
+		sent := clientQuestion(client, question, shuffleOptions)
+		if hostQuestion != nil && client.role == RoleHost {
+			sent = hostQuestion
 		}
+		data, err := json.Marshal(Message{
+			Type:    "question_start",
+			Payload: questionStartPayload(questionIndex, sent, totalQuestions),
+			Seq:     seq,
+		})
+		if err != nil {
+			h.logger.Error("Error marshaling message", "error", err)
+			continue
+		}
+		clientCount += h.deliverLocked([]*Client{client}, data)
 	}
-	h.mutex.RUnlock()
+	h.mutex.Unlock()
+
+	h.logger.Debug("Broadcast delivered", "game_pin", gamePin, "event", "question_start", "recipients", clientCount)
+}
 
-	log.Printf("Message sent to %d clients in game %s (total clients: %d)", clientCount, gamePin, totalClients)
+// questionStartPayload builds a question_start payload; options carry
+// IsCorrect only if the question was built with the answers revealed
+func questionStartPayload(questionIndex int, question *GameQuestion, totalQuestions int) map[string]interface{} {
+	return map[string]interface{}{
+		"question_index": questionIndex,
+		"question": map[string]interface{}{
+			"id":         question.ID,
+			"text":       question.Text,
+			"type":       question.Type,
+			"time_limit": question.TimeLimit,
+			"options":    question.Options,
+		},
+		"total_questions": totalQuestions,
+	}
+}
 
-	// Debug: List all clients if we're not sending to all expected clients
-	if clientCount < 3 { // Assuming we expect 3 clients (host + 2 players)
-		h.ListAllClients()
+// clientQuestion returns the question as a client sees it: players get their
+// own option order when options are shuffled, everyone else the canonical one
+func clientQuestion(client *Client, question *GameQuestion, shuffleOptions bool) *GameQuestion {
+	if !shuffleOptions || question == nil || client.role != RolePlayer {
+		return question
 	}
+
+	shuffled := *question
+	shuffled.Options = playerOptionOrder(client.playerID, question.ID, question.Options)
+	return &shuffled
+}
+
+// stateQuestion is the game state's current question as the client is shown
+// it: with its answers for a host of a game showing them, otherwise as
+// clientQuestion orders it
+func stateQuestion(client *Client, gameState *GameState) *GameQuestion {
+	if client.role == RoleHost && gameState.HostQuestion != nil {
+		return gameState.HostQuestion
+	}
+	return clientQuestion(client, gameState.CurrentQuestion, gameState.ShuffleOptions)
+}
+
+// SendToPlayer sends a message to every connection the player has open in the
+// game, e.g. a second tab, and to no one else. Personal messages aren't logged
+// for replay. It returns how many connections the message was queued for.
+func (h *Hub) SendToPlayer(gamePin string, playerID uint, messageType string, payload interface{}) int {
+	data, err := json.Marshal(Message{Type: messageType, Payload: payload})
+	if err != nil {
+		h.logger.Error("Error marshaling message", "error", err)
+		return 0
+	}
+
+	h.mutex.Lock()
+	var targets []*Client
+	for _, client := range h.gameClients(gamePin, RolePlayer) {
+		if client.playerID == playerID {
+			targets = append(targets, client)
+		}
+	}
+	delivered := h.deliverLocked(targets, data)
+	h.mutex.Unlock()
+
+	h.logger.Debug("Sent to player", "game_pin", gamePin, "event", messageType, "player_id", playerID, "recipients", delivered)
+	return delivered
+}
+
+// SendToClient sends a message to a single connection, such as a reply to a
+// message it sent. It reports whether the message was queued.
+func (h *Hub) SendToClient(client *Client, messageType string, payload interface{}) bool {
+	data, err := json.Marshal(Message{Type: messageType, Payload: payload})
+	if err != nil {
+		h.logger.Error("Error marshaling message", "error", err)
+		return false
+	}
+	return h.sendToClient(client, data)
+}
+
+// deliverLocked queues a message for each client and returns how many took
+// it; clients whose send buffer is full are disconnected. The caller must
+// hold the mutex.
+func (h *Hub) deliverLocked(clients []*Client, data []byte) int {
+	delivered := 0
+	for _, client := range clients {
+		select {
+		case client.send <- data:
+			delivered++
+		default:
+			h.logger.Warn("Client send buffer full, closing connection", "client_id", client.id, "game_pin", client.gamePin, "player_id", client.playerID)
+			h.removeClient(client)
+		}
+	}
+	return delivered
 }
 
 func (h *Hub) BroadcastPlayerUpdate(gamePin string, player models.Player, action string) {
+	h.broadcastPlayerUpdate(gamePin, player, action)
+}
+
+// broadcastConnectionUpdate tells the game a player's first connection opened
+// or their last one closed; the client only knows the player's ID and name
+func (h *Hub) broadcastConnectionUpdate(client *Client, action string) {
+	h.broadcastPlayerUpdate(client.gamePin, GamePlayer{ID: client.playerID, Name: client.playerName}, action)
+}
+
+func (h *Hub) broadcastPlayerUpdate(gamePin string, player interface{}, action string) {
 	message := Message{
 		Type: "player_update",
 		Payload: map[string]interface{}{
-			"action": action, // "joined" or "left"
+			"action": action, // "joined", "left", "connected" or "disconnected"
 			"player": player,
 		},
 	}
 
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling player update message: %v", err)
+		h.logger.Error("Error marshaling player update message", "error", err)
 		return
 	}
 
-	h.mutex.RLock()
-	for client := range h.clients {
-		// Use case-insensitive comparison for game pins
-		if strings.EqualFold(client.gamePin, gamePin) {
-			select {
-			case client.send <- data:
-			default:
-				close(client.send)
-				delete(h.clients, client)
-			}
-		}
+	h.mutex.Lock()
+	h.deliverLocked(h.gameClients(gamePin, ""), data)
+	h.mutex.Unlock()
+}
+
+// DisconnectGame closes every client connected to a game. Messages already
+// queued for a client are delivered before its socket closes.
+func (h *Hub) DisconnectGame(gamePin string) {
+	h.mutex.Lock()
+	for _, client := range h.gameClients(gamePin, "") {
+		h.removeClient(client)
 	}
-	h.mutex.RUnlock()
+	h.mutex.Unlock()
 }
 
-func (h *Hub) SendGameStateSync(client *Client, gameStatus string, currentQuestionIndex int, currentQuestion interface{}) {
+// SendGameStateSync sends the current game state to a client. If the game no
+// longer exists the client is told so and disconnected, and ErrGameNotFound is
+// returned.
+func (h *Hub) SendGameStateSync(client *Client, gameStatus string, currentQuestionIndex int, currentQuestion interface{}) error {
 	// Always try to get the actual game state from the service first
 	if h.gameService != nil {
-		gameState, err := h.gameService.GetCurrentGameState(client.gamePin)
+		gameState, err := h.gameService.GetCurrentGameState(context.Background(), client.gamePin)
 		if err == nil {
 			// Use the actual game state from the service
 			message := Message{
@@ -180,28 +571,28 @@ func (h *Hub) SendGameStateSync(client *Client, gameStatus string, currentQuesti
 				Payload: map[string]interface{}{
 					"game_status":            gameState.Status,
 					"current_question_index": gameState.CurrentQuestionIndex,
-					"current_question":       gameState.CurrentQuestion,
-					"players":                gameState.Players,
+					"current_question":       stateQuestion(client, gameState),
+					"players":                h.roster(client.gamePin, gameState.Players),
+					"leaderboard":            gameState.Leaderboard,
 				},
 			}
 
 			data, err := json.Marshal(message)
 			if err != nil {
-				log.Printf("Error marshaling game state sync message: %v", err)
-				return
+				h.logger.Error("Error marshaling game state sync message", "error", err)
+				return err
 			}
 
-			log.Printf("Sending actual game state sync to client %s: status=%s, question=%d", client.id, gameState.Status, gameState.CurrentQuestionIndex)
+			h.logger.Debug("Sending game state sync", "client_id", client.id, "game_pin", client.gamePin, "status", gameState.Status, "question_index", gameState.CurrentQuestionIndex)
 
-			select {
-			case client.send <- data:
-			default:
-				close(client.send)
-				delete(h.clients, client)
-			}
-			return
+			h.sendToClient(client, data)
+			return nil
+		} else if errors.Is(err, ErrGameNotFound) {
+			h.logger.Info("Game not found, closing connection", "game_pin", client.gamePin, "client_id", client.id)
+			h.sendGameNotFound(client)
+			return err
 		} else {
-			log.Printf("Error getting game state for client %s: %v", client.id, err)
+			h.logger.Error("Error getting game state", "game_pin", client.gamePin, "client_id", client.id, "error", err)
 		}
 	}
 
@@ -217,84 +608,270 @@ func (h *Hub) SendGameStateSync(client *Client, gameStatus string, currentQuesti
 
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling game state sync message: %v", err)
-		return
+		h.logger.Error("Error marshaling game state sync message", "error", err)
+		return err
 	}
 
-	log.Printf("Sending fallback game state sync to client %s: status=%s, question=%d", client.id, gameStatus, currentQuestionIndex)
+	h.logger.Debug("Sending fallback game state sync", "client_id", client.id, "game_pin", client.gamePin, "status", gameStatus, "question_index", currentQuestionIndex)
+
+	h.sendToClient(client, data)
+	return nil
+}
+
+// sendToClient queues a message for a single client. It is a no-op once the
+// client has been unregistered, and drops the message if the client's send
+// buffer is full.
+func (h *Hub) sendToClient(client *Client, data []byte) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if client.closed {
+		return false
+	}
 
 	select {
 	case client.send <- data:
+		return true
 	default:
-		close(client.send)
-		delete(h.clients, client)
+		h.logger.Warn("Client send buffer full, dropping message", "client_id", client.id, "game_pin", client.gamePin, "player_id", client.playerID)
+		return false
+	}
+}
+
+// sendGameNotFound tells a client its game doesn't exist and disconnects it.
+// Unregistering closes the send channel after the message, so the write pump
+// delivers it before sending the close frame.
+func (h *Hub) sendGameNotFound(client *Client) {
+	data, err := json.Marshal(Message{
+		Type: "game_not_found",
+		Payload: map[string]interface{}{
+			"game_pin": DisplayPin(client.gamePin),
+			"message":  "This game does not exist or has expired.",
+		},
+	})
+	if err == nil {
+		h.sendToClient(client, data)
+	}
+
+	h.UnregisterClient(client)
+}
+
+// ReplayMissedEvents resends the logged events a reconnecting client has not
+// seen (those after lastSeq), then the current question with its up-to-date
+// time left. Replayed messages keep their original seq so a client that did
+// not actually miss anything can discard them as duplicates. A negative lastSeq
+// means the client doesn't track sequence numbers, so only the current question
+// is resent.
+func (h *Hub) ReplayMissedEvents(client *Client, lastSeq int64) {
+	if h.gameService == nil {
+		return
+	}
+
+	events, err := h.gameService.GetRecentEvents(context.Background(), client.gamePin)
+	if err != nil {
+		h.logger.Error("Error getting recent events", "game_pin", client.gamePin, "error", err)
+	}
+
+	gameState, err := h.gameService.GetCurrentGameState(context.Background(), client.gamePin)
+	if err != nil {
+		h.logger.Error("Error getting game state for replay", "game_pin", client.gamePin, "client_id", client.id, "error", err)
+	}
+
+	var currentQuestion *GameQuestion
+	currentQuestionIndex := -1
+	if gameState != nil && gameState.Status == "active" && gameState.CurrentQuestion != nil {
+		currentQuestion = gameState.CurrentQuestion
+		currentQuestionIndex = gameState.CurrentQuestionIndex
+	}
+
+	// Find the question_start event for the current question so it is resent
+	// once, fresh, rather than replayed with a stale time limit
+	var currentQuestionSeq int64
+	for _, event := range events {
+		if event.Type != "question_start" {
+			continue
+		}
+		var start struct {
+			QuestionIndex int `json:"question_index"`
+		}
+		if err := json.Unmarshal(event.Payload, &start); err == nil && start.QuestionIndex == currentQuestionIndex {
+			currentQuestionSeq = event.Seq
+		}
 	}
+
+	send := func(message Message) {
+		data, err := json.Marshal(message)
+		if err != nil {
+			h.logger.Error("Error marshaling replay message", "error", err)
+			return
+		}
+		h.sendToClient(client, data)
+	}
+
+	replayed := 0
+	for _, event := range events {
+		if lastSeq < 0 || event.Seq <= lastSeq || (currentQuestion != nil && event.Seq == currentQuestionSeq) {
+			continue
+		}
+		if event.Role != "" && event.Role != client.role {
+			continue // sent to another role only
+		}
+		send(Message{Type: event.Type, Payload: event.Payload, Seq: event.Seq})
+		replayed++
+	}
+
+	if currentQuestion != nil {
+		payload := questionStartPayload(currentQuestionIndex, stateQuestion(client, gameState), gameState.TotalQuestions)
+		payload["time_left"] = currentQuestion.TimeLeft
+		payload["replay"] = true
+		send(Message{Type: "question_start", Payload: payload, Seq: currentQuestionSeq})
+	}
+
+	h.logger.Info("Replayed missed events", "game_pin", client.gamePin, "client_id", client.id, "player_id", client.playerID, "replayed", replayed, "last_seq", lastSeq)
 }
 
+// GetConnectedPlayers returns the IDs of the game's connected players, once
+// each however many connections they have open. Hosts connect with their user
+// ID, which may equal a player's ID, so they are left out along with spectators.
 func (h *Hub) GetConnectedPlayers(gamePin string) []uint {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
 	var playerIDs []uint
-	for client := range h.clients {
-		// Use case-insensitive comparison for game pins
-		if strings.EqualFold(client.gamePin, gamePin) {
-			playerIDs = append(playerIDs, client.playerID)
+	seen := make(map[uint]bool)
+	for _, client := range h.gameClients(gamePin, RolePlayer) {
+		if seen[client.playerID] {
+			continue
 		}
+		seen[client.playerID] = true
+		playerIDs = append(playerIDs, client.playerID)
 	}
 	return playerIDs
 }
 
-// ListAllClients lists all connected clients for debugging
-func (h *Hub) ListAllClients() {
+// HubStats is a snapshot of the hub's connections for monitoring
+type HubStats struct {
+	TotalClients int                        `json:"total_clients"`
+	Games        map[string]GameClientStats `json:"games"` // by game PIN
+}
+
+// GameClientStats counts one game's connections by role
+type GameClientStats struct {
+	Clients    int    `json:"clients"`
+	Hosts      int    `json:"hosts"`
+	Players    int    `json:"players"`
+	Spectators int    `json:"spectators"`
+	PlayerIDs  []uint `json:"player_ids"` // players with at least one connection, ascending
+}
+
+// Stats returns a consistent snapshot of the connected clients, taken under
+// the hub's read lock
+func (h *Hub) Stats() HubStats {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
-	log.Printf("=== Current Hub Status ===")
-	log.Printf("Total clients connected: %d", len(h.clients))
-
-	gameClients := make(map[string][]*Client)
-	for client := range h.clients {
-		gameClients[client.gamePin] = append(gameClients[client.gamePin], client)
+	stats := HubStats{
+		TotalClients: len(h.clients),
+		Games:        make(map[string]GameClientStats, len(h.games)),
 	}
+	for pin, roles := range h.games {
+		game := GameClientStats{
+			Hosts:      len(roles[RoleHost]),
+			Players:    len(roles[RolePlayer]),
+			Spectators: len(roles[RoleSpectator]),
+			PlayerIDs:  []uint{},
+		}
+		game.Clients = game.Hosts + game.Players + game.Spectators
 
-	for gamePin, clients := range gameClients {
-		log.Printf("Game %s: %d clients", gamePin, len(clients))
-		for _, client := range clients {
-			log.Printf("  - Client %s: Player %d (%s)", client.id, client.playerID, client.playerName)
+		seen := make(map[uint]bool)
+		for client := range roles[RolePlayer] {
+			if !seen[client.playerID] {
+				seen[client.playerID] = true
+				game.PlayerIDs = append(game.PlayerIDs, client.playerID)
+			}
 		}
+		sort.Slice(game.PlayerIDs, func(i, j int) bool {
+			return game.PlayerIDs[i] < game.PlayerIDs[j]
+		})
+
+		stats.Games[DisplayPin(pin)] = game
 	}
-	log.Printf("========================")
+	return stats
 }
 
-func (h *Hub) IsPlayerConnected(gamePin string, playerID uint) bool {
+// ListAllClients lists all connected clients for debugging
+func (h *Hub) ListAllClients() {
+	if !h.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
+	h.logger.Debug("Current hub status", "total_clients", len(h.clients))
 	for client := range h.clients {
-		// Use case-insensitive comparison for game pins
-		if strings.EqualFold(client.gamePin, gamePin) && client.playerID == playerID {
-			return true
-		}
+		h.logger.Debug("Connected client", "client_id", client.id, "game_pin", client.gamePin, "player_id", client.playerID, "player_name", client.playerName)
 	}
-	return false
 }
 
-func (h *Hub) IsCreatorConnected(gamePin string) bool {
+func (h *Hub) IsPlayerConnected(gamePin string, playerID uint) bool {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
-	// Check if there's a creator (player ID 0) connected for this game
-	for client := range h.clients {
-		// Use case-insensitive comparison for game pins
-		if strings.EqualFold(client.gamePin, gamePin) && client.playerID == 0 {
+	return h.playerConnectedLocked(gamePin, playerID)
+}
+
+// playerConnectedLocked reports whether the player has a connection open in
+// the game; the caller must hold the mutex
+func (h *Hub) playerConnectedLocked(gamePin string, playerID uint) bool {
+	// Hosts connect with their user ID, which may equal a player's ID
+	for _, client := range h.gameClients(gamePin, RolePlayer) {
+		if client.playerID == playerID {
 			return true
 		}
 	}
 	return false
 }
 
-func (h *Hub) RegisterClient(conn *websocket.Conn, gamePin string, playerID uint, playerName string) *Client {
+// roster marks which of a game's players currently have a connection open
+func (h *Hub) roster(gamePin string, players []GamePlayer) []RosterPlayer {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	roster := make([]RosterPlayer, len(players))
+	for i, player := range players {
+		roster[i] = RosterPlayer{GamePlayer: player, Online: h.playerConnectedLocked(gamePin, player.ID)}
+	}
+	return roster
+}
+
+// IsCreatorConnected reports whether the game's host has a connection open
+func (h *Hub) IsCreatorConnected(gamePin string) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return len(h.gameClients(gamePin, RoleHost)) > 0
+}
+
+func (h *Hub) RegisterClient(conn *websocket.Conn, gamePin string, playerID uint, playerName string, role string) *Client {
+	h.mutex.RLock()
+	closing := h.closing
+	h.mutex.RUnlock()
+
+	if closing {
+		h.logger.Info("Rejecting client: hub is shutting down", "game_pin", gamePin, "player_id", playerID)
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+			time.Now().Add(time.Second))
+		conn.Close()
+		return nil
+	}
+
+	// Oversized frames fail the read, which closes the connection and unregisters the client
+	if h.maxMessageSize > 0 {
+		conn.SetReadLimit(h.maxMessageSize)
+	}
+
 	client := &Client{
 		hub:        h,
 		id:         generateClientID(),
@@ -303,9 +880,17 @@ func (h *Hub) RegisterClient(conn *websocket.Conn, gamePin string, playerID uint
 		gamePin:    gamePin,
 		playerID:   playerID,
 		playerName: playerName,
+		role:       role,
+		tokens:     float64(h.messageRateLimit),
+		lastRefill: time.Now(),
 	}
 
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.quit:
+		conn.Close()
+		return nil
+	}
 
 	go client.writePump()
 	go client.readPump()
@@ -314,7 +899,10 @@ func (h *Hub) RegisterClient(conn *websocket.Conn, gamePin string, playerID uint
 }
 
 func (h *Hub) UnregisterClient(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.quit:
+	}
 }
 
 func (c *Client) readPump() {
@@ -326,16 +914,34 @@ func (c *Client) readPump() {
 	for {
 		_, message, err := c.socket.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket read error: %v", err)
+			if errors.Is(err, websocket.ErrReadLimit) {
+				c.hub.logger.Warn("Client exceeded max message size", "client_id", c.id, "game_pin", c.gamePin, "player_id", c.playerID, "max_bytes", c.hub.maxMessageSize)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				c.hub.logger.Debug("WebSocket read error", "client_id", c.id, "game_pin", c.gamePin, "player_id", c.playerID, "error", err)
 			}
 			break
 		}
 
+		// Drop messages beyond the rate limit and disconnect persistent offenders
+		if !c.allowMessage() {
+			c.throttled++
+			if c.throttled == 1 || c.throttled%10 == 0 {
+				c.hub.logger.Warn("Throttling client", "client_id", c.id, "game_pin", c.gamePin, "player_id", c.playerID, "dropped", c.throttled)
+			}
+			if c.throttled >= maxThrottledMessages {
+				c.hub.logger.Warn("Disconnecting client for exceeding message rate limit", "client_id", c.id, "game_pin", c.gamePin, "player_id", c.playerID)
+				c.socket.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "message rate limit exceeded"),
+					time.Now().Add(time.Second))
+				break
+			}
+			continue
+		}
+
 		// Handle incoming message
 		var msg Message
 		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("Error unmarshaling message: %v", err)
+			c.hub.logger.Warn("Error unmarshaling message", "client_id", c.id, "game_pin", c.gamePin, "player_id", c.playerID, "error", err)
 			continue
 		}
 
@@ -344,6 +950,31 @@ func (c *Client) readPump() {
 	}
 }
 
+// allowMessage takes a token from the client's bucket, refilled continuously at
+// the hub's message rate limit up to one second's worth of messages. Once the
+// bucket is full again the client's throttle count is forgiven.
+func (c *Client) allowMessage() bool {
+	rate := float64(c.hub.messageRateLimit)
+	if rate <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	c.tokens = math.Min(rate, c.tokens+now.Sub(c.lastRefill).Seconds()*rate)
+	c.lastRefill = now
+
+	if c.tokens >= rate {
+		c.throttled = 0
+	}
+
+	if c.tokens < 1 {
+		return false
+	}
+
+	c.tokens--
+	return true
+}
+
 func (c *Client) writePump() {
 	defer func() {
 		c.socket.Close()
@@ -371,7 +1002,26 @@ func (c *Client) writePump() {
 	}
 }
 
+// spectatorMessageTypes are the read-only messages a spectator may send
+var spectatorMessageTypes = map[string]bool{
+	"ping":               true,
+	"join_game":          true,
+	"leave_game":         true,
+	"player_ready":       true,
+	"request_game_state": true,
+}
+
 func (c *Client) handleMessage(msg Message) {
+	// Spectators only watch; anything that would act on the game, such as
+	// submit_answer, is refused
+	if c.role == RoleSpectator && !spectatorMessageTypes[msg.Type] {
+		c.hub.logger.Debug("Rejected spectator message", "type", msg.Type, "game_pin", c.gamePin, "client_id", c.id)
+		c.hub.SendToClient(c, "error", map[string]interface{}{
+			"message": "Spectators can't " + msg.Type,
+		})
+		return
+	}
+
 	switch msg.Type {
 	case "ping":
 		// Respond with pong
@@ -380,30 +1030,30 @@ func (c *Client) handleMessage(msg Message) {
 			Payload: "pong",
 		}
 		data, _ := json.Marshal(response)
-		c.send <- data
+		c.hub.sendToClient(c, data)
 
 	case "join_game":
 		// Handle player joining game
-		log.Printf("Player %d (%s) joined game %s via WebSocket", c.playerID, c.playerName, c.gamePin)
+		c.hub.logger.Info("Player joined via WebSocket", "game_pin", c.gamePin, "player_id", c.playerID, "player_name", c.playerName)
 		// Send game state sync to the joining player
 		c.hub.SendGameStateSync(c, "", 0, nil)
 
 	case "leave_game":
 		// Handle player leaving game
-		log.Printf("Player %d (%s) left game %s via WebSocket", c.playerID, c.playerName, c.gamePin)
+		c.hub.logger.Info("Player left via WebSocket", "game_pin", c.gamePin, "player_id", c.playerID, "player_name", c.playerName)
 
 	case "player_ready":
 		// Player is ready, send current game state
-		log.Printf("Player %d (%s) ready in game %s via WebSocket", c.playerID, c.playerName, c.gamePin)
+		c.hub.logger.Debug("Player ready via WebSocket", "game_pin", c.gamePin, "player_id", c.playerID, "player_name", c.playerName)
 		c.hub.SendGameStateSync(c, "", 0, nil)
 
 	case "request_game_state":
 		// Player is requesting current game state
-		log.Printf("Player %d (%s) requesting game state for game %s via WebSocket", c.playerID, c.playerName, c.gamePin)
+		c.hub.logger.Debug("Player requested game state via WebSocket", "game_pin", c.gamePin, "player_id", c.playerID, "player_name", c.playerName)
 		c.hub.SendGameStateSync(c, "", 0, nil)
 
 	default:
-		log.Printf("Unknown message type: %s from player %d (%s) in game %s", msg.Type, c.playerID, c.playerName, c.gamePin)
+		c.hub.logger.Warn("Unknown message type", "type", msg.Type, "game_pin", c.gamePin, "player_id", c.playerID, "player_name", c.playerName)
 	}
 }
 
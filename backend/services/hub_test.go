@@ -0,0 +1,1000 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"openquiz/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestHub builds a hub without a game service, for tests that only look at
+// which clients are connected
+func newTestHub() *Hub {
+	return NewHub(nil, testLogger, 0, 0, 0)
+}
+
+// connectTestClient registers a client with the hub directly, without a socket
+func connectTestClient(h *Hub, gamePin string, role string, playerID uint) *Client {
+	client := &Client{hub: h, id: role, send: make(chan []byte, 16), gamePin: gamePin, playerID: playerID, role: role}
+	h.mutex.Lock()
+	h.addClient(client)
+	h.mutex.Unlock()
+	return client
+}
+
+func TestGetConnectedPlayersOnlyCountsPlayers(t *testing.T) {
+	h := newTestHub()
+
+	// The host's user ID happens to equal a player's ID in this game
+	connectTestClient(h, "abc123", RoleHost, 4)
+	connectTestClient(h, "abc123", RoleSpectator, 0)
+	connectTestClient(h, "abc123", RolePlayer, 2)
+	connectTestClient(h, "abc123", RolePlayer, 2) // a second tab
+	connectTestClient(h, "def456", RolePlayer, 4) // another game
+
+	got := h.GetConnectedPlayers("ABC123")
+	if len(got) != 1 || got[0] != 2 {
+		t.Errorf("GetConnectedPlayers() = %v, want [2]", got)
+	}
+	if h.IsPlayerConnected("abc123", 4) {
+		t.Error("the host counted as player 4")
+	}
+}
+
+// readMessage takes the next message queued for a client
+func readMessage(t *testing.T, client *Client) Message {
+	t.Helper()
+
+	select {
+	case data := <-client.send:
+		var message Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("decode message %s: %v", data, err)
+		}
+		return message
+	default:
+		t.Fatal("no message was sent")
+		return Message{}
+	}
+}
+
+// waitForMessage takes messages queued for a client until one of the given
+// type arrives, failing if none does within a couple of seconds
+func waitForMessage(t *testing.T, client *Client, messageType string) Message {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case data := <-client.send:
+			var message Message
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("decode message %s: %v", data, err)
+			}
+			if message.Type == messageType {
+				return message
+			}
+		case <-timeout:
+			t.Fatalf("no %s message was sent", messageType)
+			return Message{}
+		}
+	}
+}
+
+func TestGameNotFoundShowsDisplayPin(t *testing.T) {
+	h := newTestHub()
+	client := connectTestClient(h, "abc123", RolePlayer, 2)
+
+	go h.sendGameNotFound(client)
+	if unregistered := <-h.unregister; unregistered != client {
+		t.Fatal("a different client was unregistered")
+	}
+
+	message := readMessage(t, client)
+	payload, _ := message.Payload.(map[string]interface{})
+	if message.Type != "game_not_found" || payload["game_pin"] != "ABC123" {
+		t.Errorf("message = %+v, want game_not_found for ABC123", message)
+	}
+}
+
+func TestStatsKeyGamesByDisplayPin(t *testing.T) {
+	h := newTestHub()
+	connectTestClient(h, "abc123", RoleHost, 1)
+	connectTestClient(h, "abc123", RolePlayer, 2)
+
+	stats := h.Stats()
+	game, ok := stats.Games["ABC123"]
+	if !ok {
+		t.Fatalf("stats.Games = %v, want an ABC123 entry", stats.Games)
+	}
+	if game.Hosts != 1 || game.Players != 1 || game.Clients != 2 {
+		t.Errorf("ABC123 stats = %+v, want 1 host and 1 player", game)
+	}
+}
+
+func TestStatsSnapshotOfRegisteredClients(t *testing.T) {
+	h := newTestHub()
+	go h.Run()
+	defer close(h.quit)
+
+	newClient := func(pin, role string, playerID uint) *Client {
+		return &Client{hub: h, id: fmt.Sprintf("%s-%s-%d", pin, role, playerID), send: make(chan []byte, 16), gamePin: pin, playerID: playerID, role: role}
+	}
+	other := newClient("def456", RolePlayer, 7)
+	clients := []*Client{
+		newClient("abc123", RoleHost, 1),
+		newClient("abc123", RolePlayer, 5),
+		newClient("abc123", RolePlayer, 3),
+		newClient("abc123", RolePlayer, 3), // a second tab
+		newClient("abc123", RoleSpectator, 0),
+		other,
+	}
+
+	// Snapshots taken while clients register must not race with them
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(2)
+		go func(client *Client) {
+			defer wg.Done()
+			h.register <- client
+		}(client)
+		go func() {
+			defer wg.Done()
+			h.Stats()
+		}()
+	}
+	wg.Wait()
+	waitForClients(t, h, len(clients))
+
+	stats := h.Stats()
+	if stats.TotalClients != 6 {
+		t.Errorf("TotalClients = %d, want 6", stats.TotalClients)
+	}
+	want := map[string]GameClientStats{
+		"ABC123": {Clients: 5, Hosts: 1, Players: 3, Spectators: 1, PlayerIDs: []uint{3, 5}},
+		"DEF456": {Clients: 1, Players: 1, PlayerIDs: []uint{7}},
+	}
+	if fmt.Sprint(stats.Games) != fmt.Sprint(want) {
+		t.Errorf("Games = %+v, want %+v", stats.Games, want)
+	}
+
+	h.unregister <- other
+	waitForClients(t, h, len(clients)-1)
+	if _, ok := h.Stats().Games["DEF456"]; ok {
+		t.Error("a game with no connections is still listed")
+	}
+}
+
+func TestStateQuestionShowsAnswersOnlyToHosts(t *testing.T) {
+	correct, wrong := true, false
+	gameState := &GameState{
+		CurrentQuestion: &GameQuestion{ID: 1, Options: []GameOption{{ID: 10}, {ID: 11}}},
+		HostQuestion:    &GameQuestion{ID: 1, Options: []GameOption{{ID: 10, IsCorrect: &correct}, {ID: 11, IsCorrect: &wrong}}},
+	}
+
+	host := &Client{role: RoleHost, playerID: 1}
+	if got := stateQuestion(host, gameState); got != gameState.HostQuestion {
+		t.Error("a reconnecting host didn't get the question with its answers")
+	}
+
+	for _, client := range []*Client{{role: RolePlayer, playerID: 1}, {role: RoleSpectator}} {
+		for _, option := range stateQuestion(client, gameState).Options {
+			if option.IsCorrect != nil {
+				t.Errorf("%s was shown whether option %d is correct", client.role, option.ID)
+			}
+		}
+	}
+
+	// Games that don't show the answers send everyone the same question
+	gameState.HostQuestion = nil
+	if got := stateQuestion(host, gameState); got != gameState.CurrentQuestion {
+		t.Error("host got a different question though the game doesn't show answers")
+	}
+}
+
+func TestQuestionStartShowsAnswersOnlyToTheHost(t *testing.T) {
+	h := newTestHub()
+	host := connectTestClient(h, "abc123", RoleHost, 1)
+	player := connectTestClient(h, "abc123", RolePlayer, 2)
+	spectator := connectTestClient(h, "abc123", RoleSpectator, 0)
+
+	question := models.Question{ID: 1, Options: []models.Option{{ID: 10, IsCorrect: true}, {ID: 11}}}
+	h.BroadcastQuestionStart("abc123", 0, toGameQuestion(question, false), toGameQuestion(question, true), 1, false)
+
+	want := map[*Client]string{
+		host:      "10:true 11:false",
+		player:    "10:- 11:-",
+		spectator: "10:- 11:-",
+	}
+	for _, client := range []*Client{host, player, spectator} {
+		payload, _ := readMessage(t, client).Payload.(map[string]interface{})
+		sent, _ := payload["question"].(map[string]interface{})
+		options, _ := sent["options"].([]interface{})
+
+		var marks []string
+		for _, option := range options {
+			fields, _ := option.(map[string]interface{})
+			mark := "-"
+			if isCorrect, ok := fields["is_correct"]; ok {
+				mark = fmt.Sprint(isCorrect)
+			}
+			marks = append(marks, fmt.Sprintf("%v:%s", fields["id"], mark))
+		}
+		if got := strings.Join(marks, " "); got != want[client] {
+			t.Errorf("%s options = %s, want %s", client.role, got, want[client])
+		}
+	}
+}
+
+// dialTestClient connects a real WebSocket to the hub as the given role and
+// player, returning the client's end of the connection
+func dialTestClient(t *testing.T, h *Hub, gamePin string, role string, playerID uint) *websocket.Conn {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		h.RegisterClient(conn, gamePin, playerID, "Player", role)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial hub: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// waitForClients waits until the hub has exactly n clients registered
+func waitForClients(t *testing.T, h *Hub, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mutex.RLock()
+		count := len(h.clients)
+		h.mutex.RUnlock()
+		if count == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hub has %d clients, want %d", count, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// readUntilClose reads from a connection until it closes, returning the close error
+func readUntilClose(t *testing.T, conn *websocket.Conn) error {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return err
+		}
+	}
+}
+
+func TestShutdownClosesClientsAndStopsHub(t *testing.T) {
+	h := newTestHub()
+	stopped := make(chan struct{})
+	go func() {
+		h.Run()
+		close(stopped)
+	}()
+
+	conns := []*websocket.Conn{
+		dialTestClient(t, h, "abc123", RolePlayer, 1),
+		dialTestClient(t, h, "abc123", RolePlayer, 2),
+	}
+	waitForClients(t, h, 2)
+
+	// Clients close their end once they see the close frame, as browsers do
+	for _, conn := range conns {
+		go readUntilClose(t, conn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after Shutdown")
+	}
+
+	// Nobody new gets in once the hub is closing
+	late := dialTestClient(t, h, "abc123", RolePlayer, 3)
+	var closeErr *websocket.CloseError
+	if err := readUntilClose(t, late); !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("client connecting after shutdown got %v, want a going away close", err)
+	}
+
+	if err := h.Shutdown(ctx); err == nil {
+		t.Error("a second Shutdown succeeded")
+	}
+}
+
+func TestShutdownSendsGoingAway(t *testing.T) {
+	h := newTestHub()
+	go h.Run()
+
+	conn := dialTestClient(t, h, "abc123", RolePlayer, 1)
+	waitForClients(t, h, 1)
+
+	closed := make(chan error, 1)
+	go func() { closed <- readUntilClose(t, conn) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go h.Shutdown(ctx)
+
+	var closeErr *websocket.CloseError
+	if err := <-closed; !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("client got %v, want a going away close", err)
+	}
+}
+
+func TestShutdownGivesUpWhenClientsLinger(t *testing.T) {
+	h := newTestHub()
+	go h.Run()
+
+	// This client never reads, so never answers the close frame
+	dialTestClient(t, h, "abc123", RolePlayer, 1)
+	waitForClients(t, h, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want the context's deadline", err)
+	}
+}
+
+// drainMessages takes every message queued for a client
+func drainMessages(t *testing.T, client *Client) []Message {
+	t.Helper()
+
+	var messages []Message
+	for {
+		select {
+		case data := <-client.send:
+			var message Message
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("decode message %s: %v", data, err)
+			}
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
+}
+
+func TestReplayMissedEventsResendsWhatTheClientMissed(t *testing.T) {
+	client := testRedis(t)
+	s := &GameService{redis: client, logger: testLogger, keyPrefix: testKeyPrefix(t, client), gameStateTTL: time.Hour}
+	ctx := context.Background()
+
+	startedAt := time.Now().Add(-5 * time.Second)
+	if err := s.storeGameState(ctx, "abc123", &GameState{
+		Pin:                  "abc123",
+		Status:               "active",
+		CurrentQuestionIndex: 1,
+		CurrentQuestion:      &GameQuestion{ID: 12, Text: "Second", TimeLimit: 20},
+		QuestionStartedAt:    &startedAt,
+		TotalQuestions:       3,
+	}); err != nil {
+		t.Fatalf("storeGameState: %v", err)
+	}
+
+	h := NewHub(s, testLogger, 0, 0, 0)
+	h.BroadcastToGame("abc123", "question_start", questionStartPayload(0, &GameQuestion{ID: 11, TimeLimit: 20}, 3)) // seq 1
+	h.BroadcastToGame("abc123", "question_end", map[string]interface{}{"question_index": 0})                        // seq 2
+	h.BroadcastToRole("abc123", RoleHost, "answer_details", map[string]interface{}{"question_index": 0})            // seq 3
+	h.BroadcastToGame("abc123", "question_start", questionStartPayload(1, &GameQuestion{ID: 12, TimeLimit: 20}, 3)) // seq 4
+
+	types := func(messages []Message) string {
+		var names []string
+		for _, message := range messages {
+			names = append(names, fmt.Sprintf("%s#%d", message.Type, message.Seq))
+		}
+		return strings.Join(names, " ")
+	}
+
+	tests := []struct {
+		name    string
+		role    string
+		lastSeq int64
+		want    string
+	}{
+		{"player who saw the first question", RolePlayer, 1, "question_end#2 question_start#4"},
+		{"host who saw the first question", RoleHost, 1, "question_end#2 answer_details#3 question_start#4"},
+		{"player who missed nothing", RolePlayer, 4, "question_start#4"},
+		{"client without sequence numbers", RolePlayer, -1, "question_start#4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reconnected := connectTestClient(h, "abc123", tt.role, 7)
+			h.ReplayMissedEvents(reconnected, tt.lastSeq)
+
+			messages := drainMessages(t, reconnected)
+			if got := types(messages); got != tt.want {
+				t.Fatalf("replayed %q, want %q", got, tt.want)
+			}
+
+			// The current question is resent fresh with the time it has left
+			last, _ := messages[len(messages)-1].Payload.(map[string]interface{})
+			if last["replay"] != true || last["time_left"].(float64) < 14 || last["time_left"].(float64) > 15 {
+				t.Errorf("current question = %v, want a replay with about 15 seconds left", last)
+			}
+		})
+	}
+}
+
+func TestAllowMessageRefillsAtTheRateLimit(t *testing.T) {
+	h := NewHub(nil, testLogger, 5, 0, 0)
+	client := &Client{hub: h, tokens: 5, lastRefill: time.Now()}
+
+	for i := 0; i < 5; i++ {
+		if !client.allowMessage() {
+			t.Fatalf("message %d of a burst of 5 was throttled", i+1)
+		}
+	}
+	if client.allowMessage() {
+		t.Error("a sixth message within the second was allowed")
+	}
+
+	// Half a second later half the bucket has refilled
+	client.lastRefill = client.lastRefill.Add(-500 * time.Millisecond)
+	allowed := 0
+	for client.allowMessage() {
+		allowed++
+	}
+	if allowed < 2 || allowed > 3 {
+		t.Errorf("%d messages allowed after half a second, want 2 or 3", allowed)
+	}
+
+	// A full bucket forgives earlier throttling
+	client.throttled = 7
+	client.lastRefill = client.lastRefill.Add(-2 * time.Second)
+	client.allowMessage()
+	if client.throttled != 0 {
+		t.Errorf("throttled = %d after the bucket refilled, want 0", client.throttled)
+	}
+
+	unlimited := &Client{hub: newTestHub()}
+	for i := 0; i < 100; i++ {
+		if !unlimited.allowMessage() {
+			t.Fatal("a message was throttled with rate limiting disabled")
+		}
+	}
+}
+
+func TestFloodingClientIsDisconnected(t *testing.T) {
+	h := NewHub(nil, testLogger, 5, 0, 0)
+	go h.Run()
+
+	conn := dialTestClient(t, h, "abc123", RolePlayer, 1)
+	waitForClients(t, h, 1)
+
+	closed := make(chan error, 1)
+	go func() { closed <- readUntilClose(t, conn) }()
+	for i := 0; i < 5+maxThrottledMessages; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping"}`)); err != nil {
+			break
+		}
+	}
+
+	var closeErr *websocket.CloseError
+	if err := <-closed; !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Errorf("flooding client got %v, want a policy violation close", err)
+	}
+	waitForClients(t, h, 0)
+}
+
+func TestOversizedMessageClosesTheConnection(t *testing.T) {
+	h := NewHub(nil, testLogger, 0, 64, 0)
+	go h.Run()
+
+	conn := dialTestClient(t, h, "abc123", RolePlayer, 1)
+	waitForClients(t, h, 1)
+
+	// A small message is still answered
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping"}`)); err != nil {
+		t.Fatalf("write ping: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for pong: %v", err)
+		}
+		if strings.Contains(string(data), `"pong"`) {
+			break
+		}
+	}
+
+	oversized := `{"type":"ping","payload":"` + strings.Repeat("x", 1024) + `"}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(oversized)); err != nil {
+		t.Fatalf("write oversized message: %v", err)
+	}
+
+	var closeErr *websocket.CloseError
+	if err := readUntilClose(t, conn); !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseMessageTooBig {
+		t.Errorf("oversized message got %v, want a message too big close", err)
+	}
+	waitForClients(t, h, 0)
+}
+
+func TestStateSyncForUnknownGameSaysNotFound(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+
+	if _, err := s.GetCurrentGameState(ctx, "0f0f0f"); !errors.Is(err, ErrGameNotFound) {
+		t.Fatalf("GetCurrentGameState() = %v, want ErrGameNotFound", err)
+	}
+	if n := s.redis.Exists(ctx, s.gameKey("0f0f0f")).Val(); n != 0 {
+		t.Error("a state was stored for a game that doesn't exist")
+	}
+
+	h := NewHub(s, testLogger, 0, 0, 0)
+	client := connectTestClient(h, "0f0f0f", RolePlayer, 1)
+	errs := make(chan error, 1)
+	go func() { errs <- h.SendGameStateSync(client, "", 0, nil) }()
+	if unregistered := <-h.unregister; unregistered != client {
+		t.Fatal("a different client was unregistered")
+	}
+	if err := <-errs; !errors.Is(err, ErrGameNotFound) {
+		t.Errorf("SendGameStateSync() = %v, want ErrGameNotFound", err)
+	}
+	if message := readMessage(t, client); message.Type != "game_not_found" {
+		t.Errorf("client got %s, want game_not_found", message.Type)
+	}
+}
+
+func TestBroadcastToRoleReachesOnlyThatRole(t *testing.T) {
+	h := newTestHub()
+	host := connectTestClient(h, "abc123", RoleHost, 1)
+	players := []*Client{
+		connectTestClient(h, "abc123", RolePlayer, 2),
+		connectTestClient(h, "abc123", RolePlayer, 3),
+	}
+	spectator := connectTestClient(h, "abc123", RoleSpectator, 0)
+	elsewhere := connectTestClient(h, "def456", RolePlayer, 2)
+
+	h.BroadcastToRole("ABC123", RolePlayer, "players_only", nil)
+	for _, player := range players {
+		if message := readMessage(t, player); message.Type != "players_only" {
+			t.Errorf("player %d was sent %q, want players_only", player.playerID, message.Type)
+		}
+	}
+	for name, client := range map[string]*Client{"host": host, "spectator": spectator, "another game's player": elsewhere} {
+		if messages := drainMessages(t, client); len(messages) != 0 {
+			t.Errorf("the %s was sent %v", name, messages)
+		}
+	}
+
+	h.BroadcastToGame("abc123", "everyone", nil)
+	for _, client := range append(players, host, spectator) {
+		if message := readMessage(t, client); message.Type != "everyone" {
+			t.Errorf("%s client was sent %q, want everyone", client.role, message.Type)
+		}
+	}
+	if messages := drainMessages(t, elsewhere); len(messages) != 0 {
+		t.Errorf("another game's player was sent %v", messages)
+	}
+}
+
+func TestRoleBucketsFollowConcurrentConnections(t *testing.T) {
+	h := newTestHub()
+	go h.Run()
+	defer close(h.quit)
+
+	const perRole = 20
+	newClient := func(role string, playerID uint) *Client {
+		return &Client{hub: h, id: fmt.Sprintf("%s-%d", role, playerID), send: make(chan []byte, 256), gamePin: "abc123", playerID: playerID, role: role}
+	}
+	var players, spectators []*Client
+	for i := 1; i <= perRole; i++ {
+		players = append(players, newClient(RolePlayer, uint(i)))
+		spectators = append(spectators, newClient(RoleSpectator, 0))
+	}
+
+	var wg sync.WaitGroup
+	for _, client := range append(append([]*Client{}, players...), spectators...) {
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			h.register <- client
+		}(client)
+	}
+	wg.Wait()
+	waitForClients(t, h, 2*perRole)
+
+	// Players leave while spectators are being broadcast to
+	const broadcasts = 10
+	for i := 0; i < broadcasts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.BroadcastToRole("abc123", RoleSpectator, "spectators_only", nil)
+		}()
+	}
+	for _, player := range players {
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			h.unregister <- client
+		}(player)
+	}
+	wg.Wait()
+	waitForClients(t, h, perRole)
+
+	h.mutex.RLock()
+	buckets := h.games["abc123"]
+	if _, ok := buckets[RolePlayer]; ok {
+		t.Error("the player bucket outlived its last client")
+	}
+	if got := len(buckets[RoleSpectator]); got != perRole {
+		t.Errorf("spectator bucket has %d clients, want %d", got, perRole)
+	}
+	h.mutex.RUnlock()
+
+	for _, spectator := range spectators {
+		received := 0
+		for _, message := range drainMessages(t, spectator) {
+			if message.Type == "spectators_only" {
+				received++
+			}
+		}
+		if received != broadcasts {
+			t.Errorf("spectator got %d of %d broadcasts", received, broadcasts)
+		}
+	}
+	for _, player := range players {
+		for data := range player.send {
+			var message Message
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("decode message %s: %v", data, err)
+			}
+			if message.Type == "spectators_only" {
+				t.Fatalf("player %d was sent a spectators-only broadcast", player.playerID)
+			}
+		}
+	}
+}
+
+func TestSpectatorReceivesBroadcastsButCannotAnswer(t *testing.T) {
+	h := newTestHub()
+	spectator := connectTestClient(h, "abc123", RoleSpectator, 0)
+	connectTestClient(h, "abc123", RolePlayer, 2)
+
+	for _, messageType := range []string{"question_start", "timer_update", "leaderboard"} {
+		h.BroadcastToGame("abc123", messageType, nil)
+		if message := readMessage(t, spectator); message.Type != messageType {
+			t.Errorf("spectator was sent %q, want %s", message.Type, messageType)
+		}
+	}
+
+	spectator.handleMessage(Message{Type: "submit_answer", Payload: map[string]interface{}{"question_id": 1, "option_id": 2}})
+	message := readMessage(t, spectator)
+	if message.Type != "error" {
+		t.Fatalf("spectator's answer got %q, want an error", message.Type)
+	}
+	if payload, ok := message.Payload.(map[string]interface{}); !ok || !strings.Contains(fmt.Sprint(payload["message"]), "submit_answer") {
+		t.Errorf("error payload = %v, want it to name submit_answer", message.Payload)
+	}
+
+	// Read-only requests are still answered
+	spectator.handleMessage(Message{Type: "ping"})
+	if message := readMessage(t, spectator); message.Type != "pong" {
+		t.Errorf("spectator's ping got %q, want pong", message.Type)
+	}
+
+	if players := h.GetConnectedPlayers("abc123"); len(players) != 1 || players[0] != 2 {
+		t.Errorf("GetConnectedPlayers() = %v, want only player 2", players)
+	}
+}
+
+// optionIDs lists the option IDs of a question_start message in the order sent
+func optionIDs(t *testing.T, message Message) []uint {
+	t.Helper()
+
+	data, err := json.Marshal(message.Payload)
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	var payload struct {
+		Question GameQuestion `json:"question"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("decode question_start payload %s: %v", data, err)
+	}
+	return optionIDsOf(payload.Question.Options)
+}
+
+func TestPlayersGetTheirOwnOptionOrder(t *testing.T) {
+	question := &GameQuestion{ID: 42, Text: "Pick one", TimeLimit: 20}
+	for id := uint(1); id <= 6; id++ {
+		question.Options = append(question.Options, GameOption{ID: id, Text: fmt.Sprintf("Option %d", id)})
+	}
+
+	h := newTestHub()
+	host := connectTestClient(h, "abc123", RoleHost, 1)
+	ada := connectTestClient(h, "abc123", RolePlayer, 2)
+	grace := connectTestClient(h, "abc123", RolePlayer, 3)
+	h.BroadcastQuestionStart("abc123", 0, question, nil, 1, true)
+
+	adaOrder := optionIDs(t, readMessage(t, ada))
+	graceOrder := optionIDs(t, readMessage(t, grace))
+	if fmt.Sprint(adaOrder) == fmt.Sprint(graceOrder) {
+		t.Errorf("both players got the options in the order %v", adaOrder)
+	}
+	for name, order := range map[string][]uint{"Ada": adaOrder, "Grace": graceOrder} {
+		sorted := append([]uint(nil), order...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		if fmt.Sprint(sorted) != "[1 2 3 4 5 6]" {
+			t.Errorf("%s got options %v, want the same option set", name, order)
+		}
+	}
+	if got := optionIDs(t, readMessage(t, host)); fmt.Sprint(got) != "[1 2 3 4 5 6]" {
+		t.Errorf("host got options %v, want the authored order", got)
+	}
+
+	// A reconnect shows the player the same order again
+	if again := playerOptionOrder(2, 42, question.Options); fmt.Sprint(optionIDsOf(again)) != fmt.Sprint(adaOrder) {
+		t.Errorf("Ada's order changed from %v to %v", adaOrder, optionIDsOf(again))
+	}
+
+	// Without shuffling everyone gets the authored order
+	h.BroadcastQuestionStart("abc123", 0, question, nil, 1, false)
+	if got := optionIDs(t, readMessage(t, ada)); fmt.Sprint(got) != "[1 2 3 4 5 6]" {
+		t.Errorf("unshuffled question sent options %v", got)
+	}
+}
+
+// optionIDsOf lists options' IDs in order
+func optionIDsOf(options []GameOption) []uint {
+	ids := make([]uint, len(options))
+	for i, option := range options {
+		ids[i] = option.ID
+	}
+	return ids
+}
+
+func TestHostReconnectWithinGracePeriodKeepsTheGame(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user, game, players := startTestGame(t, s, 2, "Ada")
+
+	const grace = 300 * time.Millisecond
+	h := NewHub(s, testLogger, 0, 0, grace)
+	go h.Run()
+	defer close(h.quit)
+
+	newClient := func(role string, id uint) *Client {
+		return &Client{hub: h, id: fmt.Sprintf("%s-%d", role, id), send: make(chan []byte, 64), gamePin: game.Pin, playerID: id, role: role}
+	}
+	player := newClient(RolePlayer, players[0].ID)
+	host := newClient(RoleHost, user.ID)
+	h.register <- player
+	h.register <- host
+	waitForClients(t, h, 2)
+
+	// A blip: the host drops and comes back within the grace period
+	h.unregister <- host
+	waitForClients(t, h, 1)
+	time.Sleep(grace / 3)
+	h.register <- newClient(RoleHost, user.ID)
+	waitForMessage(t, player, "host_reconnected")
+
+	time.Sleep(2 * grace)
+	if stored, err := s.GetGameByPin(ctx, game.Pin); err != nil || stored.Status != "active" {
+		t.Fatalf("after the host reconnected the game is %+v, %v; want it still active", stored, err)
+	}
+}
+
+func TestHostGoneBeyondGracePeriodEndsTheGame(t *testing.T) {
+	s := newTestGameService(t)
+	ctx := context.Background()
+	user, game, players := startTestGame(t, s, 2, "Ada")
+
+	const grace = 200 * time.Millisecond
+	h := NewHub(s, testLogger, 0, 0, grace)
+	go h.Run()
+	defer close(h.quit)
+
+	player := &Client{hub: h, id: "player", send: make(chan []byte, 64), gamePin: game.Pin, playerID: players[0].ID, role: RolePlayer}
+	host := &Client{hub: h, id: "host", send: make(chan []byte, 64), gamePin: game.Pin, playerID: user.ID, role: RoleHost}
+	h.register <- player
+	h.register <- host
+	waitForClients(t, h, 2)
+
+	h.unregister <- host
+	time.Sleep(grace / 2)
+	if stored, err := s.GetGameByPin(ctx, game.Pin); err != nil || stored.Status != "active" {
+		t.Fatalf("within the grace period the game is %+v, %v; want it still active", stored, err)
+	}
+
+	waitForMessage(t, player, "game_end")
+	if stored, err := s.GetGameByPin(ctx, game.Pin); err != nil || stored.Status != "finished" {
+		t.Errorf("after the grace period the game is %+v, %v; want it finished", stored, err)
+	}
+}
+
+func TestHostIsClassifiedByRoleNotID(t *testing.T) {
+	h := NewHub(nil, testLogger, 0, 0, time.Minute)
+	go h.Run()
+	defer close(h.quit)
+
+	// Player 0 is an ordinary player, and player 4 shares the host's user ID
+	dialTestClient(t, h, "abc123", RolePlayer, 0)
+	dialTestClient(t, h, "abc123", RolePlayer, 4)
+	waitForClients(t, h, 2)
+
+	if h.IsCreatorConnected("abc123") {
+		t.Fatal("players counted as the creator")
+	}
+
+	host := dialTestClient(t, h, "abc123", RoleHost, 4)
+	waitForClients(t, h, 3)
+	if !h.IsCreatorConnected("ABC123") {
+		t.Fatal("the host connection wasn't counted as the creator")
+	}
+	h.mutex.RLock()
+	for client := range h.clients {
+		if client.isHost() != (client.role == RoleHost) {
+			t.Errorf("client %s with role %s has isHost %v", client.id, client.role, client.isHost())
+		}
+	}
+	if got := len(h.games["abc123"][RoleHost]); got != 1 {
+		t.Errorf("host bucket has %d clients, want 1", got)
+	}
+	h.mutex.RUnlock()
+
+	// Only the host leaving starts the creator's grace period
+	h.mutex.RLock()
+	var player *Client
+	for client := range h.clients {
+		if client.role == RolePlayer && client.playerID == 4 {
+			player = client
+		}
+	}
+	h.mutex.RUnlock()
+	h.unregister <- player
+	waitForClients(t, h, 2)
+	h.mutex.RLock()
+	pending := len(h.hostGraceTimers)
+	h.mutex.RUnlock()
+	if pending != 0 {
+		t.Error("a player sharing the host's ID started the creator grace period")
+	}
+
+	host.Close()
+	waitForClients(t, h, 1)
+	h.mutex.RLock()
+	_, pendingHost := h.hostGraceTimers["abc123"]
+	h.mutex.RUnlock()
+	if !pendingHost {
+		t.Error("the host leaving didn't start the creator grace period")
+	}
+}
+
+func TestSendToPlayerReachesOnlyThatPlayer(t *testing.T) {
+	h := newTestHub()
+	firstTab := connectTestClient(h, "abc123", RolePlayer, 2)
+	secondTab := connectTestClient(h, "abc123", RolePlayer, 2)
+	others := map[string]*Client{
+		"another player":                 connectTestClient(h, "abc123", RolePlayer, 3),
+		"host sharing the player's ID":   connectTestClient(h, "abc123", RoleHost, 2),
+		"spectator":                      connectTestClient(h, "abc123", RoleSpectator, 0),
+		"same player ID in another game": connectTestClient(h, "def456", RolePlayer, 2),
+	}
+
+	if got := h.SendToPlayer("ABC123", 2, "kicked", map[string]string{"reason": "test"}); got != 2 {
+		t.Errorf("SendToPlayer() reached %d connections, want 2", got)
+	}
+	for _, tab := range []*Client{firstTab, secondTab} {
+		if message := readMessage(t, tab); message.Type != "kicked" {
+			t.Errorf("player tab was sent %q, want kicked", message.Type)
+		}
+	}
+	for name, client := range others {
+		if messages := drainMessages(t, client); len(messages) != 0 {
+			t.Errorf("the %s was sent %v", name, messages)
+		}
+	}
+
+	if got := h.SendToPlayer("abc123", 9, "kicked", nil); got != 0 {
+		t.Errorf("SendToPlayer() to an absent player reached %d connections", got)
+	}
+}
+
+func TestSendToPlayerDropsAStalledConnection(t *testing.T) {
+	h := newTestHub()
+	stalled := &Client{hub: h, id: "stalled", send: make(chan []byte), gamePin: "abc123", playerID: 2, role: RolePlayer}
+	h.mutex.Lock()
+	h.addClient(stalled)
+	h.mutex.Unlock()
+	healthy := connectTestClient(h, "abc123", RolePlayer, 2)
+
+	if got := h.SendToPlayer("abc123", 2, "note", nil); got != 1 {
+		t.Errorf("SendToPlayer() reached %d connections, want 1", got)
+	}
+	if message := readMessage(t, healthy); message.Type != "note" {
+		t.Errorf("healthy connection was sent %q, want note", message.Type)
+	}
+	h.mutex.RLock()
+	_, stillConnected := h.clients[stalled]
+	h.mutex.RUnlock()
+	if stillConnected {
+		t.Error("the connection with a full buffer was kept")
+	}
+}
+
+func TestPlayerDisconnectUpdatesTheRoster(t *testing.T) {
+	h := newTestHub()
+	go h.Run()
+	defer close(h.quit)
+
+	host := &Client{hub: h, id: "host", send: make(chan []byte, 16), gamePin: "abc123", playerID: 1, role: RoleHost}
+	firstTab := &Client{hub: h, id: "tab-1", send: make(chan []byte, 16), gamePin: "abc123", playerID: 2, playerName: "Ada", role: RolePlayer}
+	secondTab := &Client{hub: h, id: "tab-2", send: make(chan []byte, 16), gamePin: "abc123", playerID: 2, playerName: "Ada", role: RolePlayer}
+	h.register <- host
+	h.register <- firstTab
+	waitForClients(t, h, 2)
+
+	connected := waitForMessage(t, host, "player_update")
+	if payload, _ := connected.Payload.(map[string]interface{}); payload["action"] != "connected" {
+		t.Errorf("player_update on connect = %v, want action connected", connected.Payload)
+	}
+
+	players := []GamePlayer{{ID: 2, Name: "Ada"}, {ID: 3, Name: "Grace"}}
+	roster := h.roster("abc123", players)
+	if !roster[0].Online || roster[1].Online {
+		t.Errorf("roster = %+v, want only Ada online", roster)
+	}
+
+	// Closing one of two tabs leaves the player online
+	h.register <- secondTab
+	waitForClients(t, h, 3)
+	h.unregister <- firstTab
+	waitForClients(t, h, 2)
+	if !h.roster("abc123", players)[0].Online {
+		t.Error("Ada went offline with a tab still open")
+	}
+	for _, message := range drainMessages(t, host) {
+		if payload, _ := message.Payload.(map[string]interface{}); message.Type == "player_update" && payload["action"] == "disconnected" {
+			t.Error("disconnected was sent while the player still had a tab open")
+		}
+	}
+
+	h.unregister <- secondTab
+	disconnected := waitForMessage(t, host, "player_update")
+	payload, _ := disconnected.Payload.(map[string]interface{})
+	player, _ := payload["player"].(map[string]interface{})
+	if payload["action"] != "disconnected" || player["id"] != float64(2) {
+		t.Errorf("player_update on disconnect = %v, want Ada disconnected", disconnected.Payload)
+	}
+	if h.roster("abc123", players)[0].Online {
+		t.Error("Ada is still online after the last connection closed")
+	}
+}
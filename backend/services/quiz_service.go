@@ -1,121 +1,402 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"openquiz/models"
 
 	"gorm.io/gorm"
 )
 
+// Question time limit bounds in seconds, and the limit for questions that
+// set none on a quiz without a default
+const (
+	minTimeLimit     = 5
+	maxTimeLimit     = 300
+	defaultTimeLimit = 30
+)
+
+// maxQuizBatchSize is the most quizzes one batch request may create
+const maxQuizBatchSize = 50
+
+// ErrQuizLimitReached is returned when creating or restoring a quiz would take
+// the user past the per-user quiz cap
+var ErrQuizLimitReached = errors.New("quiz limit reached")
+
+// ErrQuestionLimitExceeded is returned when a quiz would have more questions
+// than the per-quiz cap
+var ErrQuestionLimitExceeded = errors.New("too many questions")
+
 type QuizService struct {
-	db *gorm.DB
+	db                  *gorm.DB
+	maxQuizzesPerUser   int // 0 for no cap
+	maxQuestionsPerQuiz int // 0 for no cap
 }
 
-func NewQuizService(db *gorm.DB) *QuizService {
-	return &QuizService{db: db}
+func NewQuizService(db *gorm.DB, maxQuizzesPerUser, maxQuestionsPerQuiz int) *QuizService {
+	return &QuizService{
+		db:                  db,
+		maxQuizzesPerUser:   maxQuizzesPerUser,
+		maxQuestionsPerQuiz: maxQuestionsPerQuiz,
+	}
 }
 
 type CreateQuizRequest struct {
-	Title       string                  `json:"title" binding:"required"`
-	Description string                  `json:"description"`
-	Questions   []CreateQuestionRequest `json:"questions" binding:"required,min=1"`
+	Title             string                  `json:"title" binding:"required"`
+	Description       string                  `json:"description"`
+	ConfidenceScoring bool                    `json:"confidence_scoring"`
+	FixedOptionCount  int                     `json:"fixed_option_count" binding:"omitempty,min=2,max=6"`
+	GradeRubric       []models.GradeBand      `json:"grade_rubric"`
+	BasePoints        *int                    `json:"base_points" binding:"omitempty,min=0,max=1000"`
+	MaxTimeBonus      *int                    `json:"max_time_bonus" binding:"omitempty,min=0,max=1000"`
+	SpeedBonus        *bool                   `json:"speed_bonus"`
+	PenaltyPoints     *int                    `json:"penalty_points" binding:"omitempty,min=0,max=1000"`
+	FastestBonus      *int                    `json:"fastest_bonus" binding:"omitempty,min=0,max=1000"`
+	FloorScoreAtZero  bool                    `json:"floor_score_at_zero"`
+	DefaultTimeLimit  *int                    `json:"default_time_limit"` // for questions without their own time_limit
+	RevealSeconds     *int                    `json:"reveal_seconds" binding:"omitempty,min=0,max=30"`
+	QuestionCountdown *int                    `json:"question_countdown" binding:"omitempty,min=0,max=10"`
+	Questions         []CreateQuestionRequest `json:"questions" binding:"required,min=1"`
 }
 
 type CreateQuestionRequest struct {
+	ID        uint                  `json:"id"` // on update, the existing question to edit in place; omit to add one
 	Text      string                `json:"text" binding:"required"`
-	TimeLimit int                   `json:"time_limit" binding:"required,min=5,max=300"`
-	Order     int                   `json:"order" binding:"required"`
+	Type      string                `json:"type" binding:"omitempty,oneof=multiple_choice poll"` // defaults to multiple_choice
+	TimeLimit int                   `json:"time_limit"`                                          // defaults to the quiz's default_time_limit
+	Order     *int                  `json:"order"`                                               // defaults to the position in the list
 	Options   []CreateOptionRequest `json:"options" binding:"required,min=2,max=6"`
 }
 
 type CreateOptionRequest struct {
+	ID        uint   `json:"id"` // on update, the existing option to edit in place; omit to add one
 	Text      string `json:"text" binding:"required"`
 	IsCorrect bool   `json:"is_correct"`
-	Order     int    `json:"order" binding:"required"`
+	Order     *int   `json:"order"` // defaults to the position in the list
 }
 
 type UpdateQuizRequest struct {
-	Title       string                  `json:"title"`
-	Description string                  `json:"description"`
-	Questions   []CreateQuestionRequest `json:"questions"`
+	Title             string                  `json:"title"`
+	Description       string                  `json:"description"`
+	ConfidenceScoring *bool                   `json:"confidence_scoring"`
+	FixedOptionCount  *int                    `json:"fixed_option_count" binding:"omitempty,min=0,max=6"` // 0 removes the requirement
+	GradeRubric       []models.GradeBand      `json:"grade_rubric"`                                       // an empty list removes the rubric
+	BasePoints        *int                    `json:"base_points" binding:"omitempty,min=0,max=1000"`
+	MaxTimeBonus      *int                    `json:"max_time_bonus" binding:"omitempty,min=0,max=1000"`
+	SpeedBonus        *bool                   `json:"speed_bonus"`
+	PenaltyPoints     *int                    `json:"penalty_points" binding:"omitempty,min=0,max=1000"`
+	FastestBonus      *int                    `json:"fastest_bonus" binding:"omitempty,min=0,max=1000"`
+	FloorScoreAtZero  *bool                   `json:"floor_score_at_zero"`
+	DefaultTimeLimit  *int                    `json:"default_time_limit"` // 0 removes the default
+	RevealSeconds     *int                    `json:"reveal_seconds" binding:"omitempty,min=0,max=30"`
+	QuestionCountdown *int                    `json:"question_countdown" binding:"omitempty,min=0,max=10"`
+	Questions         []CreateQuestionRequest `json:"questions"`
 }
 
-func (s *QuizService) CreateQuiz(userID uint, req *CreateQuizRequest) (*models.Quiz, error) {
-	// Start transaction
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+// UpdateTimeLimitsRequest sets either one time limit for every question or
+// individual limits keyed by question ID
+type UpdateTimeLimitsRequest struct {
+	TimeLimit  *int         `json:"time_limit"`
+	TimeLimits map[uint]int `json:"time_limits"`
+}
+
+// QuizListItem is a quiz in the owner's list along with a summary of it
+type QuizListItem struct {
+	models.Quiz
+	QuestionCount     int `json:"question_count"`
+	EstimatedDuration int `json:"estimated_duration"` // seconds, the sum of the question time limits
+	TimesPlayed       int `json:"times_played"`       // games started with the quiz
+}
+
+// QuizPreview shows a quiz's questions the way a game presents them
+type QuizPreview struct {
+	ID        uint           `json:"id"`
+	Title     string         `json:"title"`
+	Questions []GameQuestion `json:"questions"`
+}
+
+// ReorderQuestionsRequest lists every question ID of a quiz in the new order
+type ReorderQuestionsRequest struct {
+	QuestionIDs []uint `json:"question_ids" binding:"required,min=1"`
+}
+
+// ReorderOptionsRequest lists every option ID of a question in the new order
+type ReorderOptionsRequest struct {
+	OptionIDs []uint `json:"option_ids" binding:"required,min=1"`
+}
+
+func (s *QuizService) CreateQuiz(ctx context.Context, userID uint, req *CreateQuizRequest) (*models.Quiz, error) {
+	if err := s.checkQuizLimit(ctx, userID, 1); err != nil {
+		return nil, err
+	}
+
+	var quiz *models.Quiz
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		quiz, err = s.createQuiz(tx, userID, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the quiz with questions and options loaded
+	return s.GetQuizByID(ctx, quiz.ID, userID)
+}
+
+// BatchQuizError reports which quiz of a batch failed, counting from 0
+type BatchQuizError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchQuizError) Error() string {
+	return fmt.Sprintf("quiz %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchQuizError) Unwrap() error {
+	return e.Err
+}
+
+// CreateQuizzes creates several quizzes in one transaction, so either all of
+// them are created or, if any is invalid, none are
+func (s *QuizService) CreateQuizzes(ctx context.Context, userID uint, reqs []CreateQuizRequest) ([]models.Quiz, error) {
+	if len(reqs) == 0 || len(reqs) > maxQuizBatchSize {
+		return nil, fmt.Errorf("a batch must contain between 1 and %d quizzes", maxQuizBatchSize)
+	}
+	if err := s.checkQuizLimit(ctx, userID, len(reqs)); err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(reqs))
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range reqs {
+			quiz, err := s.createQuiz(tx, userID, &reqs[i])
+			if err != nil {
+				return &BatchQuizError{Index: i, Err: err}
+			}
+			ids[i] = quiz.ID
 		}
-	}()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	quizzes := make([]models.Quiz, len(ids))
+	for i, id := range ids {
+		quiz, err := s.GetQuizByID(ctx, id, userID)
+		if err != nil {
+			return nil, err
+		}
+		quizzes[i] = *quiz
+	}
+	return quizzes, nil
+}
+
+// createQuiz validates a quiz request and creates the quiz with its questions
+// and options inside the caller's transaction
+func (s *QuizService) createQuiz(tx *gorm.DB, userID uint, req *CreateQuizRequest) (*models.Quiz, error) {
+	if err := s.checkQuestionLimit(len(req.Questions)); err != nil {
+		return nil, err
+	}
+	if req.GradeRubric != nil {
+		if err := validateGradeRubric(req.GradeRubric); err != nil {
+			return nil, err
+		}
+	}
+	if req.DefaultTimeLimit != nil {
+		if err := validateTimeLimit(*req.DefaultTimeLimit); err != nil {
+			return nil, err
+		}
+	}
 
 	// Create quiz
 	quiz := models.Quiz{
-		Title:       req.Title,
-		Description: req.Description,
-		UserID:      userID,
+		Title:             req.Title,
+		Description:       req.Description,
+		UserID:            userID,
+		ConfidenceScoring: req.ConfidenceScoring,
+		FixedOptionCount:  req.FixedOptionCount,
+		GradeRubric:       req.GradeRubric,
+		BasePoints:        req.BasePoints,
+		MaxTimeBonus:      req.MaxTimeBonus,
+		SpeedBonus:        req.SpeedBonus,
+		PenaltyPoints:     req.PenaltyPoints,
+		FastestBonus:      req.FastestBonus,
+		FloorScoreAtZero:  req.FloorScoreAtZero,
+		DefaultTimeLimit:  req.DefaultTimeLimit,
+		RevealSeconds:     req.RevealSeconds,
+		QuestionCountdown: req.QuestionCountdown,
 	}
 
 	if err := tx.Create(&quiz).Error; err != nil {
-		tx.Rollback()
 		return nil, err
 	}
 
 	// Create questions and options
-	for _, qReq := range req.Questions {
+	for _, qReq := range normalizeQuestionOrder(req.Questions) {
+		timeLimit, err := questionTimeLimit(qReq.TimeLimit, &quiz)
+		if err != nil {
+			return nil, err
+		}
+
 		question := models.Question{
 			QuizID:    quiz.ID,
 			Text:      qReq.Text,
-			TimeLimit: qReq.TimeLimit,
-			Order:     qReq.Order,
+			Type:      questionType(qReq.Type),
+			TimeLimit: timeLimit,
+			Order:     *qReq.Order,
 		}
 
 		if err := tx.Create(&question).Error; err != nil {
-			tx.Rollback()
 			return nil, err
 		}
 
-		// Validate that only one option is correct
-		correctCount := 0
-		for _, optReq := range qReq.Options {
-			if optReq.IsCorrect {
-				correctCount++
-			}
+		if err := validateOptionCount(len(qReq.Options), quiz.FixedOptionCount); err != nil {
+			return nil, err
 		}
-		if correctCount != 1 {
-			tx.Rollback()
-			return nil, errors.New("each question must have exactly one correct answer")
+
+		if err := validateCorrectOptions(question.Type, qReq.Options); err != nil {
+			return nil, err
+		}
+
+		if err := validateDistinctOptions(qReq.Text, qReq.Options); err != nil {
+			return nil, err
 		}
 
 		// Create options
-		for _, optReq := range qReq.Options {
+		for _, optReq := range normalizeOptionOrder(qReq.Options) {
 			option := models.Option{
 				QuestionID: question.ID,
 				Text:       optReq.Text,
 				IsCorrect:  optReq.IsCorrect,
-				Order:      optReq.Order,
+				Order:      *optReq.Order,
 			}
 
 			if err := tx.Create(&option).Error; err != nil {
-				tx.Rollback()
 				return nil, err
 			}
 		}
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
+	return &quiz, nil
+}
+
+// normalizeQuestionOrder sorts questions by their requested Order, keeping the
+// submitted sequence for duplicates, and renumbers them 0..n-1. Games traverse
+// questions by index, so the stored Order must be exactly that index.
+func normalizeQuestionOrder(questions []CreateQuestionRequest) []CreateQuestionRequest {
+	normalized := make([]CreateQuestionRequest, len(questions))
+	copy(normalized, questions)
+
+	for i := range normalized {
+		normalized[i].Order = orderOrIndex(normalized[i].Order, i)
 	}
 
-	// Fetch the quiz with questions and options loaded
-	return s.GetQuizByID(quiz.ID, userID)
+	sort.SliceStable(normalized, func(i, j int) bool {
+		return *normalized[i].Order < *normalized[j].Order
+	})
+
+	for i := range normalized {
+		normalized[i].Order = orderOrIndex(nil, i)
+	}
+
+	return normalized
+}
+
+// normalizeOptionOrder does the same for a question's options, so duplicate
+// orders can't make their display order depend on the database
+func normalizeOptionOrder(options []CreateOptionRequest) []CreateOptionRequest {
+	normalized := make([]CreateOptionRequest, len(options))
+	copy(normalized, options)
+
+	for i := range normalized {
+		normalized[i].Order = orderOrIndex(normalized[i].Order, i)
+	}
+
+	sort.SliceStable(normalized, func(i, j int) bool {
+		return *normalized[i].Order < *normalized[j].Order
+	})
+
+	for i := range normalized {
+		normalized[i].Order = orderOrIndex(nil, i)
+	}
+
+	return normalized
+}
+
+// orderOrIndex returns a copy of the requested order, or the item's position
+// in the request when no order was given
+func orderOrIndex(order *int, index int) *int {
+	if order != nil {
+		index = *order
+	}
+	return &index
+}
+
+// validateOptionCount checks a question's option count against the quiz's fixed
+// option count, if it has one
+func validateOptionCount(optionCount int, fixedOptionCount int) error {
+	if fixedOptionCount > 0 && optionCount != fixedOptionCount {
+		return fmt.Errorf("each question in this quiz must have exactly %d options", fixedOptionCount)
+	}
+	return nil
+}
+
+// questionType returns the stored type for a requested one, multiple choice
+// when none was given
+func questionType(requested string) string {
+	if requested == "" {
+		return models.QuestionTypeMultipleChoice
+	}
+	return requested
+}
+
+// validateCorrectOptions checks that a multiple choice question has exactly one
+// correct option and a poll has none
+func validateCorrectOptions(questionType string, options []CreateOptionRequest) error {
+	correctCount := 0
+	for _, optReq := range options {
+		if optReq.IsCorrect {
+			correctCount++
+		}
+	}
+
+	if questionType == models.QuestionTypePoll {
+		if correctCount > 0 {
+			return errors.New("poll questions can't have a correct answer")
+		}
+		return nil
+	}
+	if correctCount != 1 {
+		return errors.New("each question must have exactly one correct answer")
+	}
+	return nil
+}
+
+// validateDistinctOptions rejects a question with two options whose text only
+// differs in case or surrounding whitespace
+func validateDistinctOptions(questionText string, options []CreateOptionRequest) error {
+	seen := make(map[string]bool, len(options))
+	for _, optReq := range options {
+		key := strings.ToLower(strings.TrimSpace(optReq.Text))
+		if seen[key] {
+			return fmt.Errorf("question %q has more than one option %q", questionText, strings.TrimSpace(optReq.Text))
+		}
+		seen[key] = true
+	}
+	return nil
 }
 
-func (s *QuizService) GetUserQuizzes(userID uint) ([]models.Quiz, error) {
+func (s *QuizService) GetUserQuizzes(ctx context.Context, userID uint) ([]QuizListItem, error) {
 	var quizzes []models.Quiz
-	err := s.db.Where("user_id = ?", userID).
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).
 		Preload("Questions", func(db *gorm.DB) *gorm.DB {
 			return db.Order("questions.order")
 		}).
@@ -123,13 +404,94 @@ func (s *QuizService) GetUserQuizzes(userID uint) ([]models.Quiz, error) {
 			return db.Order("options.order")
 		}).
 		Order("created_at DESC").
-		Find(&quizzes).Error
-	return quizzes, err
+		Find(&quizzes).Error; err != nil {
+		return nil, err
+	}
+
+	quizIDs := make([]uint, len(quizzes))
+	for i, quiz := range quizzes {
+		quizIDs[i] = quiz.ID
+	}
+
+	// Summaries come from grouped aggregates rather than the loaded questions
+	var questionStats []struct {
+		QuizID            uint
+		QuestionCount     int
+		EstimatedDuration int
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Question{}).
+		Select("quiz_id, COUNT(*) AS question_count, COALESCE(SUM(time_limit), 0) AS estimated_duration").
+		Where("quiz_id IN ?", quizIDs).
+		Group("quiz_id").
+		Scan(&questionStats).Error; err != nil {
+		return nil, err
+	}
+
+	var gameStats []struct {
+		QuizID      uint
+		TimesPlayed int
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Game{}).
+		Select("quiz_id, COUNT(*) AS times_played").
+		Where("quiz_id IN ? AND started_at IS NOT NULL", quizIDs).
+		Group("quiz_id").
+		Scan(&gameStats).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]QuizListItem, len(quizzes))
+	index := make(map[uint]*QuizListItem, len(quizzes))
+	for i, quiz := range quizzes {
+		items[i].Quiz = quiz
+		index[quiz.ID] = &items[i]
+	}
+	for _, stat := range questionStats {
+		index[stat.QuizID].QuestionCount = stat.QuestionCount
+		index[stat.QuizID].EstimatedDuration = stat.EstimatedDuration
+	}
+	for _, stat := range gameStats {
+		index[stat.QuizID].TimesPlayed = stat.TimesPlayed
+	}
+
+	return items, nil
 }
 
-func (s *QuizService) GetQuizByID(quizID uint, userID uint) (*models.Quiz, error) {
+// PreviewQuiz returns the owner's quiz in the shape games broadcast its
+// questions, with the correct answers marked, without creating a game
+func (s *QuizService) PreviewQuiz(ctx context.Context, quizID uint, userID uint) (*QuizPreview, error) {
+	quiz, err := s.GetQuizByID(ctx, quizID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &QuizPreview{
+		ID:        quiz.ID,
+		Title:     quiz.Title,
+		Questions: make([]GameQuestion, len(quiz.Questions)),
+	}
+	for i, question := range quiz.Questions {
+		preview.Questions[i] = *toGameQuestion(question, true)
+	}
+	return preview, nil
+}
+
+func (s *QuizService) GetQuizByID(ctx context.Context, quizID uint, userID uint) (*models.Quiz, error) {
+	return s.getQuiz(s.db.WithContext(ctx), quizID, userID)
+}
+
+// omitPassword keeps the password hash out of preloaded authors entirely
+func omitPassword(db *gorm.DB) *gorm.DB {
+	return db.Omit("password")
+}
+
+// GetQuizWithAuthor is GetQuizByID with the author's account included
+func (s *QuizService) GetQuizWithAuthor(ctx context.Context, quizID uint, userID uint) (*models.Quiz, error) {
+	return s.getQuiz(s.db.WithContext(ctx).Preload("User", omitPassword), quizID, userID)
+}
+
+func (s *QuizService) getQuiz(db *gorm.DB, quizID uint, userID uint) (*models.Quiz, error) {
 	var quiz models.Quiz
-	err := s.db.Where("id = ? AND user_id = ?", quizID, userID).
+	err := db.Where("id = ? AND user_id = ?", quizID, userID).
 		Preload("Questions", func(db *gorm.DB) *gorm.DB {
 			return db.Order("questions.order")
 		}).
@@ -140,15 +502,15 @@ func (s *QuizService) GetQuizByID(quizID uint, userID uint) (*models.Quiz, error
 	return &quiz, err
 }
 
-func (s *QuizService) UpdateQuiz(quizID uint, userID uint, req *UpdateQuizRequest) (*models.Quiz, error) {
+func (s *QuizService) UpdateQuiz(ctx context.Context, quizID uint, userID uint, req *UpdateQuizRequest) (*models.Quiz, error) {
 	// Check if quiz exists and belongs to user
-	quiz, err := s.GetQuizByID(quizID, userID)
+	quiz, err := s.GetQuizByID(ctx, quizID, userID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Start transaction
-	tx := s.db.Begin()
+	tx := s.db.WithContext(ctx).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -162,78 +524,606 @@ func (s *QuizService) UpdateQuiz(quizID uint, userID uint, req *UpdateQuizReques
 	if req.Description != "" {
 		quiz.Description = req.Description
 	}
+	if req.ConfidenceScoring != nil {
+		quiz.ConfidenceScoring = *req.ConfidenceScoring
+	}
+	if req.BasePoints != nil {
+		quiz.BasePoints = req.BasePoints
+	}
+	if req.MaxTimeBonus != nil {
+		quiz.MaxTimeBonus = req.MaxTimeBonus
+	}
+	if req.SpeedBonus != nil {
+		quiz.SpeedBonus = req.SpeedBonus
+	}
+	if req.PenaltyPoints != nil {
+		quiz.PenaltyPoints = req.PenaltyPoints
+	}
+	if req.FastestBonus != nil {
+		quiz.FastestBonus = req.FastestBonus
+	}
+	if req.FloorScoreAtZero != nil {
+		quiz.FloorScoreAtZero = *req.FloorScoreAtZero
+	}
+	if req.RevealSeconds != nil {
+		quiz.RevealSeconds = req.RevealSeconds
+	}
+	if req.QuestionCountdown != nil {
+		quiz.QuestionCountdown = req.QuestionCountdown
+	}
+	if req.DefaultTimeLimit != nil {
+		if *req.DefaultTimeLimit == 0 {
+			quiz.DefaultTimeLimit = nil
+		} else if err := validateTimeLimit(*req.DefaultTimeLimit); err != nil {
+			tx.Rollback()
+			return nil, err
+		} else {
+			quiz.DefaultTimeLimit = req.DefaultTimeLimit
+		}
+	}
+	if req.GradeRubric != nil {
+		if len(req.GradeRubric) == 0 {
+			quiz.GradeRubric = nil
+		} else if err := validateGradeRubric(req.GradeRubric); err != nil {
+			tx.Rollback()
+			return nil, err
+		} else {
+			quiz.GradeRubric = req.GradeRubric
+		}
+	}
+	if req.FixedOptionCount != nil {
+		if *req.FixedOptionCount == 1 {
+			tx.Rollback()
+			return nil, errors.New("fixed option count must be between 2 and 6")
+		}
+		quiz.FixedOptionCount = *req.FixedOptionCount
+
+		// Existing questions must already satisfy a new count if they aren't being replaced
+		if req.Questions == nil {
+			for _, question := range quiz.Questions {
+				if err := validateOptionCount(len(question.Options), quiz.FixedOptionCount); err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+			}
+		}
+	}
 
 	if err := tx.Save(quiz).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	// If questions are provided, replace all questions
+	// If questions are provided, make the quiz's questions match them
 	if req.Questions != nil {
-		// Delete existing questions and options
-		if err := tx.Where("quiz_id = ?", quizID).Delete(&models.Question{}).Error; err != nil {
+		if err := s.checkQuestionLimit(len(req.Questions)); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := syncQuestions(tx, quiz, req.Questions); err != nil {
 			tx.Rollback()
 			return nil, err
 		}
+	}
 
-		// Create new questions and options
-		for _, qReq := range req.Questions {
-			question := models.Question{
-				QuizID:    quiz.ID,
-				Text:      qReq.Text,
-				TimeLimit: qReq.TimeLimit,
-				Order:     qReq.Order,
-			}
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	// Fetch the updated quiz with questions and options loaded
+	return s.GetQuizByID(ctx, quiz.ID, userID)
+}
+
+// syncQuestions makes a quiz's questions match the requested ones. Questions
+// and options that carry an ID are updated in place, those without one are
+// created and existing ones left out are deleted, so the IDs that game answers
+// reference stay valid for everything that wasn't removed.
+func syncQuestions(tx *gorm.DB, quiz *models.Quiz, requests []CreateQuestionRequest) error {
+	existing := make(map[uint]models.Question, len(quiz.Questions))
+	for _, question := range quiz.Questions {
+		existing[question.ID] = question
+	}
+
+	kept := make(map[uint]bool, len(requests))
+	for _, qReq := range requests {
+		if qReq.ID == 0 {
+			continue
+		}
+		if _, ok := existing[qReq.ID]; !ok {
+			return fmt.Errorf("question %d does not belong to this quiz", qReq.ID)
+		}
+		if kept[qReq.ID] {
+			return fmt.Errorf("question %d is listed more than once", qReq.ID)
+		}
+		kept[qReq.ID] = true
+	}
+
+	// Remove the questions that were left out, along with their options
+	var removed []uint
+	for _, question := range quiz.Questions {
+		if !kept[question.ID] {
+			removed = append(removed, question.ID)
+		}
+	}
+	if len(removed) > 0 {
+		if err := tx.Where("question_id IN ?", removed).Delete(&models.Option{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", removed).Delete(&models.Question{}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, qReq := range normalizeQuestionOrder(requests) {
+		qType := questionType(qReq.Type)
+		if err := validateOptionCount(len(qReq.Options), quiz.FixedOptionCount); err != nil {
+			return err
+		}
+		if err := validateCorrectOptions(qType, qReq.Options); err != nil {
+			return err
+		}
+		if err := validateDistinctOptions(qReq.Text, qReq.Options); err != nil {
+			return err
+		}
+		timeLimit, err := questionTimeLimit(qReq.TimeLimit, quiz)
+		if err != nil {
+			return err
+		}
 
+		question := models.Question{
+			ID:        qReq.ID,
+			QuizID:    quiz.ID,
+			Text:      qReq.Text,
+			Type:      qType,
+			TimeLimit: timeLimit,
+			Order:     *qReq.Order,
+		}
+		if qReq.ID == 0 {
 			if err := tx.Create(&question).Error; err != nil {
-				tx.Rollback()
-				return nil, err
+				return err
 			}
+		} else if err := tx.Model(&models.Question{ID: qReq.ID}).Updates(map[string]interface{}{
+			"text":       question.Text,
+			"type":       question.Type,
+			"time_limit": question.TimeLimit,
+			"order":      question.Order,
+		}).Error; err != nil {
+			return err
+		}
 
-			// Validate that only one option is correct
-			correctCount := 0
-			for _, optReq := range qReq.Options {
-				if optReq.IsCorrect {
-					correctCount++
-				}
+		if err := syncOptions(tx, question.ID, existing[qReq.ID].Options, qReq.Options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncOptions makes a question's options match the requested ones the same
+// way syncQuestions does for questions
+func syncOptions(tx *gorm.DB, questionID uint, current []models.Option, requests []CreateOptionRequest) error {
+	existing := make(map[uint]bool, len(current))
+	for _, option := range current {
+		existing[option.ID] = true
+	}
+
+	kept := make(map[uint]bool, len(requests))
+	for _, optReq := range requests {
+		if optReq.ID == 0 {
+			continue
+		}
+		if !existing[optReq.ID] {
+			return fmt.Errorf("option %d does not belong to question %d", optReq.ID, questionID)
+		}
+		if kept[optReq.ID] {
+			return fmt.Errorf("option %d is listed more than once", optReq.ID)
+		}
+		kept[optReq.ID] = true
+	}
+
+	var removed []uint
+	for _, option := range current {
+		if !kept[option.ID] {
+			removed = append(removed, option.ID)
+		}
+	}
+	if len(removed) > 0 {
+		if err := tx.Where("id IN ?", removed).Delete(&models.Option{}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, optReq := range normalizeOptionOrder(requests) {
+		if optReq.ID != 0 {
+			if err := tx.Model(&models.Option{ID: optReq.ID}).Updates(map[string]interface{}{
+				"text":       optReq.Text,
+				"is_correct": optReq.IsCorrect,
+				"order":      *optReq.Order,
+			}).Error; err != nil {
+				return err
 			}
-			if correctCount != 1 {
-				tx.Rollback()
-				return nil, errors.New("each question must have exactly one correct answer")
+			continue
+		}
+
+		option := models.Option{
+			QuestionID: questionID,
+			Text:       optReq.Text,
+			IsCorrect:  optReq.IsCorrect,
+			Order:      *optReq.Order,
+		}
+		if err := tx.Create(&option).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateTimeLimits changes only the time limits of a quiz's questions, leaving
+// the questions themselves (and their IDs) untouched
+func (s *QuizService) UpdateTimeLimits(ctx context.Context, quizID uint, userID uint, req *UpdateTimeLimitsRequest) (*models.Quiz, error) {
+	if (req.TimeLimit == nil) == (len(req.TimeLimits) == 0) {
+		return nil, errors.New("provide either time_limit or time_limits")
+	}
+
+	quiz, err := s.GetQuizByID(ctx, quizID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.TimeLimit != nil {
+		if err := validateTimeLimit(*req.TimeLimit); err != nil {
+			return nil, err
+		}
+		if err := s.db.WithContext(ctx).Model(&models.Question{}).Where("quiz_id = ?", quiz.ID).
+			Update("time_limit", *req.TimeLimit).Error; err != nil {
+			return nil, err
+		}
+		return s.GetQuizByID(ctx, quiz.ID, userID)
+	}
+
+	questionIDs := make(map[uint]bool, len(quiz.Questions))
+	for _, question := range quiz.Questions {
+		questionIDs[question.ID] = true
+	}
+	for questionID, timeLimit := range req.TimeLimits {
+		if !questionIDs[questionID] {
+			return nil, fmt.Errorf("question %d does not belong to this quiz", questionID)
+		}
+		if err := validateTimeLimit(timeLimit); err != nil {
+			return nil, fmt.Errorf("question %d: %v", questionID, err)
+		}
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for questionID, timeLimit := range req.TimeLimits {
+			if err := tx.Model(&models.Question{}).Where("id = ? AND quiz_id = ?", questionID, quiz.ID).
+				Update("time_limit", timeLimit).Error; err != nil {
+				return err
 			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			// Create options
-			for _, optReq := range qReq.Options {
-				option := models.Option{
-					QuestionID: question.ID,
-					Text:       optReq.Text,
-					IsCorrect:  optReq.IsCorrect,
-					Order:      optReq.Order,
-				}
+	return s.GetQuizByID(ctx, quiz.ID, userID)
+}
 
-				if err := tx.Create(&option).Error; err != nil {
-					tx.Rollback()
-					return nil, err
-				}
+// ReorderQuestions sets the quiz's question order to the order of the given
+// IDs, which must be exactly the quiz's questions. Questions are updated in
+// place, so they keep their IDs.
+func (s *QuizService) ReorderQuestions(ctx context.Context, quizID uint, userID uint, req *ReorderQuestionsRequest) (*models.Quiz, error) {
+	quiz, err := s.GetQuizByID(ctx, quizID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	questionIDs := make([]uint, len(quiz.Questions))
+	for i, question := range quiz.Questions {
+		questionIDs[i] = question.ID
+	}
+	if err := validateReorder(req.QuestionIDs, questionIDs, "question", "quiz"); err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for order, questionID := range req.QuestionIDs {
+			if err := tx.Model(&models.Question{}).Where("id = ? AND quiz_id = ?", questionID, quiz.ID).
+				Update("order", order).Error; err != nil {
+				return err
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
+	return s.GetQuizByID(ctx, quiz.ID, userID)
+}
+
+// ReorderOptions sets a question's option order to the order of the given IDs,
+// which must be exactly the question's options. Options are updated in place,
+// so answers already given keep pointing at them.
+func (s *QuizService) ReorderOptions(ctx context.Context, quizID uint, questionID uint, userID uint, req *ReorderOptionsRequest) (*models.Quiz, error) {
+	quiz, err := s.GetQuizByID(ctx, quizID, userID)
+	if err != nil {
 		return nil, err
 	}
 
-	// Fetch the updated quiz with questions and options loaded
-	return s.GetQuizByID(quiz.ID, userID)
+	var question *models.Question
+	for i := range quiz.Questions {
+		if quiz.Questions[i].ID == questionID {
+			question = &quiz.Questions[i]
+			break
+		}
+	}
+	if question == nil {
+		return nil, fmt.Errorf("question %d does not belong to this quiz", questionID)
+	}
+
+	optionIDs := make([]uint, len(question.Options))
+	for i, option := range question.Options {
+		optionIDs[i] = option.ID
+	}
+	if err := validateReorder(req.OptionIDs, optionIDs, "option", "question"); err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for order, optionID := range req.OptionIDs {
+			if err := tx.Model(&models.Option{}).Where("id = ? AND question_id = ?", optionID, question.ID).
+				Update("order", order).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetQuizByID(ctx, quiz.ID, userID)
+}
+
+// validateReorder checks a requested order lists each of the current IDs
+// exactly once, naming the item and its parent in the error
+func validateReorder(requested []uint, current []uint, item string, parent string) error {
+	if len(requested) != len(current) {
+		return fmt.Errorf("expected all %d %s IDs of this %s", len(current), item, parent)
+	}
+	belongs := make(map[uint]bool, len(current))
+	for _, id := range current {
+		belongs[id] = true
+	}
+	seen := make(map[uint]bool, len(requested))
+	for _, id := range requested {
+		if !belongs[id] {
+			return fmt.Errorf("%s %d does not belong to this %s", item, id, parent)
+		}
+		if seen[id] {
+			return fmt.Errorf("%s %d is listed more than once", item, id)
+		}
+		seen[id] = true
+	}
+	return nil
 }
 
-func (s *QuizService) DeleteQuiz(quizID uint, userID uint) error {
+// validateGradeRubric requires labelled bands with strictly increasing
+// thresholds, starting at 0 and within 100, so every percentage gets a grade
+func validateGradeRubric(rubric []models.GradeBand) error {
+	if len(rubric) == 0 {
+		return errors.New("grade rubric must have at least one band")
+	}
+	if rubric[0].MinPercent != 0 {
+		return errors.New("the first grade band must start at 0 percent")
+	}
+
+	for i, band := range rubric {
+		if band.Label == "" {
+			return fmt.Errorf("grade band %d needs a label", i+1)
+		}
+		if band.MinPercent < 0 || band.MinPercent > 100 {
+			return fmt.Errorf("grade band %q threshold must be between 0 and 100", band.Label)
+		}
+		if i > 0 && band.MinPercent <= rubric[i-1].MinPercent {
+			return errors.New("grade band thresholds must be in increasing order")
+		}
+	}
+	return nil
+}
+
+// checkQuizLimit refuses adding quizzes that would take a user past the
+// maximum; quizzes in the trash don't count
+func (s *QuizService) checkQuizLimit(ctx context.Context, userID uint, adding int) error {
+	if s.maxQuizzesPerUser <= 0 {
+		return nil
+	}
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Quiz{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count+int64(adding) > int64(s.maxQuizzesPerUser) {
+		return fmt.Errorf("%w: at most %d quizzes per user", ErrQuizLimitReached, s.maxQuizzesPerUser)
+	}
+	return nil
+}
+
+// checkQuestionLimit refuses a quiz with more than the maximum questions
+func (s *QuizService) checkQuestionLimit(questionCount int) error {
+	if s.maxQuestionsPerQuiz > 0 && questionCount > s.maxQuestionsPerQuiz {
+		return fmt.Errorf("%w: at most %d questions per quiz", ErrQuestionLimitExceeded, s.maxQuestionsPerQuiz)
+	}
+	return nil
+}
+
+// questionTimeLimit returns the time limit a question is stored with: its own
+// when set, otherwise the quiz default, checked against the allowed bounds
+func questionTimeLimit(timeLimit int, quiz *models.Quiz) (int, error) {
+	if timeLimit == 0 {
+		timeLimit = defaultTimeLimit
+		if quiz.DefaultTimeLimit != nil {
+			timeLimit = *quiz.DefaultTimeLimit
+		}
+	}
+	if err := validateTimeLimit(timeLimit); err != nil {
+		return 0, err
+	}
+	return timeLimit, nil
+}
+
+// validateTimeLimit checks a question time limit against the allowed bounds
+func validateTimeLimit(timeLimit int) error {
+	if timeLimit < minTimeLimit || timeLimit > maxTimeLimit {
+		return fmt.Errorf("time limit must be between %d and %d seconds", minTimeLimit, maxTimeLimit)
+	}
+	return nil
+}
+
+func (s *QuizService) DeleteQuiz(ctx context.Context, quizID uint, userID uint) error {
 	// Check if quiz exists and belongs to user
-	_, err := s.GetQuizByID(quizID, userID)
+	_, err := s.GetQuizByID(ctx, quizID, userID)
 	if err != nil {
 		return err
 	}
 
-	return s.db.Delete(&models.Quiz{}, quizID).Error
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := deleteQuizTree(tx, quizID)
+		return err
+	})
+}
+
+// deleteQuizTree soft-deletes a quiz together with its questions and options,
+// and any of its games that haven't finished along with their players.
+// Finished games keep their players and answers so results stay available.
+// Everything is stamped with the same time, which is how RestoreQuiz finds
+// what was deleted with the quiz. It returns the number of quizzes deleted.
+func deleteQuizTree(tx *gorm.DB, quizID uint) (int64, error) {
+	now := time.Now()
+
+	questions := tx.Model(&models.Question{}).Select("id").Where("quiz_id = ?", quizID)
+	if err := tx.Model(&models.Option{}).Where("question_id IN (?)", questions).
+		Update("deleted_at", now).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&models.Question{}).Where("quiz_id = ?", quizID).
+		Update("deleted_at", now).Error; err != nil {
+		return 0, err
+	}
+
+	unfinished := tx.Model(&models.Game{}).Select("id").Where("quiz_id = ? AND status <> ?", quizID, "finished")
+	if err := tx.Model(&models.Player{}).Where("game_id IN (?)", unfinished).
+		Update("deleted_at", now).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&models.Game{}).Where("quiz_id = ? AND status <> ?", quizID, "finished").
+		Update("deleted_at", now).Error; err != nil {
+		return 0, err
+	}
+
+	result := tx.Model(&models.Quiz{}).Where("id = ?", quizID).Update("deleted_at", now)
+	return result.RowsAffected, result.Error
+}
+
+// GetDeletedQuizzes lists the user's soft-deleted quizzes, most recently
+// deleted first
+func (s *QuizService) GetDeletedQuizzes(ctx context.Context, userID uint) ([]models.Quiz, error) {
+	var quizzes []models.Quiz
+	err := s.db.WithContext(ctx).Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").
+		Find(&quizzes).Error
+	return quizzes, err
+}
+
+// RestoreQuiz undeletes one of the user's soft-deleted quizzes along with the
+// questions and options deleted with it. Games cancelled by the deletion stay
+// deleted.
+func (s *QuizService) RestoreQuiz(ctx context.Context, quizID uint, userID uint) (*models.Quiz, error) {
+	var quiz models.Quiz
+	if err := s.db.WithContext(ctx).Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", quizID, userID).
+		First(&quiz).Error; err != nil {
+		return nil, err
+	}
+	deletedAt := quiz.DeletedAt.Time
+
+	if err := s.checkQuizLimit(ctx, userID, 1); err != nil {
+		return nil, err
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		questions := tx.Unscoped().Model(&models.Question{}).Select("id").Where("quiz_id = ?", quiz.ID)
+		if err := tx.Unscoped().Model(&models.Option{}).
+			Where("question_id IN (?) AND deleted_at = ?", questions, deletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Question{}).
+			Where("quiz_id = ? AND deleted_at = ?", quiz.ID, deletedAt).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&quiz).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetQuizByID(ctx, quiz.ID, userID)
+}
+
+// PermanentlyDeleteQuiz removes one of the user's quizzes, deleted or not, for
+// good, together with its questions, options and every game played with it
+func (s *QuizService) PermanentlyDeleteQuiz(ctx context.Context, quizID uint, userID uint) error {
+	var quiz models.Quiz
+	if err := s.db.WithContext(ctx).Unscoped().Where("id = ? AND user_id = ?", quizID, userID).First(&quiz).Error; err != nil {
+		return err
+	}
+
+	// Soft-deleted rows are removed too, so every query is unscoped
+	games := s.db.WithContext(ctx).Unscoped().Model(&models.Game{}).Select("id").Where("quiz_id = ?", quiz.ID)
+	questions := s.db.WithContext(ctx).Unscoped().Model(&models.Question{}).Select("id").Where("quiz_id = ?", quiz.ID)
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("game_id IN (?)", games).Delete(&models.GameAnswer{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("game_id IN (?)", games).Delete(&models.Player{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("quiz_id = ?", quiz.ID).Delete(&models.Game{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("question_id IN (?)", questions).Delete(&models.Option{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("quiz_id = ?", quiz.ID).Delete(&models.Question{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&quiz).Error
+	})
+}
+
+// AdminListQuizzes lists every user's quizzes, newest first, with their authors
+func (s *QuizService) AdminListQuizzes(ctx context.Context) ([]models.Quiz, error) {
+	var quizzes []models.Quiz
+	err := s.db.WithContext(ctx).Preload("User", omitPassword).Order("created_at DESC").Find(&quizzes).Error
+	return quizzes, err
+}
+
+// AdminDeleteQuiz deletes any user's quiz, bypassing the ownership check
+func (s *QuizService) AdminDeleteQuiz(ctx context.Context, quizID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		deleted, err := deleteQuizTree(tx, quizID)
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
 }
@@ -0,0 +1,1085 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"openquiz/models"
+)
+
+func TestUpdateTimeLimitsNeedsExactlyOneForm(t *testing.T) {
+	s := &QuizService{}
+	limit := 30
+
+	for name, req := range map[string]*UpdateTimeLimitsRequest{
+		"neither": {},
+		"both":    {TimeLimit: &limit, TimeLimits: map[uint]int{1: 30}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.UpdateTimeLimits(context.Background(), 1, 1, req); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestUpdateTimeLimitsAppliesToEveryQuestion(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(3))
+	s := NewQuizService(db, 0, 0)
+
+	limit := 45
+	updated, err := s.UpdateTimeLimits(context.Background(), quiz.ID, user.ID, &UpdateTimeLimitsRequest{TimeLimit: &limit})
+	if err != nil {
+		t.Fatalf("UpdateTimeLimits: %v", err)
+	}
+	for _, question := range updated.Questions {
+		if question.TimeLimit != limit {
+			t.Errorf("question %d time limit = %d, want %d", question.ID, question.TimeLimit, limit)
+		}
+	}
+}
+
+func TestUpdateTimeLimitsPerQuestion(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(3))
+	s := NewQuizService(db, 0, 0)
+
+	first, second, third := quiz.Questions[0], quiz.Questions[1], quiz.Questions[2]
+	updated, err := s.UpdateTimeLimits(context.Background(), quiz.ID, user.ID, &UpdateTimeLimitsRequest{
+		TimeLimits: map[uint]int{first.ID: 10, second.ID: 60},
+	})
+	if err != nil {
+		t.Fatalf("UpdateTimeLimits: %v", err)
+	}
+
+	want := map[uint]int{first.ID: 10, second.ID: 60, third.ID: third.TimeLimit}
+	for _, question := range updated.Questions {
+		if question.TimeLimit != want[question.ID] {
+			t.Errorf("question %d time limit = %d, want %d", question.ID, question.TimeLimit, want[question.ID])
+		}
+	}
+}
+
+func TestUpdateTimeLimitsRejectsInvalidLimits(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(2))
+	other := createTestQuiz(t, db, user.ID, testQuizRequest(1))
+	s := NewQuizService(db, 0, 0)
+
+	tooShort := 1
+	for name, req := range map[string]*UpdateTimeLimitsRequest{
+		"limit out of range":         {TimeLimit: &tooShort},
+		"per-question out of range":  {TimeLimits: map[uint]int{quiz.Questions[0].ID: 1000}},
+		"question from another quiz": {TimeLimits: map[uint]int{other.Questions[0].ID: 30}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.UpdateTimeLimits(context.Background(), quiz.ID, user.ID, req); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	unchanged, err := s.GetQuizByID(context.Background(), quiz.ID, user.ID)
+	if err != nil {
+		t.Fatalf("GetQuizByID: %v", err)
+	}
+	for _, question := range unchanged.Questions {
+		if question.TimeLimit != 20 {
+			t.Errorf("question %d time limit changed to %d", question.ID, question.TimeLimit)
+		}
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// questionTexts lists questions' text in the order given
+func questionTexts(questions []models.Question) []string {
+	texts := make([]string, len(questions))
+	for i, question := range questions {
+		texts[i] = question.Text
+	}
+	return texts
+}
+
+func TestNormalizeQuestionOrderRenumbersGapsAndDuplicates(t *testing.T) {
+	questions := []CreateQuestionRequest{
+		{Text: "C", Order: intPtr(30)},
+		{Text: "A", Order: intPtr(-5)},
+		{Text: "B1", Order: intPtr(10)},
+		{Text: "B2", Order: intPtr(10)},
+	}
+
+	normalized := normalizeQuestionOrder(questions)
+
+	want := []string{"A", "B1", "B2", "C"}
+	for i, question := range normalized {
+		if question.Text != want[i] || *question.Order != i {
+			t.Errorf("position %d = %s with order %d, want %s with order %d", i, question.Text, *question.Order, want[i], i)
+		}
+	}
+	if *questions[0].Order != 30 {
+		t.Error("normalizing changed the request's own orders")
+	}
+}
+
+func TestQuestionOrderMatchesGameIndex(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+
+	req := testQuizRequest(3)
+	req.Questions[0].Order = intPtr(7)
+	req.Questions[1].Order = intPtr(2)
+	req.Questions[2].Order = intPtr(7)
+	quiz := createTestQuiz(t, db, user.ID, req)
+
+	assertOrder := func(quizID uint, want ...string) {
+		t.Helper()
+		stored, err := s.GetQuizByID(ctx, quizID, user.ID)
+		if err != nil {
+			t.Fatalf("GetQuizByID: %v", err)
+		}
+		if got := questionTexts(stored.Questions); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("questions = %v, want %v", got, want)
+		}
+		for i, question := range stored.Questions {
+			if question.Order != i {
+				t.Errorf("question %q has order %d at index %d", question.Text, question.Order, i)
+			}
+		}
+	}
+	assertOrder(quiz.ID, "Question 2", "Question 1", "Question 3")
+
+	// Editing with gaps renumbers the same way
+	update := &UpdateQuizRequest{Title: quiz.Title, Questions: testQuizRequest(3).Questions}
+	for i, question := range quiz.Questions {
+		update.Questions[i].ID = question.ID
+		update.Questions[i].Text = question.Text
+	}
+	update.Questions[0].Order = intPtr(100)
+	update.Questions[1].Order = intPtr(50)
+	update.Questions[2].Order = intPtr(-1)
+	if _, err := s.UpdateQuiz(ctx, quiz.ID, user.ID, update); err != nil {
+		t.Fatalf("UpdateQuiz: %v", err)
+	}
+	assertOrder(quiz.ID, quiz.Questions[2].Text, quiz.Questions[1].Text, quiz.Questions[0].Text)
+}
+
+func TestNormalizeOptionOrderKeepsDuplicatesInSubmittedOrder(t *testing.T) {
+	options := []CreateOptionRequest{
+		{Text: "B", Order: intPtr(1)},
+		{Text: "C", Order: intPtr(1)},
+		{Text: "A", Order: intPtr(0)},
+		{Text: "D", Order: intPtr(1)},
+	}
+
+	normalized := normalizeOptionOrder(options)
+
+	want := []string{"A", "B", "C", "D"}
+	for i, option := range normalized {
+		if option.Text != want[i] || *option.Order != i {
+			t.Errorf("position %d = %s with order %d, want %s with order %d", i, option.Text, *option.Order, want[i], i)
+		}
+	}
+}
+
+func TestDuplicateOptionOrdersAreStoredNormalized(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+
+	req := testQuizRequest(1)
+	for i := range req.Questions[0].Options {
+		req.Questions[0].Options[i].Order = intPtr(3)
+	}
+	quiz := createTestQuiz(t, db, user.ID, req)
+
+	want := []string{"Right", "Wrong", "Also wrong"}
+	for i, option := range quiz.Questions[0].Options {
+		if option.Text != want[i] || option.Order != i {
+			t.Errorf("option %d = %s with order %d, want %s with order %d", i, option.Text, option.Order, want[i], i)
+		}
+	}
+}
+
+func TestOmittedOrdersFollowListPosition(t *testing.T) {
+	normalized := normalizeQuestionOrder([]CreateQuestionRequest{{Text: "A"}, {Text: "B"}, {Text: "C"}})
+	for i, question := range normalized {
+		if question.Order == nil || *question.Order != i {
+			t.Errorf("question %s order = %v, want %d", question.Text, question.Order, i)
+		}
+	}
+
+	// Explicit orders are honored; omitted ones sort at their list position
+	normalized = normalizeQuestionOrder([]CreateQuestionRequest{{Text: "A"}, {Text: "B"}, {Text: "C", Order: intPtr(0)}})
+	want := []string{"A", "C", "B"}
+	for i, question := range normalized {
+		if question.Text != want[i] || *question.Order != i {
+			t.Errorf("position %d = %s with order %d, want %s with order %d", i, question.Text, *question.Order, want[i], i)
+		}
+	}
+
+	options := normalizeOptionOrder([]CreateOptionRequest{{Text: "A"}, {Text: "B", Order: intPtr(5)}, {Text: "C"}})
+	wantOptions := []string{"A", "C", "B"}
+	for i, option := range options {
+		if option.Text != wantOptions[i] || *option.Order != i {
+			t.Errorf("option %d = %s with order %d, want %s with order %d", i, option.Text, *option.Order, wantOptions[i], i)
+		}
+	}
+}
+
+func TestCreateQuizWithoutOrders(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+
+	req := testQuizRequest(3)
+	req.Questions[1].Order = intPtr(10)
+	req.Questions[2].Options[0].Order = intPtr(5)
+	quiz := createTestQuiz(t, db, user.ID, req)
+
+	if got := questionTexts(quiz.Questions); strings.Join(got, ",") != "Question 1,Question 3,Question 2" {
+		t.Errorf("questions = %v, want the explicit order honored", got)
+	}
+	for i, question := range quiz.Questions {
+		if question.Order != i {
+			t.Errorf("question %q order = %d, want %d", question.Text, question.Order, i)
+		}
+		for j, option := range question.Options {
+			if option.Order != j {
+				t.Errorf("question %q option %q order = %d, want %d", question.Text, option.Text, option.Order, j)
+			}
+		}
+	}
+	if got := quiz.Questions[1].Options[2].Text; got != "Right" {
+		t.Errorf("last option of Question 3 = %q, want the explicitly ordered Right", got)
+	}
+}
+
+func TestValidateReorder(t *testing.T) {
+	current := []uint{4, 5, 6}
+
+	tests := []struct {
+		name      string
+		requested []uint
+		wantErr   string
+	}{
+		{"new order", []uint{6, 4, 5}, ""},
+		{"same order", []uint{4, 5, 6}, ""},
+		{"missing option", []uint{6, 4}, "expected all 3 option IDs of this question"},
+		{"extra option", []uint{6, 4, 5, 7}, "expected all 3 option IDs of this question"},
+		{"option of another question", []uint{6, 4, 9}, "option 9 does not belong to this question"},
+		{"repeated option", []uint{6, 4, 4}, "option 4 is listed more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateReorder(tt.requested, current, "option", "question")
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("validateReorder() = %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("validateReorder() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReorderQuestionsKeepsQuestionRows(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(3))
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+
+	ids := []uint{quiz.Questions[0].ID, quiz.Questions[1].ID, quiz.Questions[2].ID}
+	reordered := []uint{ids[2], ids[0], ids[1]}
+
+	otherQuiz := createTestQuiz(t, db, user.ID, testQuizRequest(1))
+	for name, requested := range map[string][]uint{
+		"missing question":        {ids[2], ids[0]},
+		"another quiz's question": {ids[2], ids[0], otherQuiz.Questions[0].ID},
+		"repeated question":       {ids[2], ids[0], ids[0]},
+		"extra question":          {ids[2], ids[0], ids[1], otherQuiz.Questions[0].ID},
+	} {
+		if _, err := s.ReorderQuestions(ctx, quiz.ID, user.ID, &ReorderQuestionsRequest{QuestionIDs: requested}); err == nil {
+			t.Errorf("reordering with a %s succeeded", name)
+		}
+	}
+	if _, err := s.ReorderQuestions(ctx, quiz.ID, createTestUser(t, db).ID, &ReorderQuestionsRequest{QuestionIDs: reordered}); err == nil {
+		t.Error("reordering someone else's quiz succeeded")
+	}
+
+	updated, err := s.ReorderQuestions(ctx, quiz.ID, user.ID, &ReorderQuestionsRequest{QuestionIDs: reordered})
+	if err != nil {
+		t.Fatalf("ReorderQuestions: %v", err)
+	}
+	for i, question := range updated.Questions {
+		if question.ID != reordered[i] || question.Order != i {
+			t.Errorf("question %d = ID %d with order %d, want ID %d with order %d", i, question.ID, question.Order, reordered[i], i)
+		}
+		if len(question.Options) != 3 {
+			t.Errorf("question %d has %d options after the reorder, want 3", question.ID, len(question.Options))
+		}
+	}
+
+	var count int64
+	if err := db.Model(&models.Question{}).Where("quiz_id = ?", quiz.ID).Count(&count).Error; err != nil {
+		t.Fatalf("count questions: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("quiz has %d question rows, want the original 3", count)
+	}
+}
+
+func TestReorderOptionsKeepsOptionRows(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(2))
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+
+	question, other := quiz.Questions[0], quiz.Questions[1]
+	reversed := []uint{question.Options[2].ID, question.Options[1].ID, question.Options[0].ID}
+
+	if _, err := s.ReorderOptions(ctx, quiz.ID, question.ID, user.ID, &ReorderOptionsRequest{
+		OptionIDs: []uint{other.Options[0].ID, question.Options[1].ID, question.Options[2].ID},
+	}); err == nil {
+		t.Error("reordering with another question's option succeeded")
+	}
+	if _, err := s.ReorderOptions(ctx, quiz.ID, question.ID, createTestUser(t, db).ID, &ReorderOptionsRequest{OptionIDs: reversed}); err == nil {
+		t.Error("reordering someone else's quiz succeeded")
+	}
+
+	updated, err := s.ReorderOptions(ctx, quiz.ID, question.ID, user.ID, &ReorderOptionsRequest{OptionIDs: reversed})
+	if err != nil {
+		t.Fatalf("ReorderOptions: %v", err)
+	}
+	for i, option := range updated.Questions[0].Options {
+		if option.ID != reversed[i] || option.Order != i {
+			t.Errorf("option %d = ID %d with order %d, want ID %d with order %d", i, option.ID, option.Order, reversed[i], i)
+		}
+	}
+	for i, option := range updated.Questions[1].Options {
+		if option.ID != other.Options[i].ID || option.Order != i {
+			t.Errorf("other question's option %d changed to ID %d with order %d", i, option.ID, option.Order)
+		}
+	}
+
+	var count int64
+	if err := db.Model(&models.Option{}).Where("question_id = ?", question.ID).Count(&count).Error; err != nil {
+		t.Fatalf("count options: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("question has %d option rows, want the original 3", count)
+	}
+}
+
+func TestValidateOptionCount(t *testing.T) {
+	if err := validateOptionCount(3, 0); err != nil {
+		t.Errorf("no fixed count: %v", err)
+	}
+	if err := validateOptionCount(4, 4); err != nil {
+		t.Errorf("matching fixed count: %v", err)
+	}
+	if err := validateOptionCount(3, 4); err == nil {
+		t.Error("a question with 3 options passed a fixed count of 4")
+	}
+}
+
+func TestFixedOptionCountIsEnforced(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+
+	// Every test question has 3 options
+	req := testQuizRequest(2)
+	req.FixedOptionCount = 4
+	if _, err := s.CreateQuiz(ctx, user.ID, req); err == nil {
+		t.Error("created a quiz whose questions don't have the fixed 4 options")
+	}
+
+	req.FixedOptionCount = 3
+	quiz, err := s.CreateQuiz(ctx, user.ID, req)
+	if err != nil {
+		t.Fatalf("CreateQuiz with matching options: %v", err)
+	}
+
+	// Adding a question with the wrong count is rejected on update too
+	update := &UpdateQuizRequest{Questions: testQuizRequest(2).Questions}
+	update.Questions[1].Options = update.Questions[1].Options[:2]
+	if _, err := s.UpdateQuiz(ctx, quiz.ID, user.ID, update); err == nil {
+		t.Error("updated a fixed-count quiz with a 2-option question")
+	}
+
+	// So is tightening the count past what the questions have
+	four := 4
+	if _, err := s.UpdateQuiz(ctx, quiz.ID, user.ID, &UpdateQuizRequest{FixedOptionCount: &four}); err == nil {
+		t.Error("set a fixed count of 4 on a quiz of 3-option questions")
+	}
+
+	// Removing the requirement allows any count again
+	zero := 0
+	update.FixedOptionCount = &zero
+	if _, err := s.UpdateQuiz(ctx, quiz.ID, user.ID, update); err != nil {
+		t.Errorf("update after removing the fixed count: %v", err)
+	}
+}
+
+func TestValidateGradeRubric(t *testing.T) {
+	tests := []struct {
+		name    string
+		rubric  []models.GradeBand
+		wantErr string
+	}{
+		{"single band", []models.GradeBand{{MinPercent: 0, Label: "Pass"}}, ""},
+		{"several bands", []models.GradeBand{{MinPercent: 0, Label: "F"}, {MinPercent: 50, Label: "C"}, {MinPercent: 75, Label: "B"}, {MinPercent: 90, Label: "A"}}, ""},
+		{"empty", nil, "grade rubric must have at least one band"},
+		{"first band above zero", []models.GradeBand{{MinPercent: 10, Label: "F"}}, "the first grade band must start at 0 percent"},
+		{"missing label", []models.GradeBand{{MinPercent: 0, Label: "F"}, {MinPercent: 50, Label: ""}}, "grade band 2 needs a label"},
+		{"threshold above 100", []models.GradeBand{{MinPercent: 0, Label: "F"}, {MinPercent: 120, Label: "A"}}, `grade band "A" threshold must be between 0 and 100`},
+		{"repeated threshold", []models.GradeBand{{MinPercent: 0, Label: "F"}, {MinPercent: 50, Label: "C"}, {MinPercent: 50, Label: "B"}}, "grade band thresholds must be in increasing order"},
+		{"decreasing threshold", []models.GradeBand{{MinPercent: 0, Label: "F"}, {MinPercent: 75, Label: "B"}, {MinPercent: 50, Label: "C"}}, "grade band thresholds must be in increasing order"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateGradeRubric(tt.rubric)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("validateGradeRubric() = %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("validateGradeRubric() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCorrectOptions(t *testing.T) {
+	none := []CreateOptionRequest{{Text: "Tea"}, {Text: "Coffee"}}
+	one := []CreateOptionRequest{{Text: "Tea", IsCorrect: true}, {Text: "Coffee"}}
+	two := []CreateOptionRequest{{Text: "Tea", IsCorrect: true}, {Text: "Coffee", IsCorrect: true}}
+
+	tests := []struct {
+		name         string
+		questionType string
+		options      []CreateOptionRequest
+		wantErr      bool
+	}{
+		{"poll without a correct answer", models.QuestionTypePoll, none, false},
+		{"poll with a correct answer", models.QuestionTypePoll, one, true},
+		{"multiple choice with one correct answer", models.QuestionTypeMultipleChoice, one, false},
+		{"multiple choice without a correct answer", models.QuestionTypeMultipleChoice, none, true},
+		{"multiple choice with two correct answers", models.QuestionTypeMultipleChoice, two, true},
+		{"untyped question needs a correct answer", "", none, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateCorrectOptions(tt.questionType, tt.options); (err != nil) != tt.wantErr {
+				t.Errorf("validateCorrectOptions() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// updateRequestFor describes a quiz's questions as an update would send them,
+// with their IDs so they are edited in place
+func updateRequestFor(quiz *models.Quiz) *UpdateQuizRequest {
+	req := &UpdateQuizRequest{}
+	for _, question := range quiz.Questions {
+		questionReq := CreateQuestionRequest{ID: question.ID, Text: question.Text, TimeLimit: question.TimeLimit}
+		for _, option := range question.Options {
+			questionReq.Options = append(questionReq.Options, CreateOptionRequest{ID: option.ID, Text: option.Text, IsCorrect: option.IsCorrect})
+		}
+		req.Questions = append(req.Questions, questionReq)
+	}
+	return req
+}
+
+func TestUpdateQuizKeepsQuestionIDs(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(3))
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+	edited, kept, removed := quiz.Questions[0], quiz.Questions[1], quiz.Questions[2]
+
+	// Edit the first question's text and an option, drop the last and add one
+	req := updateRequestFor(quiz)
+	req.Questions[0].Text = "Question 1, reworded"
+	req.Questions[0].Options[1].Text = "Still wrong"
+	req.Questions = append(req.Questions[:2], CreateQuestionRequest{
+		Text:      "Brand new",
+		TimeLimit: 20,
+		Options:   []CreateOptionRequest{{Text: "Yes", IsCorrect: true}, {Text: "No"}},
+	})
+
+	updated, err := s.UpdateQuiz(ctx, quiz.ID, user.ID, req)
+	if err != nil {
+		t.Fatalf("UpdateQuiz: %v", err)
+	}
+	if got := questionTexts(updated.Questions); fmt.Sprint(got) != "[Question 1, reworded Question 2 Brand new]" {
+		t.Fatalf("questions = %v", got)
+	}
+
+	if updated.Questions[0].ID != edited.ID {
+		t.Errorf("edited question got ID %d, want %d", updated.Questions[0].ID, edited.ID)
+	}
+	for i, option := range updated.Questions[0].Options {
+		if option.ID != edited.Options[i].ID {
+			t.Errorf("edited question's option %d got ID %d, want %d", i, option.ID, edited.Options[i].ID)
+		}
+	}
+	if text := updated.Questions[0].Options[1].Text; text != "Still wrong" {
+		t.Errorf("edited option text = %q, want %q", text, "Still wrong")
+	}
+	if updated.Questions[1].ID != kept.ID {
+		t.Errorf("untouched question got ID %d, want %d", updated.Questions[1].ID, kept.ID)
+	}
+	if added := updated.Questions[2]; added.ID == 0 || added.ID == removed.ID || len(added.Options) != 2 {
+		t.Errorf("added question = %+v, want a new question with 2 options", added)
+	}
+
+	var remaining int64
+	db.Model(&models.Question{}).Where("id = ?", removed.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Error("the removed question is still part of the quiz")
+	}
+}
+
+func TestDeleteListTrashAndRestore(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(2))
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+
+	if err := s.DeleteQuiz(ctx, quiz.ID, user.ID); err != nil {
+		t.Fatalf("DeleteQuiz: %v", err)
+	}
+	if _, err := s.GetQuizByID(ctx, quiz.ID, user.ID); err == nil {
+		t.Error("the deleted quiz can still be fetched")
+	}
+
+	trash, err := s.GetDeletedQuizzes(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetDeletedQuizzes: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != quiz.ID {
+		t.Fatalf("trash = %+v, want the deleted quiz", trash)
+	}
+	stranger := createTestUser(t, db)
+	if others, _ := s.GetDeletedQuizzes(ctx, stranger.ID); len(others) != 0 {
+		t.Errorf("another user's trash lists %d quizzes", len(others))
+	}
+	if _, err := s.RestoreQuiz(ctx, quiz.ID, stranger.ID); err == nil {
+		t.Error("another user restored the quiz")
+	}
+
+	restored, err := s.RestoreQuiz(ctx, quiz.ID, user.ID)
+	if err != nil {
+		t.Fatalf("RestoreQuiz: %v", err)
+	}
+	if len(restored.Questions) != 2 || len(restored.Questions[0].Options) != 3 {
+		t.Errorf("restored quiz has %d questions, want 2 with their options", len(restored.Questions))
+	}
+	if trash, _ := s.GetDeletedQuizzes(ctx, user.ID); len(trash) != 0 {
+		t.Errorf("trash still lists %d quizzes after the restore", len(trash))
+	}
+	if _, err := s.RestoreQuiz(ctx, quiz.ID, user.ID); err == nil {
+		t.Error("restoring a quiz that isn't deleted succeeded")
+	}
+}
+
+func TestPermanentlyDeleteQuiz(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(2))
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+
+	if err := s.DeleteQuiz(ctx, quiz.ID, user.ID); err != nil {
+		t.Fatalf("DeleteQuiz: %v", err)
+	}
+	if err := s.PermanentlyDeleteQuiz(ctx, quiz.ID, createTestUser(t, db).ID); err == nil {
+		t.Error("another user permanently deleted the quiz")
+	}
+	if err := s.PermanentlyDeleteQuiz(ctx, quiz.ID, user.ID); err != nil {
+		t.Fatalf("PermanentlyDeleteQuiz: %v", err)
+	}
+
+	var quizzes, questions int64
+	db.Unscoped().Model(&models.Quiz{}).Where("id = ?", quiz.ID).Count(&quizzes)
+	db.Unscoped().Model(&models.Question{}).Where("quiz_id = ?", quiz.ID).Count(&questions)
+	if quizzes != 0 || questions != 0 {
+		t.Errorf("%d quiz and %d question rows remain, want none", quizzes, questions)
+	}
+	if _, err := s.RestoreQuiz(ctx, quiz.ID, user.ID); err == nil {
+		t.Error("a permanently deleted quiz was restored")
+	}
+}
+
+func TestDeleteQuizCascadesButKeepsFinishedGames(t *testing.T) {
+	games := newTestGameService(t)
+	s := NewQuizService(games.db, 0, 0)
+	ctx := context.Background()
+
+	// One game played to the end with an answer, and one still in its lobby
+	user, finished, players := startTestGame(t, games, 2, "Ada")
+	question := finished.Quiz.Questions[0]
+	if err := games.SubmitAnswer(ctx, finished.Pin, players[0].ID, &SubmitAnswerRequest{
+		PlayerID:   players[0].ID,
+		QuestionID: question.ID,
+		OptionID:   question.Options[0].ID,
+	}, nil); err != nil {
+		t.Fatalf("SubmitAnswer: %v", err)
+	}
+	if err := games.EndGame(ctx, finished.Pin, user.ID, nil); err != nil {
+		t.Fatalf("EndGame: %v", err)
+	}
+	waiting, err := games.StartGame(ctx, user.ID, &StartGameRequest{QuizID: finished.QuizID})
+	if err != nil {
+		t.Fatalf("StartGame: %v", err)
+	}
+	waitingPlayer, _, err := games.JoinGame(ctx, &JoinGameRequest{Pin: waiting.Pin, Name: "Grace"})
+	if err != nil {
+		t.Fatalf("JoinGame: %v", err)
+	}
+
+	if err := s.DeleteQuiz(ctx, finished.QuizID, user.ID); err != nil {
+		t.Fatalf("DeleteQuiz: %v", err)
+	}
+
+	count := func(model interface{}, query string, args ...interface{}) int64 {
+		t.Helper()
+		var n int64
+		if err := games.db.Model(model).Where(query, args...).Count(&n).Error; err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		return n
+	}
+	questionIDs := []uint{finished.Quiz.Questions[0].ID, finished.Quiz.Questions[1].ID}
+
+	// The quiz's content and unfinished games go with it
+	if n := count(&models.Question{}, "quiz_id = ?", finished.QuizID); n != 0 {
+		t.Errorf("%d questions remain", n)
+	}
+	if n := count(&models.Option{}, "question_id IN ?", questionIDs); n != 0 {
+		t.Errorf("%d options remain", n)
+	}
+	if n := count(&models.Game{}, "id = ?", waiting.ID); n != 0 {
+		t.Error("the unfinished game remains")
+	}
+	if n := count(&models.Player{}, "id = ?", waitingPlayer.ID); n != 0 {
+		t.Error("the unfinished game's player remains")
+	}
+
+	// The finished game keeps its history
+	if n := count(&models.Game{}, "id = ?", finished.ID); n != 1 {
+		t.Error("the finished game was deleted")
+	}
+	if n := count(&models.Player{}, "game_id = ?", finished.ID); n != 1 {
+		t.Errorf("the finished game has %d players left, want 1", n)
+	}
+	if n := count(&models.GameAnswer{}, "game_id = ?", finished.ID); n != 1 {
+		t.Errorf("the finished game has %d answers left, want 1", n)
+	}
+}
+
+func TestValidateDistinctOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []string
+		wantErr string
+	}{
+		{"distinct", []string{"Paris", "Lyon", "Nice"}, ""},
+		{"differing only inside the text", []string{"New York", "NewYork"}, ""},
+		{"similar but distinct", []string{"Paris", "Paris, Texas"}, ""},
+		{"exact duplicate", []string{"Paris", "Lyon", "Paris"}, `question "Capital of France?" has more than one option "Paris"`},
+		{"duplicate in another case", []string{"Paris", "PARIS"}, `question "Capital of France?" has more than one option "PARIS"`},
+		{"duplicate with surrounding spaces", []string{"Paris", "  paris "}, `question "Capital of France?" has more than one option "paris"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := make([]CreateOptionRequest, len(tt.options))
+			for i, text := range tt.options {
+				options[i] = CreateOptionRequest{Text: text}
+			}
+			err := validateDistinctOptions("Capital of France?", options)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("validateDistinctOptions() = %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("validateDistinctOptions() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateQuizRejectsDuplicateOptions(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	s := NewQuizService(db, 0, 0)
+
+	req := testQuizRequest(2)
+	req.Questions[1].Options[2].Text = " right "
+	if _, err := s.CreateQuiz(context.Background(), user.ID, req); err == nil || !strings.Contains(err.Error(), "Question 2") {
+		t.Errorf("CreateQuiz() = %v, want an error naming Question 2", err)
+	}
+
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(1))
+	update := updateRequestFor(quiz)
+	update.Questions[0].Options[1].Text = "RIGHT"
+	if _, err := s.UpdateQuiz(context.Background(), quiz.ID, user.ID, update); err == nil || !strings.Contains(err.Error(), "Question 1") {
+		t.Errorf("UpdateQuiz() = %v, want an error naming Question 1", err)
+	}
+}
+
+func TestQuestionTimeLimit(t *testing.T) {
+	withDefault := &models.Quiz{DefaultTimeLimit: intPtr(45)}
+
+	tests := []struct {
+		name      string
+		timeLimit int
+		quiz      *models.Quiz
+		want      int
+		wantErr   bool
+	}{
+		{"own limit", 20, &models.Quiz{}, 20, false},
+		{"own limit beats the quiz default", 20, withDefault, 20, false},
+		{"quiz default", 0, withDefault, 45, false},
+		{"server default", 0, &models.Quiz{}, defaultTimeLimit, false},
+		{"lower bound", minTimeLimit, &models.Quiz{}, minTimeLimit, false},
+		{"upper bound", maxTimeLimit, &models.Quiz{}, maxTimeLimit, false},
+		{"too short", minTimeLimit - 1, &models.Quiz{}, 0, true},
+		{"too long", maxTimeLimit + 1, &models.Quiz{}, 0, true},
+		{"negative", -10, &models.Quiz{}, 0, true},
+		{"out of bounds quiz default", 0, &models.Quiz{DefaultTimeLimit: intPtr(maxTimeLimit + 1)}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := questionTimeLimit(tt.timeLimit, tt.quiz)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("questionTimeLimit(%d) = %d, %v; want %d, error %v", tt.timeLimit, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuizDefaultTimeLimitAppliesToQuestionsWithoutOne(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+
+	req := testQuizRequest(2)
+	req.DefaultTimeLimit = intPtr(60)
+	req.Questions[0].TimeLimit = 0
+	quiz, err := s.CreateQuiz(ctx, user.ID, req)
+	if err != nil {
+		t.Fatalf("CreateQuiz: %v", err)
+	}
+	if got := []int{quiz.Questions[0].TimeLimit, quiz.Questions[1].TimeLimit}; fmt.Sprint(got) != "[60 20]" {
+		t.Errorf("time limits = %v, want [60 20]", got)
+	}
+
+	req = testQuizRequest(1)
+	req.DefaultTimeLimit = intPtr(1)
+	if _, err := s.CreateQuiz(ctx, user.ID, req); err == nil {
+		t.Error("a quiz default below the minimum was accepted")
+	}
+	req = testQuizRequest(1)
+	req.Questions[0].TimeLimit = maxTimeLimit + 1
+	if _, err := s.CreateQuiz(ctx, user.ID, req); err == nil {
+		t.Error("a question time limit above the maximum was accepted")
+	}
+}
+
+func TestQuizListCounts(t *testing.T) {
+	games := newTestGameService(t)
+	s := NewQuizService(games.db, 0, 0)
+	ctx := context.Background()
+	user := createTestUser(t, games.db)
+
+	req := testQuizRequest(3)
+	req.Questions[2].TimeLimit = 45
+	played := createTestQuiz(t, games.db, user.ID, req)
+	unplayed := createTestQuiz(t, games.db, user.ID, testQuizRequest(1))
+
+	// Two games started and one left in its lobby, which doesn't count
+	for i := 0; i < 3; i++ {
+		game, err := games.StartGame(ctx, user.ID, &StartGameRequest{QuizID: played.ID})
+		if err != nil {
+			t.Fatalf("StartGame: %v", err)
+		}
+		if i == 2 {
+			continue
+		}
+		if _, err := games.StartQuiz(ctx, game.Pin, user.ID, nil); err != nil {
+			t.Fatalf("StartQuiz: %v", err)
+		}
+	}
+
+	items, err := s.GetUserQuizzes(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserQuizzes: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d quizzes, want 2", len(items))
+	}
+
+	want := map[uint][3]int{
+		played.ID:   {3, 85, 2},
+		unplayed.ID: {1, 20, 0},
+	}
+	for _, item := range items {
+		got := [3]int{item.QuestionCount, item.EstimatedDuration, item.TimesPlayed}
+		if got != want[item.ID] {
+			t.Errorf("quiz %q [questions duration played] = %v, want %v", item.Title, got, want[item.ID])
+		}
+	}
+}
+
+func TestQuizCountLimit(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	s := NewQuizService(db, 2, 0)
+	ctx := context.Background()
+
+	var first *models.Quiz
+	for i := 0; i < 2; i++ {
+		quiz, err := s.CreateQuiz(ctx, user.ID, testQuizRequest(1))
+		if err != nil {
+			t.Fatalf("CreateQuiz(%d): %v", i, err)
+		}
+		if first == nil {
+			first = quiz
+		}
+	}
+	if _, err := s.CreateQuiz(ctx, user.ID, testQuizRequest(1)); !errors.Is(err, ErrQuizLimitReached) {
+		t.Fatalf("CreateQuiz() past the limit = %v, want ErrQuizLimitReached", err)
+	}
+
+	// A quiz in the trash frees its slot until it is restored
+	if err := s.DeleteQuiz(ctx, first.ID, user.ID); err != nil {
+		t.Fatalf("DeleteQuiz: %v", err)
+	}
+	if _, err := s.CreateQuiz(ctx, user.ID, testQuizRequest(1)); err != nil {
+		t.Fatalf("CreateQuiz() after trashing one: %v", err)
+	}
+	if _, err := s.RestoreQuiz(ctx, first.ID, user.ID); !errors.Is(err, ErrQuizLimitReached) {
+		t.Errorf("RestoreQuiz() past the limit = %v, want ErrQuizLimitReached", err)
+	}
+
+	// The limit is per user
+	if _, err := s.CreateQuiz(ctx, createTestUser(t, db).ID, testQuizRequest(1)); err != nil {
+		t.Errorf("CreateQuiz() for another user: %v", err)
+	}
+}
+
+func TestQuestionCountLimit(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	s := NewQuizService(db, 0, 3)
+	ctx := context.Background()
+
+	if _, err := s.CreateQuiz(ctx, user.ID, testQuizRequest(4)); !errors.Is(err, ErrQuestionLimitExceeded) {
+		t.Fatalf("CreateQuiz() with too many questions = %v, want ErrQuestionLimitExceeded", err)
+	}
+	quiz, err := s.CreateQuiz(ctx, user.ID, testQuizRequest(3))
+	if err != nil {
+		t.Fatalf("CreateQuiz() at the limit: %v", err)
+	}
+
+	update := updateRequestFor(quiz)
+	update.Questions = append(update.Questions, testQuizRequest(1).Questions[0])
+	if _, err := s.UpdateQuiz(ctx, quiz.ID, user.ID, update); !errors.Is(err, ErrQuestionLimitExceeded) {
+		t.Errorf("UpdateQuiz() past the limit = %v, want ErrQuestionLimitExceeded", err)
+	}
+	got, err := s.GetQuizByID(ctx, quiz.ID, user.ID)
+	if err != nil {
+		t.Fatalf("GetQuizByID: %v", err)
+	}
+	if len(got.Questions) != 3 {
+		t.Errorf("quiz has %d questions after a refused update, want 3", len(got.Questions))
+	}
+}
+
+func TestToGameQuestionMarksAnswersOnlyWhenRevealed(t *testing.T) {
+	question := models.Question{ID: 1, Text: "Q", TimeLimit: 20, Options: []models.Option{
+		{ID: 1, Text: "Right", IsCorrect: true},
+		{ID: 2, Text: "Wrong"},
+	}}
+
+	for _, option := range toGameQuestion(question, false).Options {
+		if option.IsCorrect != nil {
+			t.Errorf("option %q is marked during a game", option.Text)
+		}
+	}
+	for i, option := range toGameQuestion(question, true).Options {
+		if option.IsCorrect == nil || *option.IsCorrect != question.Options[i].IsCorrect {
+			t.Errorf("option %q is_correct = %v, want %v", option.Text, option.IsCorrect, question.Options[i].IsCorrect)
+		}
+	}
+}
+
+func TestPreviewQuizIncludesCorrectAnswersForTheOwner(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(2))
+
+	preview, err := s.PreviewQuiz(ctx, quiz.ID, user.ID)
+	if err != nil {
+		t.Fatalf("PreviewQuiz: %v", err)
+	}
+	if preview.Title != quiz.Title || len(preview.Questions) != 2 {
+		t.Fatalf("preview = %+v, want %q with 2 questions", preview, quiz.Title)
+	}
+	for _, question := range preview.Questions {
+		for _, option := range question.Options {
+			if option.IsCorrect == nil {
+				t.Fatalf("option %q has no is_correct", option.Text)
+			}
+			if *option.IsCorrect != (option.Text == "Right") {
+				t.Errorf("option %q is_correct = %v", option.Text, *option.IsCorrect)
+			}
+		}
+	}
+
+	// Previewing creates no game, and only the owner may do it
+	var games int64
+	if err := db.Model(&models.Game{}).Where("quiz_id = ?", quiz.ID).Count(&games).Error; err != nil {
+		t.Fatalf("count games: %v", err)
+	}
+	if games != 0 {
+		t.Errorf("preview created %d games", games)
+	}
+	if _, err := s.PreviewQuiz(ctx, quiz.ID, createTestUser(t, db).ID); err == nil {
+		t.Error("another user previewed the quiz")
+	}
+}
+
+func TestCreateQuizzesBatch(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+
+	batch := func(n int) []CreateQuizRequest {
+		reqs := make([]CreateQuizRequest, n)
+		for i := range reqs {
+			reqs[i] = *testQuizRequest(i + 1)
+			reqs[i].Title = fmt.Sprintf("Batch quiz %d", i+1)
+		}
+		return reqs
+	}
+
+	quizzes, err := s.CreateQuizzes(ctx, user.ID, batch(3))
+	if err != nil {
+		t.Fatalf("CreateQuizzes: %v", err)
+	}
+	if len(quizzes) != 3 {
+		t.Fatalf("created %d quizzes, want 3", len(quizzes))
+	}
+	for i, quiz := range quizzes {
+		if quiz.Title != fmt.Sprintf("Batch quiz %d", i+1) || len(quiz.Questions) != i+1 {
+			t.Errorf("quiz %d = %q with %d questions, want it in request order with its questions", i, quiz.Title, len(quiz.Questions))
+		}
+	}
+}
+
+func TestCreateQuizzesBatchRollsBackOnAnInvalidQuiz(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+
+	// The third quiz fails validation after the first two were inserted
+	reqs := []CreateQuizRequest{*testQuizRequest(1), *testQuizRequest(2), *testQuizRequest(1)}
+	reqs[2].Questions[0].Options[1].Text = "Right"
+
+	_, err := s.CreateQuizzes(ctx, user.ID, reqs)
+	var batchErr *BatchQuizError
+	if !errors.As(err, &batchErr) || batchErr.Index != 2 {
+		t.Fatalf("CreateQuizzes() = %v, want a BatchQuizError at index 2", err)
+	}
+
+	var count int64
+	if err := db.Model(&models.Quiz{}).Unscoped().Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		t.Fatalf("count quizzes: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("%d quizzes remain after a failed batch, want 0", count)
+	}
+}
+
+func TestQuizAuthorIsIncludedWithoutThePassword(t *testing.T) {
+	db := testDB(t)
+	user := createTestUser(t, db)
+	s := NewQuizService(db, 0, 0)
+	ctx := context.Background()
+	quiz := createTestQuiz(t, db, user.ID, testQuizRequest(1))
+
+	// assertAuthor checks the author is there and its JSON has no password
+	assertAuthor := func(name string, got *models.Quiz) {
+		t.Helper()
+		if got.User == nil || got.User.ID != user.ID || got.User.Email != user.Email || got.User.Username != user.Username {
+			t.Fatalf("%s: author = %+v, want user %d", name, got.User, user.ID)
+		}
+		if got.User.Password != "" {
+			t.Errorf("%s: the password hash was loaded", name)
+		}
+		data, err := json.Marshal(got)
+		if err != nil {
+			t.Fatalf("%s: marshal quiz: %v", name, err)
+		}
+		if strings.Contains(string(data), `"password"`) || strings.Contains(string(data), user.Password) {
+			t.Errorf("%s: quiz JSON exposes the password: %s", name, data)
+		}
+	}
+
+	withAuthor, err := s.GetQuizWithAuthor(ctx, quiz.ID, user.ID)
+	if err != nil {
+		t.Fatalf("GetQuizWithAuthor: %v", err)
+	}
+	assertAuthor("GetQuizWithAuthor", withAuthor)
+
+	quizzes, err := s.AdminListQuizzes(ctx)
+	if err != nil {
+		t.Fatalf("AdminListQuizzes: %v", err)
+	}
+	found := false
+	for i := range quizzes {
+		if quizzes[i].ID == quiz.ID {
+			found = true
+			assertAuthor("AdminListQuizzes", &quizzes[i])
+		}
+	}
+	if !found {
+		t.Fatal("AdminListQuizzes left out the quiz")
+	}
+
+	// Without the flag the author stays out of the response
+	plain, err := s.GetQuizByID(ctx, quiz.ID, user.ID)
+	if err != nil {
+		t.Fatalf("GetQuizByID: %v", err)
+	}
+	data, _ := json.Marshal(plain)
+	if plain.User != nil || strings.Contains(string(data), `"user"`) {
+		t.Errorf("GetQuizByID included the author: %s", data)
+	}
+}
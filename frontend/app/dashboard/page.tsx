@@ -13,6 +13,9 @@ interface Quiz {
   title: string
   description: string
   questions: Question[]
+  question_count: number
+  estimated_duration: number
+  times_played: number
   created_at: string
 }
 
@@ -71,7 +74,14 @@ export default function DashboardPage() {
   }
 
   const handleQuizCreated = (newQuiz: Quiz) => {
-    setQuizzes([newQuiz, ...quizzes])
+    // A new quiz has not been played yet; its summary follows from its questions
+    const questions = newQuiz.questions || []
+    setQuizzes([{
+      ...newQuiz,
+      question_count: questions.length,
+      estimated_duration: questions.reduce((total, question) => total + question.time_limit, 0),
+      times_played: 0,
+    }, ...quizzes])
     setShowCreateModal(false)
     toast.success('Quiz created successfully!')
   }
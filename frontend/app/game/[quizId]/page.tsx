@@ -36,6 +36,8 @@ interface Game {
   pin: string
   status: string
   quiz: Quiz
+  join_url?: string
+  join_code?: string
 }
 
 export default function GamePage() {
@@ -80,7 +82,7 @@ export default function GamePage() {
         console.log('Host attempting to connect to WebSocket:', `${wsUrl}/ws/${game.pin}/${user.id}?playerName=${encodeURIComponent(user.username)}`)
         console.log('Host connection details:', { gamePin: game.pin, userId: user.id, username: user.username })
         
-        ws = new WebSocket(`${wsUrl}/ws/${game.pin}/${user.id}?playerName=${encodeURIComponent(user.username)}`)
+        ws = new WebSocket(`${wsUrl}/ws/${game.pin}/${user.id}?playerName=${encodeURIComponent(user.username)}&token=${encodeURIComponent(token || '')}`)
         
         ws.onopen = () => {
           console.log('WebSocket connected successfully for quiz host')
@@ -140,7 +142,7 @@ export default function GamePage() {
         ws.close(1000, 'Component unmounting')
       }
     }
-  }, [game?.pin, user])
+  }, [game?.pin, user, token])
 
   const fetchQuiz = async () => {
     try {
@@ -366,7 +368,7 @@ export default function GamePage() {
 
       if (!response.ok) {
         const error = await response.json()
-        throw new Error(error.error || 'Failed to start game')
+        throw new Error(error.message || 'Failed to start game')
       }
 
       const newGame = await response.json()
@@ -453,7 +455,7 @@ export default function GamePage() {
               <h3 className="text-lg font-semibold text-gray-900 mb-4">QR Code</h3>
               <div className="inline-block p-4 bg-white rounded-lg shadow-sm">
                 <QRCodeSVG
-                  value={`${window.location.origin}/join?pin=${game.pin}`}
+                  value={game.join_url || `${window.location.origin}/join?pin=${game.pin}`}
                   size={200}
                   level="M"
                 />
@@ -492,7 +494,7 @@ export default function GamePage() {
                     
                     if (!response.ok) {
                       const error = await response.json()
-                      throw new Error(error.error || 'Failed to start quiz')
+                      throw new Error(error.message || 'Failed to start quiz')
                     }
                     
                     toast.success('Quiz started successfully!')
@@ -582,7 +584,7 @@ export default function GamePage() {
                     
                     if (!response.ok) {
                       const error = await response.json()
-                      throw new Error(error.error || 'Failed to advance to next question')
+                      throw new Error(error.message || 'Failed to advance to next question')
                     }
                     
                     toast.success('Moving to next question...')
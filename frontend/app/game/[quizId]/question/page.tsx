@@ -58,6 +58,7 @@ export default function QuestionPage() {
   const [gamePhase, setGamePhase] = useState<'waiting' | 'question' | 'results' | 'finished'>('waiting')
   const [playerAnswers, setPlayerAnswers] = useState<any[]>([])
   const [timeLeft, setTimeLeft] = useState<number>(0)
+  const [answerProgress, setAnswerProgress] = useState<{ answered: number; expected: number } | null>(null)
   const [gamePlayers, setGamePlayers] = useState<Player[]>([])
   
   const { quizId } = useParams()
@@ -159,6 +160,7 @@ export default function QuestionPage() {
           setCurrentQuestion(questionData)
           setCurrentQuestionIndex(data.payload.question_index)
           setTimeLeft(questionData.time_limit)
+          setAnswerProgress(null)
           setGamePhase('question')
           
           setQuiz(prevQuiz => {
@@ -199,6 +201,14 @@ export default function QuestionPage() {
         }
         break
         
+      case 'answer_progress':
+        setAnswerProgress({ answered: data.payload.answered, expected: data.payload.expected })
+        break
+        
+      case 'all_answered':
+        toast.success('Everyone has answered')
+        break
+        
       case 'timer_update':
         console.log('Timer update:', data.payload.time_left)
         setTimeLeft(data.payload.time_left)
@@ -324,7 +334,7 @@ export default function QuestionPage() {
 
       if (!response.ok) {
         const error = await response.json()
-        throw new Error(error.error || 'Failed to end question')
+        throw new Error(error.message || 'Failed to end question')
       }
 
       toast.success('Question ended!')
@@ -350,7 +360,7 @@ export default function QuestionPage() {
       
       if (!response.ok) {
         const error = await response.json()
-        throw new Error(error.error || 'Failed to advance to next question')
+        throw new Error(error.message || 'Failed to advance to next question')
       }
       
       toast.success('Moving to next question...')
@@ -420,6 +430,11 @@ export default function QuestionPage() {
               
               {/* Quiz Controls */}
               <div className="text-center mt-8">
+                {answerProgress && (
+                  <p className="text-gray-600 mb-4">
+                    {answerProgress.answered} of {answerProgress.expected} answered
+                  </p>
+                )}
                 <Button onClick={endQuestion} size="lg">
                   End Question
                 </Button>
@@ -6,12 +6,49 @@ import { Button } from '@/components/ui/Button'
 import { Input } from '@/components/ui/Input'
 import { toast } from 'react-hot-toast'
 
+// A random ID kept in this browser, so games that allow one join per device
+// can hand back the same player instead of creating another
+function getDeviceId() {
+  let deviceId = localStorage.getItem('deviceId')
+  if (!deviceId) {
+    deviceId = crypto.randomUUID()
+    localStorage.setItem('deviceId', deviceId)
+  }
+  return deviceId
+}
+
 export default function JoinGamePage() {
   const [gamePin, setGamePin] = useState('')
   const [playerName, setPlayerName] = useState('')
   const [isJoining, setIsJoining] = useState(false)
+  const [pinError, setPinError] = useState('')
   const router = useRouter()
 
+  // Catch a mistyped PIN before the player gets as far as entering a name
+  const checkGamePin = async () => {
+    setPinError('')
+    if (!gamePin.trim()) {
+      return
+    }
+
+    try {
+      const response = await fetch(`/api/games/${gamePin}/exists`)
+      if (!response.ok) {
+        setPinError('Please enter a valid game PIN')
+        return
+      }
+
+      const status = await response.json()
+      if (!status.exists) {
+        setPinError('No game found with this PIN')
+      } else if (!status.joinable) {
+        setPinError('This game has already finished')
+      }
+    } catch {
+      // Leave it to the join request to report connection problems
+    }
+  }
+
   const handleJoinGame = async (e: React.FormEvent) => {
     e.preventDefault()
     
@@ -31,19 +68,25 @@ export default function JoinGamePage() {
         body: JSON.stringify({
           pin: gamePin,
           name: playerName,
+          device_id: getDeviceId(),
+          guest_token: localStorage.getItem('guestToken') || undefined,
         }),
       })
 
       if (!response.ok) {
         const error = await response.json()
-        throw new Error(error.error || 'Failed to join game')
+        throw new Error(error.message || 'Failed to join game')
       }
 
       const player = await response.json()
-      toast.success('Successfully joined the game!')
+      // The socket token authorizes this player's WebSocket connection
+      sessionStorage.setItem(`socketToken:${gamePin}`, player.socket_token)
+      // The guest token keeps this browser's game history without an account
+      localStorage.setItem('guestToken', player.guest_token)
+      toast.success(player.rejoined ? `Welcome back, ${player.name}!` : 'Successfully joined the game!')
       
       // Redirect to game page
-      router.push(`/play/${gamePin}?playerId=${player.id}&playerName=${encodeURIComponent(playerName)}`)
+      router.push(`/play/${gamePin}?playerId=${player.id}&playerName=${encodeURIComponent(player.name)}`)
     } catch (error) {
       toast.error(error instanceof Error ? error.message : 'Failed to join game')
     } finally {
@@ -72,8 +115,10 @@ export default function JoinGamePage() {
                 type="text"
                 value={gamePin}
                 onChange={(e) => setGamePin(e.target.value)}
+                onBlur={checkGamePin}
+                error={pinError}
                 placeholder="Enter 6-digit PIN"
-                maxLength={6}
+                maxLength={10}
                 required
               />
             </div>
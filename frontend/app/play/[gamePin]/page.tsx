@@ -88,7 +88,8 @@ export default function PlayGamePage() {
           const wsUrl = process.env.NEXT_PUBLIC_WS_URL || 'ws://localhost:8080'
           console.log('Attempting to connect to WebSocket:', `${wsUrl}/ws/${gamePin}/${playerId}?playerName=${encodeURIComponent(playerName || '')}`)
           
-          ws = new WebSocket(`${wsUrl}/ws/${gamePin}/${playerId}?playerName=${encodeURIComponent(playerName || '')}`)
+          const socketToken = sessionStorage.getItem(`socketToken:${gamePin}`) || ''
+          ws = new WebSocket(`${wsUrl}/ws/${gamePin}/${playerId}?playerName=${encodeURIComponent(playerName || '')}&socket_token=${encodeURIComponent(socketToken)}`)
           wsRef.current = ws
           
           ws.onopen = () => {
@@ -315,9 +316,9 @@ export default function PlayGamePage() {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        'X-Socket-Token': sessionStorage.getItem(`socketToken:${gamePin}`) || '',
       },
       body: JSON.stringify({
-        player_id: parseInt(playerId || '0'),
         question_id: currentQuestion.id,
         option_id: optionId,
         time_spent: timeSpent,
@@ -37,7 +37,7 @@ export function AuthForm({ isLogin }: AuthFormProps) {
 
       if (!response.ok) {
         const error = await response.json()
-        throw new Error(error.error || 'Authentication failed')
+        throw new Error(error.message || 'Authentication failed')
       }
 
       const result = await response.json()
@@ -76,7 +76,7 @@ export function CreateQuizModal({ onClose, onQuizCreated }: CreateQuizModalProps
 
       if (!response.ok) {
         const error = await response.json()
-        throw new Error(error.error || 'Failed to create quiz')
+        throw new Error(error.message || 'Failed to create quiz')
       }
 
       const quiz = await response.json()
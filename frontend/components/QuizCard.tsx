@@ -10,6 +10,9 @@ interface Quiz {
   title: string
   description: string
   questions: Question[]
+  question_count: number
+  estimated_duration: number
+  times_played: number
   created_at: string
 }
 
@@ -75,7 +78,11 @@ export function QuizCard({ quiz, onDelete, onStartGame }: QuizCardProps) {
             <p className="text-gray-600 text-sm mb-3">{quiz.description}</p>
           )}
           <div className="flex items-center text-sm text-gray-500">
-            <span>{quiz.questions?.length || 0} questions</span>
+            <span>{quiz.question_count} questions</span>
+            <span className="mx-2">•</span>
+            <span>~{Math.ceil(quiz.estimated_duration / 60)} min</span>
+            <span className="mx-2">•</span>
+            <span>Played {quiz.times_played} {quiz.times_played === 1 ? 'time' : 'times'}</span>
             <span className="mx-2">•</span>
             <span>Created {formatDate(quiz.created_at)}</span>
           </div>
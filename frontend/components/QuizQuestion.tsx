@@ -6,7 +6,7 @@ import { Button } from '@/components/ui/Button'
 interface Option {
   id: number
   text: string
-  is_correct: boolean
+  is_correct?: boolean // only sent to the host, and only when the game shows them the answers
 }
 
 interface Question {
@@ -141,10 +141,11 @@ export function QuizQuestion({
               selectedOption === option.id 
                 ? 'ring-4 ring-primary-300 shadow-lg' 
                 : 'hover:scale-105 active:scale-95'
-            }`}
+            } ${isCreator && option.is_correct ? 'ring-4 ring-green-400' : ''}`}
             onClick={() => handleOptionSelect(option.id)}
             disabled={isAnswered || isCreator}
           >
+            {isCreator && option.is_correct && <span className="mr-2">✓</span>}
             {option.text}
           </Button>
         ))}